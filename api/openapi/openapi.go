@@ -0,0 +1,9 @@
+// Package openapi 内嵌由 api/proto/user.proto 生成的 OpenAPI (Swagger 2.0) 文档
+package openapi
+
+import _ "embed"
+
+// UserServiceJSON /api/v2 REST 接口文档，与 gRPC 接口共用 user.proto 定义
+//
+//go:embed user.swagger.json
+var UserServiceJSON []byte
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -14,17 +15,28 @@ import (
 	"github.com/netkey/golang-user-mysql-redis/internal/config"
 	"github.com/netkey/golang-user-mysql-redis/internal/handler"
 	"github.com/netkey/golang-user-mysql-redis/internal/middleware"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
 	"github.com/netkey/golang-user-mysql-redis/internal/repository"
 	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/certs"
 	"github.com/netkey/golang-user-mysql-redis/pkg/database"
 	"github.com/netkey/golang-user-mysql-redis/pkg/discovery"
+	"github.com/netkey/golang-user-mysql-redis/pkg/healthcheck"
+	"github.com/netkey/golang-user-mysql-redis/pkg/idp"
 	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/mailer"
 	"github.com/netkey/golang-user-mysql-redis/pkg/pb"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
 
 	// 外部依赖
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 func main() {
@@ -57,8 +69,83 @@ func main() {
 	// 4. 组装依赖注入 (DI)
 	// Repo -> Service -> Handler
 	userRepo := repository.NewUserRepository(db, rdb)
-	userSvc := service.NewUserService(userRepo, cfg) // 传入 cfg 供 JWT 使用
+	tokenRepo := repository.NewTokenRepository(rdb)
+	// 旧密钥保留到其签发的 Token 全部过期
+	keyRetain := service.KeyRetention(cfg)
+	keyring, err := utils.LoadKeyring(cfg.JWT.KeysDir, cfg.JWT.Algorithm, keyRetain)
+	if err != nil {
+		logger.Log.Fatal("JWT 密钥加载失败", zap.Error(err))
+	}
+	stopKeyWatch, err := keyring.Watch()
+	if err != nil {
+		logger.Log.Fatal("JWT 密钥目录监听失败", zap.Error(err))
+	}
+	defer stopKeyWatch()
+
+	jwtMgr := utils.NewJWTManager(keyring, cfg.JWT.Issuer, cfg.JWT.Audience)
+	mailSender, err := mailer.New(cfg.Mail)
+	if err != nil {
+		logger.Log.Fatal("邮件发送器初始化失败", zap.Error(err))
+	}
+
+	repos := service.Repositories{
+		User:         userRepo,
+		Token:        tokenRepo,
+		OneTime:      repository.NewOneTimeTokenRepository(rdb),
+		MFA:          repository.NewMFARepository(db),
+		LoginAttempt: repository.NewLoginAttemptRepository(rdb),
+		RBAC:         repository.NewRBACRepository(db, rdb),
+		APIKey:       repository.NewAPIKeyRepository(db, rdb),
+		MagicLink:    repository.NewMagicLinkRepository(rdb),
+		Audit:        repository.NewAuditRepository(db),
+		Introspect:   repository.NewIntrospectionRepository(rdb),
+		UserEvent:    repository.NewUserEventRepository(rdb, cfg.UserEvents.MaxLen),
+	}
+	pwCfg := cfg.Password
+	hasher, err := utils.NewPasswordHasher(pwCfg.Algorithm, pwCfg.BcryptCost, utils.Argon2Params{
+		Memory:      pwCfg.Argon2.Memory,
+		Iterations:  pwCfg.Argon2.Iterations,
+		Parallelism: pwCfg.Argon2.Parallelism,
+	})
+	if err != nil {
+		logger.Log.Fatal("密码哈希配置错误", zap.Error(err))
+	}
+	pwPolicy, err := utils.NewPasswordPolicy(pwCfg.MinLength, pwCfg.MaxLength, pwCfg.BreachedList, pwCfg.CheckPersonal)
+	if err != nil {
+		logger.Log.Fatal("密码策略加载失败", zap.Error(err))
+	}
+
+	userSvc := service.NewUserService(repos, jwtMgr, hasher, pwPolicy, mailSender, cfg) // 传入 cfg 供 JWT 有效期等使用
 	userHandler := handler.NewUserHandler(userSvc)
+	oidcSvc := service.NewOIDCService(repository.NewOAuthClientRepository(db), repository.NewOAuthCodeRepository(rdb), userSvc, jwtMgr, cfg)
+	oidcHandler := handler.NewOIDCHandler(oidcSvc)
+	providers, err := idp.Load(cfg.SocialLogin.Providers, cfg.SocialLogin.AllowStub)
+	if err != nil {
+		logger.Log.Fatal("第三方登录配置错误", zap.Error(err))
+	}
+	socialSvc := service.NewSocialService(userSvc, repository.NewIdentityRepository(db, rdb), providers, cfg.SocialLogin)
+	socialHandler := handler.NewSocialHandler(socialSvc)
+
+	// 服务间鉴权公钥 (gRPC 与 HTTP 内部接口共用)
+	serviceKeys, err := utils.LoadServiceKeySet(cfg.GrpcAuth.Audience, cfg.GrpcAuth.TrustedServices, cfg.GrpcAuth.CertServices)
+	if err != nil {
+		logger.Log.Fatal("服务间鉴权公钥加载失败", zap.Error(err))
+	}
+	// 内部服务私钥签名的 Token 与 OIDC client_credentials Token 均可作为服务身份
+	services := middleware.ServiceVerifiers{serviceKeys, oidcSvc}
+
+	// gRPC TLS / mTLS：证书文件变更后自动重新加载；未开启时使用明文，仅限本地开发
+	serverCreds, gatewayCreds := insecure.NewCredentials(), insecure.NewCredentials()
+	if tc := cfg.Server.GrpcTLS; tc.Enable {
+		grpcCerts, err := certs.NewReloader(tc.CertFile, tc.KeyFile, tc.CAFile)
+		if err != nil {
+			logger.Log.Fatal("gRPC 证书加载失败", zap.Error(err))
+		}
+		defer grpcCerts.Close()
+		serverCreds = credentials.NewTLS(grpcCerts.ServerConfig(tc.RequireClientCert))
+		// REST 网关以本服务证书回连，该身份不在 cert_services 中，仍按请求携带的 Token 鉴权
+		gatewayCreds = credentials.NewTLS(grpcCerts.ClientConfig(tc.ServerName))
+	}
 
 	// 5. 配置 HTTP 服务器 (REST API + Metrics)
 	mux := http.NewServeMux()
@@ -66,20 +153,109 @@ func main() {
 	// --- A. 公开接口 (无需鉴权) ---
 	mux.HandleFunc("/api/v1/register", userHandler.Register)
 	mux.HandleFunc("/api/v1/login", userHandler.Login)
+	mux.HandleFunc("/api/v1/login/mfa", userHandler.LoginMFA)
+	mux.HandleFunc("POST /api/v1/login/magic", userHandler.RequestMagicLink)
+	mux.HandleFunc("POST /api/v1/login/magic/verify", userHandler.LoginMagicLink)
+	mux.HandleFunc("/api/v1/refresh", userHandler.RefreshToken)
+	mux.HandleFunc("/api/v1/email/verify", userHandler.VerifyEmail)
+	mux.HandleFunc("/api/v1/email/resend", userHandler.ResendVerification)
+	mux.HandleFunc("/api/v1/password/forgot", userHandler.ForgotPassword)
+	mux.HandleFunc("/api/v1/password/reset", userHandler.ResetPassword)
 	mux.Handle("/metrics", promhttp.Handler()) // Prometheus 采集接口
+	mux.Handle("/.well-known/jwks.json", handler.NewJWKSHandler(keyring))
+	mux.HandleFunc("GET /.well-known/openid-configuration", oidcHandler.Discovery)
+	mux.HandleFunc("GET /oauth/authorize", oidcHandler.Authorize)
+	mux.HandleFunc("POST /oauth/token", oidcHandler.Token)
+	mux.HandleFunc("GET /api/v1/oauth/social/providers", socialHandler.Providers)
+	mux.HandleFunc("GET /api/v1/oauth/social/{provider}/start", socialHandler.Start)
+	mux.HandleFunc("POST /api/v1/oauth/social/{provider}/callback", socialHandler.Callback)
 
-	// --- B. 私有接口 (应用 JWT 鉴权中间件) ---
+	// --- B. 私有接口 (应用 JWT / API Key 鉴权中间件) ---
 	// 我们可以封装一个简单的路由装饰器或使用第三方路由库，这里使用标准库演示
-	auth := middleware.AuthMiddleware(cfg.JWT.Secret)
+	// 模拟登录期间的请求在鉴权通过后写入审计日志
+	authenticate := middleware.AuthMiddleware(userSvc)
+	audit := middleware.ImpersonationAudit(userSvc)
+	auth := func(h http.Handler) http.Handler {
+		return authenticate(audit(h))
+	}
 
 	mux.Handle("/api/v1/me", auth(http.HandlerFunc(userHandler.GetProfile)))
+	mux.Handle("GET /api/v1/users", auth(http.HandlerFunc(userHandler.BatchGetUsers)))
 	mux.Handle("/api/v1/profile/update", auth(http.HandlerFunc(userHandler.UpdateProfile)))
 	mux.Handle("/api/v1/friends", auth(http.HandlerFunc(userHandler.ListFriends)))
 	mux.Handle("/api/v1/friend/add", auth(http.HandlerFunc(userHandler.AddFriend)))
+	// 账号安全相关接口只允许登录会话访问，不接受 API Key，模拟登录期间也不可用
+	session := func(h http.HandlerFunc) http.Handler {
+		return auth(middleware.SessionOnly(h))
+	}
+	sensitive := func(h http.HandlerFunc) http.Handler {
+		return session(middleware.RefuseImpersonation(h).ServeHTTP)
+	}
+	mux.Handle("/api/v1/logout", session(userHandler.Logout))
+	mux.Handle("/api/v1/password/change", sensitive(userHandler.ChangePassword))
+	mux.Handle("/api/v1/mfa/enroll", sensitive(userHandler.EnrollMFA))
+	mux.Handle("/api/v1/mfa/confirm", sensitive(userHandler.ConfirmMFA))
+	mux.Handle("/api/v1/mfa/disable", sensitive(userHandler.DisableMFA))
+	mux.Handle("GET /api/v1/sessions", sensitive(userHandler.ListSessions))
+	mux.Handle("DELETE /api/v1/sessions/{id}", sensitive(userHandler.RevokeSession))
+	mux.Handle("POST /api/v1/oauth/authorize", sensitive(oidcHandler.Approve))
+	mux.Handle("/oauth/userinfo", middleware.OAuthScopeAuth(oidcSvc, service.ScopeOpenID)(http.HandlerFunc(oidcHandler.UserInfo)))
+	mux.Handle("GET /api/v1/identities", sensitive(socialHandler.ListIdentities))
+	mux.Handle("POST /api/v1/identities/{provider}", sensitive(socialHandler.Link))
+	mux.Handle("POST /api/v1/identities/{provider}/callback", sensitive(socialHandler.LinkCallback))
+	mux.Handle("DELETE /api/v1/identities/{provider}", sensitive(socialHandler.Unlink))
+	mux.Handle("GET /api/v1/api-keys", sensitive(userHandler.ListAPIKeys))
+	mux.Handle("POST /api/v1/api-keys", sensitive(userHandler.CreateAPIKey))
+	mux.Handle("DELETE /api/v1/api-keys/{id}", sensitive(userHandler.RevokeAPIKey))
+
+	// --- 内部服务接口 (服务间 Token) ---
+	internal := middleware.ServiceAuth(services)
+	mux.Handle("POST /api/v1/introspect", internal(http.HandlerFunc(userHandler.IntrospectToken)))
+
+	// --- C. 管理后台接口 (登录 + 权限点授权) ---
+	admin := func(perm string, h http.HandlerFunc) http.Handler {
+		return auth(middleware.RequirePermission(userSvc, perm)(h))
+	}
+	mux.Handle("GET /api/v1/admin/users", admin(model.PermUserRead, userHandler.AdminListUsers))
+	mux.Handle("GET /api/v1/admin/roles", admin(model.PermUserRead, userHandler.AdminListRoles))
+	mux.Handle("GET /api/v1/admin/users/{id}/roles", admin(model.PermUserRead, userHandler.AdminGetUserRoles))
+	mux.Handle("POST /api/v1/admin/users/{id}/roles", admin(model.PermRoleAssign, userHandler.AdminAssignRole))
+	mux.Handle("DELETE /api/v1/admin/users/{id}/roles/{role}", admin(model.PermRoleAssign, userHandler.AdminRemoveRole))
+	mux.Handle("POST /api/v1/admin/users/{id}/suspend", admin(model.PermUserSuspend, userHandler.AdminSuspendUser))
+	mux.Handle("POST /api/v1/admin/users/{id}/reactivate", admin(model.PermUserSuspend, userHandler.AdminReactivateUser))
+	mux.Handle("DELETE /api/v1/admin/users/{id}", admin(model.PermUserDelete, userHandler.AdminDeleteUser))
+	mux.Handle("POST /api/v1/admin/login/unlock", admin(model.PermLoginUnlock, userHandler.AdminUnlockLogin))
+	mux.Handle("GET /api/v1/admin/oauth/clients", admin(model.PermOAuthClient, oidcHandler.ListClients))
+	mux.Handle("POST /api/v1/admin/oauth/clients", admin(model.PermOAuthClient, oidcHandler.CreateClient))
+	mux.Handle("DELETE /api/v1/admin/oauth/clients/{id}", admin(model.PermOAuthClient, oidcHandler.DeleteClient))
+	mux.Handle("POST /api/v1/admin/users/{id}/impersonate", admin(model.PermImpersonate, userHandler.AdminImpersonate))
+	mux.Handle("GET /api/v1/admin/audit-logs", admin(model.PermAuditRead, userHandler.AdminListAuditLogs))
+
+	// --- D. REST 网关 (/api/v2)：由 user.proto 生成，经本机 gRPC 端口转发，鉴权与审计复用 gRPC 拦截器 ---
+	gwConn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", cfg.Server.GrpcPort), grpc.WithTransportCredentials(gatewayCreds))
+	if err != nil {
+		logger.Log.Fatal("REST 网关初始化失败", zap.Error(err))
+	}
+	gateway, err := handler.NewGateway(context.Background(), gwConn)
+	if err != nil {
+		logger.Log.Fatal("REST 网关初始化失败", zap.Error(err))
+	}
+	mux.Handle("/api/v2/", gateway)
+	mux.HandleFunc("GET /api/v2/openapi.json", handler.OpenAPI)
 
 	// 全局中间件应用 (如 Prometheus Metrics)
+	// 按 IP + 路径限流 (rate_limit.strategies)；登录接口另有 Service 层按邮箱 / IP 的失败退避与锁定
+	limiter := middleware.NewRedisRateLimiter(rdb, cfg.RateLimit, userSvc)
+	// 客户端 IP 只在最外层按可信代理解析一次，限流、登录风控、审计与 REST 网关统一使用
+	clientIP, err := middleware.NewClientIPResolver(cfg.Server.TrustedProxies)
+	if err != nil {
+		logger.Log.Fatal("可信代理配置错误", zap.Error(err))
+	}
+
 	var finalHandler http.Handler = mux
+	finalHandler = limiter.Handler(finalHandler)
 	finalHandler = middleware.MetricsMiddleware(finalHandler)
+	finalHandler = clientIP.Handler(finalHandler)
 
 	httpSrv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Server.HttpPort),
@@ -88,19 +264,54 @@ func main() {
 
 	// 6. 配置 gRPC 服务器 (用于内部服务间通信)
 	grpcSrv := grpc.NewServer(
+		grpc.Creds(serverCreds),
 		grpc.ChainUnaryInterceptor(
+			middleware.GrpcMetricsInterceptor,
 			middleware.GrpcRecoveryInterceptor,
 			middleware.GrpcLoggingInterceptor,
-			middleware.GrpcAuthInterceptor,
+			middleware.GrpcAuthInterceptor(userSvc, services, handler.GRPCMethodPolicy),
+			middleware.GrpcImpersonationAuditInterceptor(userSvc),
+			middleware.GrpcPermissionInterceptor(userSvc, handler.GRPCMethodPermissions),
+		),
+		grpc.ChainStreamInterceptor(
+			middleware.GrpcStreamMetricsInterceptor,
+			middleware.GrpcStreamRecoveryInterceptor,
+			middleware.GrpcStreamLoggingInterceptor,
+			middleware.GrpcStreamAuthInterceptor(userSvc, services, handler.GRPCMethodPolicy),
 		),
 	)
 
+	// 用户变更事件分发 (WatchUser)，关闭时先结束所有订阅，避免 GracefulStop 等待长连接
+	eventHub := service.NewUserEventHub(repos.UserEvent, cfg.UserEvents)
+	eventCtx, stopEvents := context.WithCancel(context.Background())
+	go eventHub.Run(eventCtx)
+
 	// 注册 gRPC 服务实现
-	userGRPCHandler := handler.NewUserGRPCHandler(userSvc)
+	userGRPCHandler := handler.NewUserGRPCHandler(userSvc, eventHub)
 	pb.RegisterUserServiceServer(grpcSrv, userGRPCHandler)
 
+	// 健康检查 (grpc.health.v1)：MySQL 与 Redis 均可用时为 SERVING，状态变化同步到 etcd 注册
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(grpcSrv, healthSrv)
+	checker := healthcheck.New(healthSrv, time.Duration(cfg.Server.HealthCheckInterval)*time.Second, map[string]healthcheck.Check{
+		"mysql": db.PingContext,
+		"redis": func(ctx context.Context) error { return rdb.Ping(ctx).Err() },
+	}, pb.UserService_ServiceDesc.ServiceName)
+	if cfg.Server.GrpcReflection {
+		reflection.Register(grpcSrv)
+	}
+
 	// 7. 初始化 Etcd 服务注册
-	reg, err := discovery.NewRegister(cfg.Etcd.Endpoints)
+	var etcdTLS *tls.Config
+	if tc := cfg.Etcd.TLS; tc.Enable {
+		etcdCerts, err := certs.NewReloader(tc.CertFile, tc.KeyFile, tc.CAFile)
+		if err != nil {
+			logger.Log.Fatal("Etcd 证书加载失败", zap.Error(err))
+		}
+		defer etcdCerts.Close()
+		etcdTLS = etcdCerts.ClientConfig(tc.ServerName)
+	}
+	reg, err := discovery.NewRegister(cfg.Etcd.Endpoints, etcdTLS)
 	if err != nil {
 		logger.Log.Fatal("Etcd 初始化失败", zap.Error(err))
 	}
@@ -128,8 +339,15 @@ func main() {
 		}
 	}()
 
-	// 9. 服务注册到 Etcd
+	// 9. 服务注册到 Etcd：租约建立后，节点地址随健康检查结果发布 / 撤下
 	grpcAddr := fmt.Sprintf("%s:%d", cfg.Server.InternalIP, cfg.Server.GrpcPort)
+	checker.OnChange(func(serving bool) {
+		if err := reg.SetServing(context.Background(), serving); err != nil {
+			logger.Log.Error("Etcd 注册状态更新失败", zap.Bool("serving", serving), zap.Error(err))
+		}
+	})
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	go checker.Run(healthCtx)
 	go func() {
 		err := reg.RegisterService(context.Background(), "user-service", grpcAddr, 10)
 		if err != nil {
@@ -147,12 +365,27 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 按照顺序关闭
+	// 按照顺序关闭：先置为 NOT_SERVING 并撤下 Etcd 注册，不再接收新流量，再等待进行中的请求结束
+	stopHealth()
+	checker.Shutdown()
 	reg.Stop()
-	grpcSrv.GracefulStop()
+	stopEvents()
+	// REST 网关依赖 gRPC 服务，先等 HTTP 请求处理完毕再停止 gRPC
 	if err := httpSrv.Shutdown(ctx); err != nil {
 		logger.Log.Error("HTTP Server 强制关闭", zap.Error(err))
 	}
+	gwConn.Close()
+	// 健康检查 Watch 等长连接由客户端断开，超时后强制关闭
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		grpcSrv.Stop()
+	}
 
 	logger.Log.Info("所有服务已安全退出")
 }
@@ -0,0 +1,119 @@
+// userctl 运维命令行工具
+//
+// 用法:
+//
+//	go run ./cmd/userctl unlock -email user@example.com   # 解除账号登录锁定
+//	go run ./cmd/userctl unlock -ip 1.2.3.4                # 解除 IP 封禁
+//	go run ./cmd/userctl grant-role -user 1 -role admin    # 分配角色（初始化第一个管理员）
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/database"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "unlock":
+		unlock(os.Args[2:])
+	case "grant-role":
+		grantRole(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: userctl unlock [-config path] [-email addr] [-ip addr]")
+	fmt.Fprintln(os.Stderr, "       userctl grant-role [-config path] -user id -role name")
+	os.Exit(2)
+}
+
+// unlock 清除登录失败计数与封禁
+func unlock(args []string) {
+	fs := flag.NewFlagSet("unlock", flag.ExitOnError)
+	cfgPath := fs.String("config", "configs/config.yaml", "配置文件路径")
+	email := fs.String("email", "", "要解锁的账号邮箱")
+	ip := fs.String("ip", "", "要解除封禁的 IP")
+	fs.Parse(args)
+
+	if *email == "" && *ip == "" {
+		usage()
+	}
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		fatal(err)
+	}
+	rdb, err := database.NewRedis(cfg.Redis)
+	if err != nil {
+		fatal(err)
+	}
+	defer rdb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := unlockLogin(ctx, repository.NewLoginAttemptRepository(rdb), *email, *ip); err != nil {
+		fatal(err)
+	}
+	fmt.Println("已解锁")
+}
+
+// unlockLogin 与 Service 层保持一致，邮箱统一小写
+func unlockLogin(ctx context.Context, repo repository.LoginAttemptRepository, email, ip string) error {
+	return repo.Unlock(ctx, strings.ToLower(strings.TrimSpace(email)), strings.TrimSpace(ip))
+}
+
+// grantRole 直接写库分配角色，用于尚无管理员时初始化
+func grantRole(args []string) {
+	fs := flag.NewFlagSet("grant-role", flag.ExitOnError)
+	cfgPath := fs.String("config", "configs/config.yaml", "配置文件路径")
+	userID := fs.Int("user", 0, "用户 ID")
+	role := fs.String("role", "", "角色名，如 admin")
+	fs.Parse(args)
+
+	if *userID <= 0 || *role == "" {
+		usage()
+	}
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		fatal(err)
+	}
+	db, err := database.NewMySQL(cfg.MySQL.DSN)
+	if err != nil {
+		fatal(err)
+	}
+	defer db.Close()
+	rdb, err := database.NewRedis(cfg.Redis)
+	if err != nil {
+		fatal(err)
+	}
+	defer rdb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := repository.NewRBACRepository(db, rdb).AssignRole(ctx, *userID, *role); err != nil {
+		fatal(err)
+	}
+	fmt.Println("已分配角色")
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
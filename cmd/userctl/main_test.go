@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestUnlockLogin(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	repo := repository.NewLoginAttemptRepository(rdb)
+	ctx := context.Background()
+
+	repo.BlockEmail(ctx, "alice@example.com", time.Hour)
+	repo.BlockIP(ctx, "198.51.100.7", time.Hour)
+	if _, _, err := repo.RecordFailure(ctx, "alice@example.com", "198.51.100.7", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// 邮箱按大小写不敏感匹配
+	if err := unlockLogin(ctx, repo, " Alice@Example.COM ", ""); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := repo.BlockedFor(ctx, "alice@example.com", ""); d != 0 {
+		t.Fatalf("email still blocked for %v", d)
+	}
+	if mr.Exists("login:fail:email:alice@example.com") {
+		t.Fatal("email failure counter not cleared")
+	}
+	if d, _ := repo.BlockedFor(ctx, "", "198.51.100.7"); d <= 0 {
+		t.Fatal("unlocking an email also cleared the IP block")
+	}
+
+	if err := unlockLogin(ctx, repo, "", "198.51.100.7"); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := repo.BlockedFor(ctx, "", "198.51.100.7"); d != 0 {
+		t.Fatalf("ip still blocked for %v", d)
+	}
+	if mr.Exists("login:fail:ip:198.51.100.7") {
+		t.Fatal("ip failure counter not cleared")
+	}
+}
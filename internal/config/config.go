@@ -1,12 +1,26 @@
 package config
 
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`
-	MySQL     MySQLConfig     `mapstructure:"mysql"`
-	Redis     RedisConfig     `mapstructure:"redis"`
-	Etcd      EtcdConfig      `mapstructure:"etcd"`
-	JWT       JWTConfig       `mapstructure:"jwt"`
-	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Server        ServerConfig        `mapstructure:"server"`
+	MySQL         MySQLConfig         `mapstructure:"mysql"`
+	Redis         RedisConfig         `mapstructure:"redis"`
+	Etcd          EtcdConfig          `mapstructure:"etcd"`
+	JWT           JWTConfig           `mapstructure:"jwt"`
+	RateLimit     RateLimitConfig     `mapstructure:"rate_limit"`
+	GrpcAuth      GrpcAuthConfig      `mapstructure:"grpc_auth"`
+	Mail          MailConfig          `mapstructure:"mail"`
+	EmailVerify   EmailVerifyConfig   `mapstructure:"email_verification"`
+	PasswordReset PasswordResetConfig `mapstructure:"password_reset"`
+	MFA           MFAConfig           `mapstructure:"mfa"`
+	LoginGuard    LoginGuardConfig    `mapstructure:"login_guard"`
+	Password      PasswordConfig      `mapstructure:"password"`
+	OIDC          OIDCConfig          `mapstructure:"oidc"`
+	SocialLogin   SocialLoginConfig   `mapstructure:"social_login"`
+	APIKey        APIKeyConfig        `mapstructure:"api_key"`
+	MagicLink     MagicLinkConfig     `mapstructure:"magic_link"`
+	Impersonation ImpersonationConfig `mapstructure:"impersonation"`
+	Introspection IntrospectionConfig `mapstructure:"introspection"`
+	UserEvents    UserEventsConfig    `mapstructure:"user_events"`
 }
 
 type ServerConfig struct {
@@ -14,6 +28,26 @@ type ServerConfig struct {
 	GrpcPort int `mapstructure:"grpc_port"`
 	// 对应配置文件中的 internal_ip，同时可以被环境变量覆盖
 	InternalIP string `mapstructure:"internal_ip"`
+	// 可信代理 (CIDR 或 IP)：只有来自这些地址的请求才读取 X-Forwarded-For / True-Client-IP 作为客户端 IP
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// 依赖 (MySQL / Redis) 健康检查间隔（秒），结果同步到 grpc.health.v1 与 etcd 注册
+	HealthCheckInterval int `mapstructure:"health_check_interval"`
+	// 是否开启 gRPC 反射 (grpcurl 等调试工具使用)
+	GrpcReflection bool `mapstructure:"grpc_reflection"`
+	// gRPC 服务端 TLS / mTLS
+	GrpcTLS TLSConfig `mapstructure:"grpc_tls"`
+}
+
+// TLSConfig 证书配置，证书文件变更后自动重新加载
+type TLSConfig struct {
+	Enable   bool   `mapstructure:"enable"`
+	CertFile string `mapstructure:"cert_file"` // 本端证书：服务端证书，或 mTLS 的客户端证书
+	KeyFile  string `mapstructure:"key_file"`
+	CAFile   string `mapstructure:"ca_file"` // 校验对端证书的 CA；服务端配置后校验客户端证书 (mTLS)
+	// 仅服务端：拒绝不带客户端证书的连接
+	RequireClientCert bool `mapstructure:"require_client_cert"`
+	// 仅客户端：校验服务端证书使用的名称，为空时使用连接地址
+	ServerName string `mapstructure:"server_name"`
 }
 
 type MySQLConfig struct {
@@ -31,12 +65,17 @@ type RedisConfig struct {
 }
 
 type EtcdConfig struct {
-	Endpoints []string `mapstructure:"endpoints"`
+	Endpoints []string  `mapstructure:"endpoints"`
+	TLS       TLSConfig `mapstructure:"tls"`
 }
 
 type JWTConfig struct {
-	Secret string `mapstructure:"secret"`
-	Expire int    `mapstructure:"expire"` // 过期时间（小时）
+	Algorithm     string `mapstructure:"algorithm"`      // 签名算法 RS256 / EdDSA（仅在生成新密钥时使用）
+	KeysDir       string `mapstructure:"keys_dir"`       // 私钥目录，文件名即 kid (<kid>.pem)
+	Issuer        string `mapstructure:"issuer"`         // 签发方 (iss)
+	Audience      string `mapstructure:"audience"`       // 受众 (aud)
+	Expire        int    `mapstructure:"expire"`         // Access Token 过期时间（小时）
+	RefreshExpire int    `mapstructure:"refresh_expire"` // Refresh Token 过期时间（小时）
 }
 
 type RateLimitConfig struct {
@@ -45,4 +84,164 @@ type RateLimitConfig struct {
 	// 例如："/graphql": 100, "/api/v1/login": 5
 	Strategies map[string]int `mapstructure:"strategies"`
 	Default    int            `mapstructure:"default"` // 没配置时的默认频率
+	// 使用 API Key 的请求按 Key 计数（所有接口共享），不再按 IP 与路径；Key 自身未设置限额时使用该值
+	APIKey int `mapstructure:"api_key"`
+}
+
+// GrpcAuthConfig 服务间调用鉴权
+type GrpcAuthConfig struct {
+	// 服务间 Token 的 aud，调用方签发时需填写本服务名
+	Audience string `mapstructure:"audience"`
+	// 受信任的调用方：服务名 -> PEM 公钥文件
+	TrustedServices map[string]string `mapstructure:"trusted_services"`
+	// 凭 mTLS 客户端证书即可认证的服务 (证书 CN 为服务名)，无需再携带服务 Token
+	CertServices []string `mapstructure:"cert_services"`
+}
+
+type MailConfig struct {
+	Driver  string     `mapstructure:"driver"` // smtp / file / memory
+	From    string     `mapstructure:"from"`
+	FileDir string     `mapstructure:"file_dir"` // driver=file 时邮件写入的目录
+	SMTP    SMTPConfig `mapstructure:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// EmailVerifyConfig 注册邮箱验证
+type EmailVerifyConfig struct {
+	Enable         bool   `mapstructure:"enable"`          // 新注册账号需验证邮箱
+	BlockLogin     bool   `mapstructure:"block_login"`     // 验证前禁止登录
+	TokenTTL       int    `mapstructure:"token_ttl"`       // 验证链接有效期（小时）
+	ResendInterval int    `mapstructure:"resend_interval"` // 同一邮箱重发间隔（秒）
+	LinkURL        string `mapstructure:"link_url"`        // 邮件中的验证链接地址，Token 以 ?token= 追加
+}
+
+// PasswordResetConfig 找回密码
+type PasswordResetConfig struct {
+	TokenTTL       int    `mapstructure:"token_ttl"`       // 重置链接有效期（分钟）
+	ResendInterval int    `mapstructure:"resend_interval"` // 同一邮箱两次申请的间隔（秒）
+	LinkURL        string `mapstructure:"link_url"`        // 前端重置密码页面，Token 以 ?token= 追加
+}
+
+// MagicLinkConfig 邮件链接免密登录（时间单位除 TokenTTL 外均为秒）
+type MagicLinkConfig struct {
+	Enable        bool   `mapstructure:"enable"`
+	TokenTTL      int    `mapstructure:"token_ttl"`       // 登录链接有效期（分钟）
+	LinkURL       string `mapstructure:"link_url"`        // 前端登录落地页，Token 以 ?token= 追加
+	BindIP        bool   `mapstructure:"bind_ip"`         // 只能在申请链接的 IP 上使用
+	BindUserAgent bool   `mapstructure:"bind_user_agent"` // 只能在申请链接的浏览器上使用
+	AutoRegister  bool   `mapstructure:"auto_register"`   // 邮箱未注册时，点击链接后自动创建账号
+	EmailLimit    int    `mapstructure:"email_limit"`     // 同一邮箱窗口内最多发送次数
+	EmailWindow   int    `mapstructure:"email_window"`
+	IPLimit       int    `mapstructure:"ip_limit"` // 同一 IP 窗口内最多申请次数
+	IPWindow      int    `mapstructure:"ip_window"`
+}
+
+// MFAConfig TOTP 二次验证
+type MFAConfig struct {
+	Issuer     string `mapstructure:"issuer"`      // 验证器 App 中显示的名称
+	SecretKey  string `mapstructure:"secret_key"`  // 加密 TOTP 密钥落库使用
+	PendingTTL int    `mapstructure:"pending_ttl"` // 登录二次验证等待时间（秒）
+}
+
+// LoginGuardConfig 登录防暴力破解（时间单位均为秒）
+type LoginGuardConfig struct {
+	Enable        bool `mapstructure:"enable"`
+	Window        int  `mapstructure:"window"`          // 失败计数窗口
+	BackoffAfter  int  `mapstructure:"backoff_after"`   // 同一邮箱失败 N 次后开始指数退避
+	BackoffBase   int  `mapstructure:"backoff_base"`    // 首次退避时长，之后每次翻倍
+	BackoffMax    int  `mapstructure:"backoff_max"`     // 退避时长上限
+	LockAfter     int  `mapstructure:"lock_after"`      // 同一邮箱失败 N 次后锁定账号
+	LockDuration  int  `mapstructure:"lock_duration"`   // 锁定时长
+	IPMaxFailures int  `mapstructure:"ip_max_failures"` // 同一 IP 在窗口内的失败上限，超过后封禁一个窗口
+}
+
+// PasswordConfig 密码哈希算法与密码策略
+// 修改算法或参数后无需批量重置：用户下次登录时按新参数透明重新哈希
+type PasswordConfig struct {
+	Algorithm     string       `mapstructure:"algorithm"`   // argon2id / bcrypt，新密码使用的算法
+	BcryptCost    int          `mapstructure:"bcrypt_cost"` // bcrypt 计算成本
+	Argon2        Argon2Config `mapstructure:"argon2"`
+	MinLength     int          `mapstructure:"min_length"`
+	MaxLength     int          `mapstructure:"max_length"`
+	BreachedList  string       `mapstructure:"breached_list"`  // 泄露密码列表文件，每行一个明文或 SHA-1，为空则不检查
+	CheckPersonal bool         `mapstructure:"check_personal"` // 禁止与邮箱、账号名、昵称过于相似
+}
+
+type Argon2Config struct {
+	Memory      uint32 `mapstructure:"memory"` // KiB
+	Iterations  uint32 `mapstructure:"iterations"`
+	Parallelism uint8  `mapstructure:"parallelism"`
+}
+
+// OIDCConfig 本服务作为 OpenID Connect Provider 的配置
+type OIDCConfig struct {
+	Issuer         string `mapstructure:"issuer"`           // 对外访问地址，如 https://account.example.com，发现文档与 ID Token 的 iss
+	LoginURL       string `mapstructure:"login_url"`        // 前端登录授权页，/oauth/authorize 校验参数后携带原始参数跳转到此
+	CodeTTL        int    `mapstructure:"code_ttl"`         // 授权码有效期（秒）
+	IDTokenTTL     int    `mapstructure:"id_token_ttl"`     // ID Token 有效期（分钟）
+	ClientTokenTTL int    `mapstructure:"client_token_ttl"` // client_credentials Access Token 有效期（分钟）
+}
+
+// SocialLoginConfig 第三方账号登录（外部 OIDC / OAuth2 身份提供方）
+type SocialLoginConfig struct {
+	StateTTL  int                             `mapstructure:"state_ttl"` // 发起登录到回调的最长等待时间（秒）
+	Providers map[string]SocialProviderConfig `mapstructure:"providers"` // Key 为提供方标识，出现在接口路径中
+	// AllowStub 允许 type=stub 的桩提供方，仅限本地开发：任何人都能以桩配置的身份登录
+	AllowStub bool `mapstructure:"allow_stub"`
+}
+
+type SocialProviderConfig struct {
+	Type         string   `mapstructure:"type"` // oidc / oauth2 / stub
+	DisplayName  string   `mapstructure:"display_name"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"` // 在提供方登记的回调地址（前端页面，收到 code/state 后提交给回调接口）
+	Scopes       []string `mapstructure:"scopes"`
+	// type=oidc：通过 Issuer 的发现文档获取端点，并校验 ID Token
+	Issuer string `mapstructure:"issuer"`
+	// type=oauth2：手动指定端点，用户信息从 UserInfoURL 获取
+	AuthURL     string `mapstructure:"auth_url"`
+	TokenURL    string `mapstructure:"token_url"`
+	UserInfoURL string `mapstructure:"user_info_url"`
+	// TrustEmail 信任该提供方已验证的邮箱：首次登录时自动关联同邮箱的已有账号
+	TrustEmail bool `mapstructure:"trust_email"`
+	// type=stub：本地开发与联调使用，不访问外部网络，直接返回配置的身份；需同时开启 allow_stub
+	Stub StubIdentityConfig `mapstructure:"stub"`
+}
+
+type StubIdentityConfig struct {
+	Subject       string `mapstructure:"subject"`
+	Email         string `mapstructure:"email"`
+	EmailVerified bool   `mapstructure:"email_verified"`
+	Name          string `mapstructure:"name"`
+}
+
+// APIKeyConfig 个人 API Key
+type APIKeyConfig struct {
+	MaxPerUser   int `mapstructure:"max_per_user"`   // 每个用户最多创建的 Key 数量
+	MaxRateLimit int `mapstructure:"max_rate_limit"` // 单个 Key 可设置的每分钟请求数上限
+	MaxTTLDays   int `mapstructure:"max_ttl_days"`   // 有效期上限（天），0 表示允许永不过期
+}
+
+// ImpersonationConfig 管理员模拟用户登录
+type ImpersonationConfig struct {
+	TokenTTL int `mapstructure:"token_ttl"` // 模拟登录 Token 有效期（分钟），不签发 Refresh Token
+}
+
+// IntrospectionConfig Token 内省接口（供其他服务鉴权终端用户）
+type IntrospectionConfig struct {
+	// 结果缓存时间（秒），不超过 Token 剩余有效期；吊销后最长在该时间内仍可能返回 active
+	CacheTTL int `mapstructure:"cache_ttl"`
+}
+
+// UserEventsConfig 用户变更事件流 (gRPC WatchUser)
+type UserEventsConfig struct {
+	MaxLen int64 `mapstructure:"max_len"` // Stream 保留的事件数（近似），超出后最早的事件无法续传
+	Buffer int   `mapstructure:"buffer"`  // 每个订阅者的缓冲事件数，消费过慢导致缓冲写满时断开连接
 }
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+// 管理后台接口，路由层通过 middleware.RequirePermission 按权限点授权
+
+// AdminListUsers 用户列表与搜索 (GET /api/v1/admin/users?keyword=&status=&page=&size=)
+func (h *UserHandler) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status, _ := strconv.Atoi(q.Get("status"))
+	page, _ := strconv.Atoi(q.Get("page"))
+	size, _ := strconv.Atoi(q.Get("size"))
+
+	users, total, err := h.svc.ListUsers(r.Context(), q.Get("keyword"), status, page, size)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "success", map[string]interface{}{
+		"total": total,
+		"list":  users,
+	})
+}
+
+// AdminSuspendUser 停用用户 (POST /api/v1/admin/users/{id}/suspend)
+func (h *UserHandler) AdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	operatorID, targetID, ok := h.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.SuspendUser(r.Context(), operatorID, targetID); err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "用户已停用", nil)
+}
+
+// AdminReactivateUser 恢复用户 (POST /api/v1/admin/users/{id}/reactivate)
+func (h *UserHandler) AdminReactivateUser(w http.ResponseWriter, r *http.Request) {
+	operatorID, targetID, ok := h.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.ReactivateUser(r.Context(), operatorID, targetID); err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "用户已恢复", nil)
+}
+
+// AdminDeleteUser 删除用户 (DELETE /api/v1/admin/users/{id})
+func (h *UserHandler) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	operatorID, targetID, ok := h.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteUser(r.Context(), operatorID, targetID); err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "用户已删除", nil)
+}
+
+// AdminListRoles 角色列表 (GET /api/v1/admin/roles)
+func (h *UserHandler) AdminListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.svc.ListRoles(r.Context())
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "success", roles)
+}
+
+// AdminGetUserRoles 查询用户角色 (GET /api/v1/admin/users/{id}/roles)
+func (h *UserHandler) AdminGetUserRoles(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || targetID <= 0 {
+		h.sendJSON(w, http.StatusBadRequest, "无效的用户 ID", nil)
+		return
+	}
+	roles, err := h.svc.GetUserRoles(r.Context(), targetID)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "success", roles)
+}
+
+// AdminAssignRole 分配角色 (POST /api/v1/admin/users/{id}/roles)，请求体 {"role": "support"}
+func (h *UserHandler) AdminAssignRole(w http.ResponseWriter, r *http.Request) {
+	operatorID, targetID, ok := h.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+	if err := h.svc.AssignRole(r.Context(), operatorID, targetID, req.Role); err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "角色已分配", nil)
+}
+
+// AdminRemoveRole 移除角色 (DELETE /api/v1/admin/users/{id}/roles/{role})
+func (h *UserHandler) AdminRemoveRole(w http.ResponseWriter, r *http.Request) {
+	operatorID, targetID, ok := h.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.RemoveRole(r.Context(), operatorID, targetID, r.PathValue("role")); err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "角色已移除", nil)
+}
+
+// AdminUnlockLogin 解除登录锁定 (POST /api/v1/admin/login/unlock)，请求体 {"email": "...", "ip": "..."} 至少填一项
+func (h *UserHandler) AdminUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Email == "" && req.IP == "") {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+	if err := h.svc.UnlockLogin(r.Context(), req.Email, req.IP); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "已解锁", nil)
+}
+
+// AdminImpersonate 模拟用户登录 (POST /api/v1/admin/users/{id}/impersonate)，请求体 {"reason": "工单 #123"}
+// 返回的短期 Token 以目标用户身份访问，期间所有请求写入审计日志
+func (h *UserHandler) AdminImpersonate(w http.ResponseWriter, r *http.Request) {
+	operatorID, targetID, ok := h.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+	token, err := h.svc.Impersonate(r.Context(), operatorID, targetID, req.Reason, clientInfo(r))
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "success", token)
+}
+
+// AdminListAuditLogs 审计日志 (GET /api/v1/admin/audit-logs?actor_id=&user_id=&page=&size=)
+func (h *UserHandler) AdminListAuditLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	actorID, _ := strconv.Atoi(q.Get("actor_id"))
+	userID, _ := strconv.Atoi(q.Get("user_id"))
+	page, _ := strconv.Atoi(q.Get("page"))
+	size, _ := strconv.Atoi(q.Get("size"))
+
+	logs, total, err := h.svc.ListAuditLogs(r.Context(), actorID, userID, page, size)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "success", map[string]interface{}{
+		"total": total,
+		"list":  logs,
+	})
+}
+
+// adminTarget 解析操作者与路径中的目标用户 ID
+func (h *UserHandler) adminTarget(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	operatorID, _ := utils.UserIDFromContext(r.Context())
+	targetID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || targetID <= 0 {
+		h.sendJSON(w, http.StatusBadRequest, "无效的用户 ID", nil)
+		return 0, 0, false
+	}
+	return operatorID, targetID, true
+}
+
+// sendAdminError 将管理操作相关错误映射为 HTTP 状态码
+func (h *UserHandler) sendAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrRoleNotFound):
+		h.sendJSON(w, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, service.ErrOperateSelf), errors.Is(err, service.ErrImpersonateReason):
+		h.sendJSON(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrImpersonateAdmin), errors.Is(err, service.ErrImpersonationForbidden):
+		h.sendJSON(w, http.StatusForbidden, err.Error(), nil)
+	default:
+		h.sendJSON(w, http.StatusInternalServerError, "操作失败", nil)
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+// ListAPIKeys 个人 API Key 列表 (GET /api/v1/api-keys)，不包含 Key 明文
+func (h *UserHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	keys, err := h.svc.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "success", keys)
+}
+
+// CreateAPIKey 创建 API Key (POST /api/v1/api-keys)，Key 明文仅在响应中返回一次
+func (h *UserHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	var req service.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	created, err := h.svc.CreateAPIKey(r.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAPIKeyInvalid):
+			h.sendJSON(w, http.StatusBadRequest, err.Error(), nil)
+		case errors.Is(err, service.ErrAPIKeyLimitReached):
+			h.sendJSON(w, http.StatusConflict, err.Error(), nil)
+		default:
+			h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		}
+		return
+	}
+	h.sendJSON(w, http.StatusCreated, "创建成功，请妥善保存 Key，之后将无法再次查看", created)
+}
+
+// RevokeAPIKey 删除 API Key (DELETE /api/v1/api-keys/{id})
+func (h *UserHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.RevokeAPIKey(r.Context(), userID, id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			h.sendJSON(w, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "API Key 已删除", nil)
+}
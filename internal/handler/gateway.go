@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/netkey/golang-user-mysql-redis/api/openapi"
+	"github.com/netkey/golang-user-mysql-redis/internal/middleware"
+	"github.com/netkey/golang-user-mysql-redis/pkg/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NewGateway REST 网关：按 user.proto 中的 google.api.http 注解把 /api/v2 请求转为对 conn 的 gRPC 调用
+// conn 指向本进程的 gRPC 端口，鉴权、审计与指标统一由 gRPC 拦截器处理；JSON 字段名与 proto 一致 (snake_case)
+func NewGateway(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		// gRPC 侧看到的对端是网关自身，真实客户端信息通过 metadata 传递；客户端自带的同名 metadata 头一律丢弃
+		runtime.WithIncomingHeaderMatcher(gatewayHeaderMatcher),
+		runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+			return middleware.GatewayMetadata(r)
+		}),
+	)
+	if err := pb.RegisterUserServiceHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// gatewayHeaderMatcher 在默认规则基础上拒绝 Grpc-Metadata-X-Gateway-* 请求头，防止客户端伪造网关传递的客户端信息
+func gatewayHeaderMatcher(key string) (string, bool) {
+	name, ok := runtime.DefaultHeaderMatcher(key)
+	if ok && strings.HasPrefix(strings.ToLower(name), middleware.GatewayMetadataPrefix) {
+		return "", false
+	}
+	return name, ok
+}
+
+// OpenAPI GET /api/v2/openapi.json
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openapi.UserServiceJSON)
+}
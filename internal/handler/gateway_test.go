@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/middleware"
+	"github.com/netkey/golang-user-mysql-redis/pkg/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// clientInfoServer 在返回的用户资料中回显 gRPC 侧看到的客户端信息
+type clientInfoServer struct {
+	pb.UnimplementedUserServiceServer
+}
+
+func (clientInfoServer) GetUserByID(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	return &pb.User{Id: req.Id, Name: middleware.GrpcPeerIP(ctx), Nickname: middleware.GrpcUserAgent(ctx)}, nil
+}
+
+func newTestGateway(t *testing.T) http.Handler {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterUserServiceServer(srv, clientInfoServer{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	gw, err := NewGateway(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gw
+}
+
+func TestGatewayIgnoresForgedClientMetadata(t *testing.T) {
+	gw := newTestGateway(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v2/users/7", nil)
+	r.RemoteAddr = "198.51.100.7:5000"
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	r.Header.Set("Grpc-Metadata-X-Gateway-Client-Ip", "10.0.0.1")
+	r.Header.Set("grpc-metadata-x-gateway-user-agent", "forged")
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var u struct {
+		Name     string `json:"name"`
+		Nickname string `json:"nickname"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "198.51.100.7" || u.Nickname != "Mozilla/5.0" {
+		t.Fatalf("gRPC saw client ip %q, user agent %q", u.Name, u.Nickname)
+	}
+}
+
+func TestGatewayHeaderMatcher(t *testing.T) {
+	cases := map[string]struct {
+		name string
+		ok   bool
+	}{
+		"Grpc-Metadata-X-Gateway-Client-Ip":  {"", false},
+		"grpc-metadata-x-gateway-user-agent": {"", false},
+		"Grpc-Metadata-X-Request-Id":         {"X-Request-Id", true},
+		"Authorization":                      {"grpcgateway-Authorization", true},
+		"X-Gateway-Client-Ip":                {"", false},
+	}
+	for key, want := range cases {
+		if name, ok := gatewayHeaderMatcher(key); name != want.name || ok != want.ok {
+			t.Errorf("gatewayHeaderMatcher(%q) = %q, %v; want %q, %v", key, name, ok, want.name, want.ok)
+		}
+	}
+}
@@ -2,27 +2,297 @@ package handler
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/middleware"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
 	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
 	"github.com/netkey/golang-user-mysql-redis/pkg/pb"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// GRPCMethodPolicy 各 RPC 的鉴权策略，新增 RPC 时需在此登记（未登记的方法要求用户 Token）
+var GRPCMethodPolicy = middleware.MethodPolicy{
+	Default: middleware.PolicyUser,
+	Methods: map[string]middleware.AuthPolicy{
+		// 终端用户查询自己/他人资料，或 OrderService 等内部服务调用
+		pb.UserService_GetUserByID_FullMethodName:   middleware.PolicyAny,
+		pb.UserService_BatchGetUsers_FullMethodName: middleware.PolicyAny,
+		// 按邮箱查询供内部服务与拥有 user:read 权限的管理员使用（见 GRPCMethodPermissions）
+		pb.UserService_GetUserByEmail_FullMethodName: middleware.PolicyAny,
+		// 校验、内省 Token 仅供内部服务使用
+		pb.UserService_ValidateToken_FullMethodName:   middleware.PolicyService,
+		pb.UserService_IntrospectToken_FullMethodName: middleware.PolicyService,
+		pb.UserService_WatchUser_FullMethodName:       middleware.PolicyService,
+		pb.UserService_Register_FullMethodName:        middleware.PolicyPublic,
+		pb.UserService_Login_FullMethodName:           middleware.PolicyPublic,
+		// 健康检查与反射供负载均衡器、调试工具使用
+		healthpb.Health_Check_FullMethodName:                                   middleware.PolicyPublic,
+		healthpb.Health_List_FullMethodName:                                    middleware.PolicyPublic,
+		healthpb.Health_Watch_FullMethodName:                                   middleware.PolicyPublic,
+		reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:      middleware.PolicyPublic,
+		reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName: middleware.PolicyPublic,
+	},
+	// 修改数据的 RPC 在此登记，API Key 调用需具备 write scope
+	Writes: map[string]bool{
+		pb.UserService_UpdateProfile_FullMethodName: true,
+		pb.UserService_AddFriend_FullMethodName:     true,
+	},
+}
+
+// GRPCMethodPermissions 用户 Token 调用时额外要求的权限点，服务间调用不受影响
+var GRPCMethodPermissions = map[string]string{
+	pb.UserService_GetUserByEmail_FullMethodName: model.PermUserRead,
+}
+
 type UserGRPCHandler struct {
 	pb.UnimplementedUserServiceServer
-	svc *service.UserService
+	svc    *service.UserService
+	events *service.UserEventHub
 }
 
-func NewUserGRPCHandler(svc *service.UserService) *UserGRPCHandler {
-	return &UserGRPCHandler{svc: svc}
+func NewUserGRPCHandler(svc *service.UserService, events *service.UserEventHub) *UserGRPCHandler {
+	return &UserGRPCHandler{svc: svc, events: events}
 }
 
-func (h *UserGRPCHandler) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.UserResponse, error) {
+func (h *UserGRPCHandler) GetUserByID(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
 	user, err := h.svc.GetUser(ctx, int(req.Id))
 	if err != nil {
-		return nil, err
+		return nil, grpcError(err)
+	}
+	return toPBUser(ctx, user), nil
+}
+
+func (h *UserGRPCHandler) BatchGetUsers(ctx context.Context, req *pb.BatchGetUsersRequest) (*pb.BatchGetUsersResponse, error) {
+	ids := make([]int, len(req.Ids))
+	for i, id := range req.Ids {
+		ids[i] = int(id)
+	}
+	users, err := h.svc.BatchGetUsers(ctx, ids)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.BatchGetUsersResponse{Users: make([]*pb.User, 0, len(users))}
+	for i := range users {
+		resp.Users = append(resp.Users, toPBUser(ctx, &users[i]))
+	}
+	return resp, nil
+}
+
+func (h *UserGRPCHandler) GetUserByEmail(ctx context.Context, req *pb.GetUserByEmailRequest) (*pb.User, error) {
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	user, err := h.svc.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toPBUser(ctx, user), nil
+}
+
+func (h *UserGRPCHandler) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	if req.Name == "" || req.Email == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "name, email and password are required")
+	}
+	if err := h.svc.Register(ctx, req.Name, req.Nickname, req.Email, req.Password); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.RegisterResponse{}, nil
+}
+
+func (h *UserGRPCHandler) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	if req.Email == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "email and password are required")
+	}
+	client := grpcClientInfo(ctx)
+	client.Device = req.Device
+	result, err := h.svc.Login(ctx, req.Email, req.Password, client)
+	if err != nil {
+		return nil, grpcError(err)
 	}
-	return &pb.UserResponse{
-		Id:    int32(user.ID),
-		Name:  user.Name,
-		Email: user.Email,
+	if result.MFARequired {
+		return &pb.LoginResponse{MfaRequired: true, MfaToken: result.MFAToken}, nil
+	}
+	return &pb.LoginResponse{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		ExpiresIn:    result.ExpiresIn,
 	}, nil
 }
+
+func (h *UserGRPCHandler) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest) (*pb.ValidateTokenResponse, error) {
+	claims, err := h.svc.ValidateToken(ctx, req.Token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	resp := &pb.ValidateTokenResponse{
+		UserId:    int32(claims.UserID()),
+		TokenType: claims.Type,
+		Scope:     claims.Scope,
+		SessionId: claims.SessionID,
+		ActorId:   int32(claims.ActorID()),
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = timestamppb.New(claims.ExpiresAt.Time)
+	}
+	return resp, nil
+}
+
+func (h *UserGRPCHandler) IntrospectToken(ctx context.Context, req *pb.IntrospectTokenRequest) (*pb.IntrospectTokenResponse, error) {
+	result, err := h.svc.IntrospectToken(ctx, req.Token)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.IntrospectTokenResponse{
+		Active:    result.Active,
+		UserId:    int32(result.UserID),
+		TokenType: result.TokenType,
+		Scopes:    result.Scopes,
+		SessionId: result.SessionID,
+		ActorId:   int32(result.ActorID),
+	}
+	if result.ExpiresAt != nil {
+		resp.ExpiresAt = timestamppb.New(*result.ExpiresAt)
+	}
+	return resp, nil
+}
+
+func (h *UserGRPCHandler) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.User, error) {
+	userID, ok := utils.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "user token required")
+	}
+	if err := h.svc.UpdateMyProfile(ctx, userID, req.Nickname, int(req.Age), req.Avatar); err != nil {
+		return nil, grpcError(err)
+	}
+	user, err := h.svc.GetUser(ctx, userID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toPBUser(ctx, user), nil
+}
+
+func (h *UserGRPCHandler) ListFriends(ctx context.Context, req *pb.ListFriendsRequest) (*pb.ListFriendsResponse, error) {
+	userID, ok := utils.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "user token required")
+	}
+	friends, err := h.svc.ListFriends(ctx, userID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.ListFriendsResponse{Friends: make([]*pb.User, 0, len(friends))}
+	for i := range friends {
+		resp.Friends = append(resp.Friends, toPBUser(ctx, &friends[i]))
+	}
+	return resp, nil
+}
+
+func (h *UserGRPCHandler) AddFriend(ctx context.Context, req *pb.AddFriendRequest) (*pb.AddFriendResponse, error) {
+	userID, ok := utils.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "user token required")
+	}
+	if err := h.svc.AddFriend(ctx, userID, int(req.FriendId)); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.AddFriendResponse{}, nil
+}
+
+// WatchUser 推送用户变更事件，直到客户端断开、消费过慢或服务关闭
+func (h *UserGRPCHandler) WatchUser(req *pb.WatchUserRequest, stream grpc.ServerStreamingServer[pb.UserEvent]) error {
+	ids := make([]int, len(req.UserIds))
+	for i, id := range req.UserIds {
+		ids[i] = int(id)
+	}
+	err := h.events.Watch(stream.Context(), ids, req.LastEventId, func(e model.UserEvent) error {
+		return stream.Send(&pb.UserEvent{
+			Id:         e.ID,
+			UserId:     int32(e.UserID),
+			Type:       pbUserEventTypes[e.Type],
+			OccurredAt: timestamppb.New(e.OccurredAt),
+		})
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err // stream.Send 返回的错误已是 gRPC 状态
+		}
+		return grpcError(err)
+	}
+	return nil
+}
+
+var pbUserEventTypes = map[string]pb.UserEventType{
+	model.UserEventUpdated:       pb.UserEventType_USER_EVENT_TYPE_UPDATED,
+	model.UserEventStatusChanged: pb.UserEventType_USER_EVENT_TYPE_STATUS_CHANGED,
+	model.UserEventDeleted:       pb.UserEventType_USER_EVENT_TYPE_DELETED,
+}
+
+// toPBUser 转换为 pb.User；终端用户查看他人资料时不返回邮箱，内部服务与本人可见
+func toPBUser(ctx context.Context, u *model.User) *pb.User {
+	out := &pb.User{
+		Id:       int32(u.ID),
+		Name:     u.Name,
+		Email:    u.Email,
+		Nickname: u.Nickname,
+		Avatar:   u.Avatar,
+		Age:      int32(u.Age),
+		Gender:   int32(u.Gender),
+		Status:   pb.UserStatus(u.Status),
+	}
+	if userID, ok := utils.UserIDFromContext(ctx); ok && userID != u.ID {
+		out.Email = ""
+	}
+	if !u.CreatedAt.IsZero() {
+		out.CreatedAt = timestamppb.New(u.CreatedAt)
+	}
+	if !u.UpdatedAt.IsZero() {
+		out.UpdatedAt = timestamppb.New(u.UpdatedAt)
+	}
+	return out
+}
+
+// grpcClientInfo 提取调用方 IP 与 User-Agent，对应 HTTP 的 clientInfo
+func grpcClientInfo(ctx context.Context) service.ClientInfo {
+	return service.ClientInfo{IP: middleware.GrpcPeerIP(ctx), UserAgent: middleware.GrpcUserAgent(ctx)}
+}
+
+// grpcError 将 Service 层错误映射为 gRPC 状态码，未知错误不向调用方暴露细节
+func grpcError(err error) error {
+	var throttled *service.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, service.ErrUserNotFound):
+		return status.Error(codes.NotFound, service.ErrUserNotFound.Error())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrAccountSuspended), errors.Is(err, service.ErrEmailNotVerified),
+		errors.Is(err, service.ErrImpersonationForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrEmailRegistered):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, utils.ErrWeakPassword), errors.Is(err, service.ErrAddSelf), errors.Is(err, service.ErrTooManyIDs),
+		errors.Is(err, service.ErrWatchTooManyUsers), errors.Is(err, service.ErrEventIDInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrSlowConsumer):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, service.ErrEventsExpired):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, service.ErrEventHubClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		logger.Log.Error("gRPC 请求处理失败", zap.Error(err))
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/netkey/golang-user-mysql-redis/internal/middleware"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/pb"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+func TestToPBUserHidesEmailFromOthers(t *testing.T) {
+	u := &model.User{ID: 7, Name: "alice", Email: "alice@example.com", Status: model.UserStatusNormal, CreatedAt: time.Now()}
+	asUser := func(id string) context.Context {
+		return utils.ContextWithClaims(context.Background(), &utils.Claims{Type: utils.TokenTypeAccess, RegisteredClaims: jwt.RegisteredClaims{Subject: id}})
+	}
+
+	cases := map[string]struct {
+		ctx   context.Context
+		email string
+	}{
+		"self":             {asUser("7"), "alice@example.com"},
+		"other user":       {asUser("8"), ""},
+		"internal service": {context.Background(), "alice@example.com"},
+	}
+	for name, tc := range cases {
+		got := toPBUser(tc.ctx, u)
+		if got.Email != tc.email {
+			t.Errorf("%s: email = %q, want %q", name, got.Email, tc.email)
+		}
+		if got.Id != 7 || got.Status != pb.UserStatus(model.UserStatusNormal) || got.CreatedAt == nil || got.UpdatedAt != nil {
+			t.Errorf("%s: user = %+v", name, got)
+		}
+	}
+}
+
+func TestGRPCErrorCodes(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{sql.ErrNoRows, codes.NotFound},
+		{service.ErrUserNotFound, codes.NotFound},
+		{service.ErrInvalidCredentials, codes.Unauthenticated},
+		{service.ErrAccountSuspended, codes.PermissionDenied},
+		{service.ErrEmailRegistered, codes.AlreadyExists},
+		{fmt.Errorf("%w: too short", utils.ErrWeakPassword), codes.InvalidArgument},
+		{service.ErrTooManyIDs, codes.InvalidArgument},
+		{&service.LoginThrottledError{RetryAfter: time.Minute}, codes.ResourceExhausted},
+		{service.ErrEventsExpired, codes.OutOfRange},
+		{fmt.Errorf("dial tcp: connection refused"), codes.Internal},
+	}
+	for _, tc := range cases {
+		if got := status.Code(grpcError(tc.err)); got != tc.code {
+			t.Errorf("grpcError(%v) = %s, want %s", tc.err, got, tc.code)
+		}
+	}
+	// 未知错误不向调用方暴露细节
+	if msg := status.Convert(grpcError(fmt.Errorf("dial tcp 10.0.0.5:3306"))).Message(); msg != "internal error" {
+		t.Errorf("internal error message = %q", msg)
+	}
+}
+
+func TestGRPCMethodPolicy(t *testing.T) {
+	methods := make(map[string]bool)
+	for _, m := range pb.UserService_ServiceDesc.Methods {
+		methods["/"+pb.UserService_ServiceDesc.ServiceName+"/"+m.MethodName] = true
+	}
+	for _, s := range pb.UserService_ServiceDesc.Streams {
+		methods["/"+pb.UserService_ServiceDesc.ServiceName+"/"+s.StreamName] = true
+	}
+
+	// 登记的写方法必须真实存在，否则 API Key 的 write scope 检查会静默失效
+	for m := range GRPCMethodPolicy.Writes {
+		if !methods[m] {
+			t.Errorf("write method %s not in UserService", m)
+		}
+	}
+	// 需要权限点的方法必须允许用户 Token 调用，否则权限登记没有意义
+	for m := range GRPCMethodPermissions {
+		if p := GRPCMethodPolicy.For(m); !methods[m] || (p != middleware.PolicyUser && p != middleware.PolicyAny) {
+			t.Errorf("permission registered for %s (policy %s)", m, p)
+		}
+	}
+	want := map[string]middleware.AuthPolicy{
+		pb.UserService_GetUserByEmail_FullMethodName: middleware.PolicyAny,
+		pb.UserService_ValidateToken_FullMethodName:  middleware.PolicyService,
+		pb.UserService_WatchUser_FullMethodName:      middleware.PolicyService,
+		pb.UserService_UpdateProfile_FullMethodName:  middleware.PolicyUser,
+		pb.UserService_AddFriend_FullMethodName:      middleware.PolicyUser,
+		pb.UserService_Login_FullMethodName:          middleware.PolicyPublic,
+	}
+	for m, p := range want {
+		if got := GRPCMethodPolicy.For(m); got != p {
+			t.Errorf("policy for %s = %s, want %s", m, got, p)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// IntrospectToken Token 内省 (POST /api/v1/introspect)，请求体 {"token": "..."}
+// 仅限内部服务调用（middleware.ServiceAuth）；Token 无效时仍返回 200，data.active 为 false
+func (h *UserHandler) IntrospectToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	result, err := h.svc.IntrospectToken(r.Context(), req.Token)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, "内省失败", nil)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "success", result)
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+// JWKSHandler 公开签名公钥，供其他服务离线校验本服务签发的 Token
+type JWKSHandler struct {
+	keys *utils.Keyring
+}
+
+func NewJWKSHandler(keys *utils.Keyring) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// ServeHTTP GET /.well-known/jwks.json
+// 按 RFC 7517 直接返回 JWK Set，不包裹统一的 Response 结构
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 允许校验方缓存一段时间；轮换后新 kid 查不到时校验方应主动刷新
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
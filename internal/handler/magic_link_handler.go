@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/service"
+)
+
+// RequestMagicLink 申请邮件登录链接 (POST /api/v1/login/magic)
+func (h *UserHandler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.RequestMagicLink(r.Context(), req.Email, clientInfo(r)); err != nil {
+		var throttled *service.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+			h.sendJSON(w, http.StatusTooManyRequests, err.Error(), nil)
+		case errors.Is(err, service.ErrMagicLinkDisabled):
+			h.sendJSON(w, http.StatusNotFound, err.Error(), nil)
+		default:
+			h.sendJSON(w, http.StatusInternalServerError, "发送失败", nil)
+		}
+		return
+	}
+
+	// 无论邮箱是否存在都返回相同结果
+	h.sendJSON(w, http.StatusOK, "如果该邮箱可以登录，登录链接已发送", nil)
+}
+
+// LoginMagicLink 使用登录链接中的 Token 登录 (POST /api/v1/login/magic/verify)
+// 需在申请链接的同一设备与浏览器上调用，返回结果与密码登录相同
+func (h *UserHandler) LoginMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token  string `json:"token"`
+		Device string `json:"device"` // 可选，显示在登录设备列表中
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	client := clientInfo(r)
+	client.Device = req.Device
+	result, err := h.svc.LoginWithMagicLink(r.Context(), req.Token, client)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMagicLinkInvalid):
+			h.sendJSON(w, http.StatusUnauthorized, err.Error(), nil)
+		case errors.Is(err, service.ErrMagicLinkDisabled):
+			h.sendJSON(w, http.StatusNotFound, err.Error(), nil)
+		case errors.Is(err, service.ErrAccountSuspended), errors.Is(err, service.ErrEmailNotVerified):
+			h.sendJSON(w, http.StatusForbidden, err.Error(), nil)
+		default:
+			h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		}
+		return
+	}
+
+	if result.MFARequired {
+		h.sendJSON(w, http.StatusOK, "请输入二次验证码", result)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "登录成功", result)
+}
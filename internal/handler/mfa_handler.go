@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// LoginMFA 登录二次验证 (POST /api/v1/login/mfa)
+// 使用登录接口返回的 mfa_token 与验证码（或恢复码）换取正式 Token 对
+func (h *UserHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+		Device   string `json:"device"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	client := clientInfo(r)
+	client.Device = req.Device
+	pair, err := h.svc.CompleteMFALogin(r.Context(), req.MFAToken, req.Code, client)
+	if err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "登录成功", pair)
+}
+
+// EnrollMFA 发起绑定 (POST /api/v1/mfa/enroll)
+func (h *UserHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	enrollment, err := h.svc.EnrollMFA(r.Context(), userID)
+	if err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "请使用验证器 App 扫码并提交验证码完成绑定", enrollment)
+}
+
+// ConfirmMFA 确认绑定 (POST /api/v1/mfa/confirm)，返回恢复码
+func (h *UserHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	codes, err := h.svc.ConfirmMFA(r.Context(), userID, req.Code)
+	if err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "二次验证已开启，请妥善保存恢复码", map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableMFA 关闭二次验证 (POST /api/v1/mfa/disable)
+func (h *UserHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.DisableMFA(r.Context(), userID, req.Code); err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "二次验证已关闭", nil)
+}
+
+// sendMFAError 将二次验证相关错误映射为 HTTP 状态码
+func (h *UserHandler) sendMFAError(w http.ResponseWriter, err error) {
+	var throttled *service.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		h.sendJSON(w, http.StatusTooManyRequests, err.Error(), nil)
+	case errors.Is(err, service.ErrMFACodeInvalid):
+		h.sendJSON(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrMFATokenInvalid):
+		h.sendJSON(w, http.StatusUnauthorized, err.Error(), nil)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled), errors.Is(err, service.ErrMFANotEnabled):
+		h.sendJSON(w, http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, service.ErrImpersonationForbidden):
+		h.sendJSON(w, http.StatusForbidden, err.Error(), nil)
+	default:
+		h.sendJSON(w, http.StatusInternalServerError, "操作失败", nil)
+	}
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// OIDCHandler OpenID Connect Provider 接口
+// 协议端点 (/oauth/*) 按 RFC 6749 返回 {"error", "error_description"}，管理接口使用统一的 Response 结构
+type OIDCHandler struct {
+	svc *service.OIDCService
+}
+
+func NewOIDCHandler(svc *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{svc: svc}
+}
+
+// Discovery 发现文档 (GET /.well-known/openid-configuration)
+func (h *OIDCHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeOAuthJSON(w, http.StatusOK, h.svc.Discovery())
+}
+
+// Authorize 授权端点 (GET /oauth/authorize)
+// 校验参数后携带原始参数跳转到前端登录授权页，由前端在用户登录后调用 POST /api/v1/oauth/authorize
+func (h *OIDCHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	req := authorizeRequestFromQuery(r.URL.Query())
+	if _, err := h.svc.ValidateAuthorizeRequest(r.Context(), req); err != nil {
+		h.sendAuthorizeError(w, r, err)
+		return
+	}
+
+	loginURL, err := url.Parse(h.svc.LoginURL())
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "login_url is misconfigured")
+		return
+	}
+	loginURL.RawQuery = r.URL.RawQuery
+	http.Redirect(w, r, loginURL.String(), http.StatusFound)
+}
+
+// Approve 已登录用户确认授权 (POST /api/v1/oauth/authorize)，返回携带授权码的回调地址，由前端完成跳转
+func (h *OIDCHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	claims, _ := utils.ClaimsFromContext(r.Context())
+
+	var req service.AuthorizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeResponse(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	redirectTo, err := h.svc.Authorize(r.Context(), claims, &req)
+	if err != nil {
+		var oe *service.OAuthError
+		switch {
+		case errors.As(err, &oe) && oe.RedirectURI != "":
+			// 协议错误同样交给客户端处理
+			writeResponse(w, http.StatusOK, oe.Description, map[string]string{"redirect_to": oe.RedirectURL()})
+		case errors.As(err, &oe):
+			writeResponse(w, http.StatusBadRequest, oe.Description, nil)
+		default:
+			logger.Log.Error("OIDC 授权失败", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "授权失败", nil)
+		}
+		return
+	}
+
+	writeResponse(w, http.StatusOK, "success", map[string]string{"redirect_to": redirectTo})
+}
+
+// Token Token 端点 (POST /oauth/token)，application/x-www-form-urlencoded
+func (h *OIDCHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	// client_secret_basic 优先，其次 client_secret_post；公开客户端只传 client_id
+	cred := service.ClientCredentials{ID: r.PostForm.Get("client_id"), Secret: r.PostForm.Get("client_secret")}
+	if id, secret, ok := r.BasicAuth(); ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+		cred = service.ClientCredentials{ID: id, Secret: secret}
+	}
+
+	var (
+		resp *service.OAuthTokenResponse
+		err  error
+	)
+	switch grant := r.PostForm.Get("grant_type"); grant {
+	case model.GrantAuthorizationCode:
+		resp, err = h.svc.ExchangeCode(r.Context(), cred,
+			r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"), clientInfo(r))
+	case model.GrantRefreshToken:
+		resp, err = h.svc.RefreshGrant(r.Context(), cred, r.PostForm.Get("refresh_token"))
+	case model.GrantClientCredentials:
+		resp, err = h.svc.ClientCredentialsGrant(r.Context(), cred, r.PostForm.Get("scope"))
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type "+grant+" is not supported")
+		return
+	}
+
+	if err != nil {
+		var oe *service.OAuthError
+		if !errors.As(err, &oe) {
+			logger.Log.Error("OIDC Token 签发失败", zap.Error(err))
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal error")
+			return
+		}
+		code := http.StatusBadRequest
+		if oe.Code == "invalid_client" {
+			code = http.StatusUnauthorized
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(w, code, oe.Code, oe.Description)
+		return
+	}
+
+	writeOAuthJSON(w, http.StatusOK, resp)
+}
+
+// UserInfo (GET/POST /oauth/userinfo)，需携带 OIDC 客户端的 Access Token (scope 含 openid)
+func (h *OIDCHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	claims, _ := utils.ClaimsFromContext(r.Context())
+
+	info, err := h.svc.UserInfo(r.Context(), claims)
+	if err != nil {
+		var oe *service.OAuthError
+		if errors.As(err, &oe) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="`+oe.Code+`"`)
+			writeOAuthError(w, http.StatusForbidden, oe.Code, oe.Description)
+			return
+		}
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	writeOAuthJSON(w, http.StatusOK, info)
+}
+
+// --- 客户端管理 (管理后台) ---
+
+// CreateClient 注册客户端 (POST /api/v1/admin/oauth/clients)，client_secret 仅在此返回一次
+func (h *OIDCHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	operatorID, _ := utils.UserIDFromContext(r.Context())
+
+	var req struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		GrantTypes   []string `json:"grant_types"`
+		Scopes       []string `json:"scopes"`
+		Public       bool     `json:"public"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeResponse(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	client := &model.OAuthClient{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		GrantTypes:   req.GrantTypes,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+	}
+	secret, err := h.svc.RegisterClient(r.Context(), operatorID, client)
+	if err != nil {
+		if errors.Is(err, service.ErrOAuthClientInvalid) {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		writeResponse(w, http.StatusInternalServerError, "注册失败", nil)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, "注册成功，请妥善保存 client_secret", map[string]interface{}{
+		"client":        client,
+		"client_secret": secret,
+	})
+}
+
+// ListClients 客户端列表 (GET /api/v1/admin/oauth/clients)
+func (h *OIDCHandler) ListClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.svc.ListClients(r.Context())
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeResponse(w, http.StatusOK, "success", clients)
+}
+
+// DeleteClient 删除客户端 (DELETE /api/v1/admin/oauth/clients/{id})
+func (h *OIDCHandler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	if err := h.svc.DeleteClient(r.Context(), r.PathValue("id")); err != nil {
+		if errors.Is(err, service.ErrOAuthClientNotFound) {
+			writeResponse(w, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		writeResponse(w, http.StatusInternalServerError, "删除失败", nil)
+		return
+	}
+	writeResponse(w, http.StatusOK, "客户端已删除", nil)
+}
+
+// sendAuthorizeError 客户端与 redirect_uri 已确认时重定向回客户端，否则直接返回错误，避免开放重定向
+func (h *OIDCHandler) sendAuthorizeError(w http.ResponseWriter, r *http.Request, err error) {
+	var oe *service.OAuthError
+	if !errors.As(err, &oe) {
+		logger.Log.Error("OIDC 授权请求校验失败", zap.Error(err))
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+	if oe.RedirectURI != "" {
+		http.Redirect(w, r, oe.RedirectURL(), http.StatusFound)
+		return
+	}
+	writeOAuthError(w, http.StatusBadRequest, oe.Code, oe.Description)
+}
+
+func authorizeRequestFromQuery(q url.Values) *service.AuthorizeRequest {
+	return &service.AuthorizeRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		Nonce:               q.Get("nonce"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	}
+}
+
+// writeOAuthJSON 协议端点响应，禁止缓存 Token
+func writeOAuthJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeOAuthError(w http.ResponseWriter, code int, errCode, desc string) {
+	writeOAuthJSON(w, code, map[string]string{
+		"error":             errCode,
+		"error_description": desc,
+	})
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+// ListSessions 登录设备列表 (GET /api/v1/sessions)
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, _ := utils.ClaimsFromContext(r.Context())
+
+	sessions, err := h.svc.ListSessions(r.Context(), claims.UserID(), claims.SessionID)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "success", sessions)
+}
+
+// RevokeSession 下线指定设备 (DELETE /api/v1/sessions/{id})
+// 也可以移除当前会话，效果等同于退出登录
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	id := r.PathValue("id")
+	if id == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.RevokeSession(r.Context(), userID, id); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			h.sendJSON(w, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "设备已下线", nil)
+}
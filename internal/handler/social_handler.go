@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/service"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+// SocialHandler 第三方账号登录与绑定接口
+type SocialHandler struct {
+	svc *service.SocialService
+}
+
+func NewSocialHandler(svc *service.SocialService) *SocialHandler {
+	return &SocialHandler{svc: svc}
+}
+
+// Providers 可用的第三方登录方式 (GET /api/v1/oauth/social/providers)
+func (h *SocialHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, http.StatusOK, "success", h.svc.Providers())
+}
+
+// Start 发起第三方登录 (GET /api/v1/oauth/social/{provider}/start)，返回提供方授权地址，由前端完成跳转
+func (h *SocialHandler) Start(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, 0)
+}
+
+// Link 已登录用户绑定第三方账号 (POST /api/v1/identities/{provider})，回调走 LinkCallback 接口
+func (h *SocialHandler) Link(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+	h.start(w, r, userID)
+}
+
+func (h *SocialHandler) start(w http.ResponseWriter, r *http.Request, linkUserID int) {
+	authURL, err := h.svc.Start(r.Context(), r.PathValue("provider"), linkUserID)
+	if err != nil {
+		sendSocialError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, "success", map[string]string{"auth_url": authURL})
+}
+
+// Callback 提供方回跳前端后，由前端提交 code 与 state (POST /api/v1/oauth/social/{provider}/callback)
+// 返回 Token 对（或二次验证凭证）
+func (h *SocialHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code   string `json:"code"`
+		State  string `json:"state"`
+		Device string `json:"device"` // 可选，显示在登录设备列表中
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" || req.State == "" {
+		writeResponse(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	client := clientInfo(r)
+	client.Device = req.Device
+	result, err := h.svc.Callback(r.Context(), r.PathValue("provider"), req.Code, req.State, client)
+	if err != nil {
+		sendSocialError(w, err)
+		return
+	}
+	if result.MFARequired {
+		writeResponse(w, http.StatusOK, "需要二次验证", result)
+		return
+	}
+	writeResponse(w, http.StatusOK, "登录成功", result)
+}
+
+// LinkCallback 绑定回调 (POST /api/v1/identities/{provider}/callback)，需以发起绑定的同一账号登录
+func (h *SocialHandler) LinkCallback(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	var req struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" || req.State == "" {
+		writeResponse(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.LinkCallback(r.Context(), userID, r.PathValue("provider"), req.Code, req.State); err != nil {
+		sendSocialError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, "绑定成功", nil)
+}
+
+// ListIdentities 已绑定的第三方账号 (GET /api/v1/identities)
+func (h *SocialHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	list, err := h.svc.ListIdentities(r.Context(), userID)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeResponse(w, http.StatusOK, "success", list)
+}
+
+// Unlink 解绑第三方账号 (DELETE /api/v1/identities/{provider})
+func (h *SocialHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	if err := h.svc.Unlink(r.Context(), userID, r.PathValue("provider")); err != nil {
+		sendSocialError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, "已解绑", nil)
+}
+
+func sendSocialError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrProviderNotFound), errors.Is(err, service.ErrIdentityNotFound):
+		writeResponse(w, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, service.ErrSocialStateInvalid), errors.Is(err, service.ErrSocialNoEmail):
+		writeResponse(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrSocialAuthFailed):
+		writeResponse(w, http.StatusUnauthorized, err.Error(), nil)
+	case errors.Is(err, service.ErrIdentityLinked), errors.Is(err, service.ErrProviderLinked),
+		errors.Is(err, service.ErrSocialEmailInUse), errors.Is(err, service.ErrLastLoginMethod):
+		writeResponse(w, http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, service.ErrAccountSuspended), errors.Is(err, service.ErrEmailNotVerified),
+		errors.Is(err, service.ErrImpersonationForbidden):
+		writeResponse(w, http.StatusForbidden, err.Error(), nil)
+	default:
+		writeResponse(w, http.StatusInternalServerError, err.Error(), nil)
+	}
+}
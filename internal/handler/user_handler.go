@@ -2,12 +2,15 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/netkey/golang-user-mysql-redis/internal/middleware"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
 	"github.com/netkey/golang-user-mysql-redis/internal/service"
 )
 
@@ -42,6 +45,10 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	// 调用 Service 层注册逻辑（包含密码哈希）
 	err := h.svc.Register(r.Context(), req.Name, req.Nickname, req.Email, req.Password)
+	if errors.Is(err, utils.ErrWeakPassword) {
+		h.sendJSON(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
 	if err != nil {
 		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
 		return
@@ -55,6 +62,7 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Device   string `json:"device"` // 可选，显示在登录设备列表中
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -62,19 +70,185 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.svc.Login(r.Context(), req.Email, req.Password)
+	client := clientInfo(r)
+	client.Device = req.Device
+	result, err := h.svc.Login(r.Context(), req.Email, req.Password, client)
 	if err != nil {
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+			h.sendJSON(w, http.StatusTooManyRequests, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) || errors.Is(err, service.ErrAccountSuspended) {
+			h.sendJSON(w, http.StatusForbidden, err.Error(), nil)
+			return
+		}
 		h.sendJSON(w, http.StatusUnauthorized, err.Error(), nil)
 		return
 	}
 
-	h.sendJSON(w, http.StatusOK, "登录成功", map[string]string{"token": token})
+	if result.MFARequired {
+		h.sendJSON(w, http.StatusOK, "请输入二次验证码", result)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, "登录成功", result)
+}
+
+// VerifyEmail 邮箱验证 (GET /api/v1/email/verify?token=xxx)，由验证邮件中的链接访问
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.VerifyEmail(r.Context(), token); err != nil {
+		if errors.Is(err, service.ErrVerifyLinkInvalid) {
+			h.sendJSON(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		h.sendJSON(w, http.StatusInternalServerError, "验证失败", nil)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "邮箱验证成功", nil)
+}
+
+// ResendVerification 重发验证邮件 (POST /api/v1/email/resend)
+func (h *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.ResendVerificationEmail(r.Context(), req.Email); err != nil {
+		if errors.Is(err, service.ErrResendTooFrequent) {
+			h.sendJSON(w, http.StatusTooManyRequests, err.Error(), nil)
+			return
+		}
+		h.sendJSON(w, http.StatusInternalServerError, "发送失败", nil)
+		return
+	}
+
+	// 无论邮箱是否存在都返回相同结果
+	h.sendJSON(w, http.StatusOK, "如果该邮箱已注册且未验证，验证邮件已发送", nil)
+}
+
+// ForgotPassword 找回密码 (POST /api/v1/password/forgot)
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.ForgotPassword(r.Context(), req.Email); err != nil {
+		if errors.Is(err, service.ErrResendTooFrequent) {
+			h.sendJSON(w, http.StatusTooManyRequests, err.Error(), nil)
+			return
+		}
+		h.sendJSON(w, http.StatusInternalServerError, "发送失败", nil)
+		return
+	}
+
+	// 无论邮箱是否存在都返回相同结果
+	h.sendJSON(w, http.StatusOK, "如果该邮箱已注册，重置邮件已发送", nil)
+}
+
+// ResetPassword 重置密码 (POST /api/v1/password/reset)
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.NewPassword == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	if err := h.svc.ResetPassword(r.Context(), req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, service.ErrResetLinkInvalid) || errors.Is(err, utils.ErrWeakPassword) {
+			h.sendJSON(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		h.sendJSON(w, http.StatusInternalServerError, "重置失败", nil)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "密码已重置，请重新登录", nil)
+}
+
+// ChangePassword 修改密码 (POST /api/v1/password/change)
+// 成功后其他设备全部下线，返回当前设备使用的新 Token 对
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.UserIDFromContext(r.Context())
+
+	var req struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OldPassword == "" || req.NewPassword == "" {
+		h.sendJSON(w, http.StatusBadRequest, "无效的请求参数", nil)
+		return
+	}
+
+	pair, err := h.svc.ChangePassword(r.Context(), userID, req.OldPassword, req.NewPassword, clientInfo(r))
+	if err != nil {
+		if errors.Is(err, service.ErrWrongPassword) || errors.Is(err, utils.ErrWeakPassword) {
+			h.sendJSON(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		h.sendJSON(w, http.StatusInternalServerError, "修改失败", nil)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "密码修改成功", pair)
+}
+
+// Logout 退出登录 (POST /api/v1/logout)
+// 请求体 {"all": true} 时退出所有设备
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		All bool `json:"all"`
+	}
+	// 请求体可选，解析失败按仅退出当前设备处理
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	claims, ok := utils.ClaimsFromContext(r.Context())
+	if !ok {
+		h.sendJSON(w, http.StatusUnauthorized, "请先登录", nil)
+		return
+	}
+
+	var err error
+	if req.All {
+		// 模拟登录只能结束自身，不能让用户所有设备下线
+		if claims.Impersonated() {
+			h.sendJSON(w, http.StatusForbidden, service.ErrImpersonationForbidden.Error(), nil)
+			return
+		}
+		err = h.svc.LogoutAll(r.Context(), claims.UserID())
+	} else {
+		err = h.svc.Logout(r.Context(), claims)
+	}
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, "已退出登录", nil)
 }
 
 // GetProfile 获取个人资料-个人中心 (GET /api/v1/me)
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	// 1. 获取用户 ID
-	userID, ok := r.Context().Value("userID").(int)
+	userID, ok := utils.UserIDFromContext(r.Context())
 	if !ok {
 		h.sendJSON(w, http.StatusUnauthorized, "请先登录", nil)
 		return
@@ -125,13 +299,7 @@ func (h *UserHandler) GetPublicProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 3. 构造公开资料的脱敏模型 (安全第一，不返回 email, status 等)
-	publicInfo := map[string]interface{}{
-		"id":       user.ID,
-		"name":     user.Name,
-		"nickname": user.Nickname,
-		"avatar":   user.Avatar,
-		"age":      user.Age,
-	}
+	publicInfo := publicProfile(user)
 
 	// 4. 计算指纹 (ETag)
 	etag := fmt.Sprintf(`W/"pub-%d-%d"`, user.ID, user.UpdatedAt.Unix())
@@ -153,9 +321,52 @@ func (h *UserHandler) GetPublicProfile(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, http.StatusOK, "success", publicInfo)
 }
 
+// BatchGetUsers 批量获取公开资料 (GET /api/v1/users?ids=1,2,3)，按 ids 顺序返回，不存在的用户跳过
+func (h *UserHandler) BatchGetUsers(w http.ResponseWriter, r *http.Request) {
+	var ids []int
+	for _, s := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, "无效的用户 ID", nil)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	users, err := h.svc.BatchGetUsers(r.Context(), ids)
+	if errors.Is(err, service.ErrTooManyIDs) {
+		h.sendJSON(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, "查询失败", nil)
+		return
+	}
+
+	list := make([]map[string]interface{}, 0, len(users))
+	for i := range users {
+		list = append(list, publicProfile(&users[i]))
+	}
+	h.sendJSON(w, http.StatusOK, "success", list)
+}
+
+// publicProfile 公开资料的脱敏字段
+func publicProfile(user *model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       user.ID,
+		"name":     user.Name,
+		"nickname": user.Nickname,
+		"avatar":   user.Avatar,
+		"age":      user.Age,
+	}
+}
+
 // UpdateProfile 更新资料 (POST /api/v1/profile/update)
 func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
-	userID, _ := r.Context().Value("userID").(int)
+	userID, _ := utils.UserIDFromContext(r.Context())
 
 	var req struct {
 		Nickname string `json:"nickname"`
@@ -179,7 +390,7 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 
 // AddFriend 添加好友 (POST /api/v1/friend/add)
 func (h *UserHandler) AddFriend(w http.ResponseWriter, r *http.Request) {
-	userID, _ := r.Context().Value("userID").(int)
+	userID, _ := utils.UserIDFromContext(r.Context())
 
 	var req struct {
 		FriendID int `json:"friend_id"`
@@ -196,7 +407,7 @@ func (h *UserHandler) AddFriend(w http.ResponseWriter, r *http.Request) {
 
 // ListFriends 好友列表 (GET /api/v1/friends)
 func (h *UserHandler) ListFriends(w http.ResponseWriter, r *http.Request) {
-	userID, _ := r.Context().Value("userID").(int)
+	userID, _ := utils.UserIDFromContext(r.Context())
 
 	friends, err := h.svc.ListFriends(r.Context(), userID)
 	if err != nil {
@@ -207,8 +418,21 @@ func (h *UserHandler) ListFriends(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, http.StatusOK, "success", friends)
 }
 
+// clientInfo 提取客户端 IP 与 User-Agent（设备名由各接口按需从请求体补充）
+func clientInfo(r *http.Request) service.ClientInfo {
+	return service.ClientInfo{
+		IP:        middleware.GetClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+}
+
 // sendJSON 内部辅助方法，减少重复代码
 func (h *UserHandler) sendJSON(w http.ResponseWriter, code int, msg string, data interface{}) {
+	writeResponse(w, code, msg, data)
+}
+
+// writeResponse 以统一的 Response 结构输出 JSON
+func writeResponse(w http.ResponseWriter, code int, msg string, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(Response{
@@ -222,40 +446,28 @@ type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// RefreshToken 刷新 Token (POST /api/v1/refresh)
+// Refresh Token 一次性有效，每次调用都会轮换出新的 Token 对
 func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	var req RefreshRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
 		h.sendJSON(w, http.StatusBadRequest, "参数错误", nil)
 		return
 	}
 
-	// 1. 解析并验证 Refresh Token
-	token, err := jwt.Parse(req.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(h.svc.GetJWTSecret()), nil // 这里的 Secret 应该从 config 获取
-	})
-
-	if err != nil || !token.Valid {
-		h.sendJSON(w, http.StatusUnauthorized, "Refresh Token 已失效", nil)
-		return
-	}
-
-	// 2. 校验 Token 类型必须是 refresh
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || claims["type"] != utils.TokenTypeRefresh {
-		h.sendJSON(w, http.StatusUnauthorized, "无效的 Token 类型", nil)
-		return
-	}
-
-	// 3. 提取 userID 并生成新的一对 Token
-	userID := int(claims["userID"].(float64))
-	newAccess, newRefresh, err := utils.GenerateTokenPair(userID, h.svc.GetJWTSecret())
+	pair, err := h.svc.RefreshToken(r.Context(), req.RefreshToken)
 	if err != nil {
+		if errors.Is(err, service.ErrRefreshReused) || errors.Is(err, service.ErrTokenRevoked) {
+			h.sendJSON(w, http.StatusUnauthorized, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, utils.ErrInvalidToken) {
+			h.sendJSON(w, http.StatusUnauthorized, "Refresh Token 已失效", nil)
+			return
+		}
 		h.sendJSON(w, http.StatusInternalServerError, "生成失败", nil)
 		return
 	}
 
-	h.sendJSON(w, http.StatusOK, "success", map[string]string{
-		"access_token":  newAccess,
-		"refresh_token": newRefresh,
-	})
+	h.sendJSON(w, http.StatusOK, "success", pair)
 }
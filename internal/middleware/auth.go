@@ -2,46 +2,144 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"slices"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
 )
 
-type contextKey string
-
-const UserIDKey contextKey = "user_id"
+// TokenValidator 校验 Token 签名、iss/aud、类型、有效期以及是否已被吊销
+// 由 service.UserService 实现，HTTP 与 gRPC 鉴权共用
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, tokenString string) (*utils.Claims, error)
+}
 
 // AuthMiddleware 用于 HTTP/GraphQL
-func AuthMiddleware(secret string) func(http.Handler) http.Handler {
+// 校验通过后通过 utils.ContextWithClaims 注入身份，Handler 使用 utils.UserIDFromContext 读取
+// 同时接受个人 API Key：没有 write scope 的 Key 只能调用 GET / HEAD 接口
+func AuthMiddleware(validator TokenValidator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				next.ServeHTTP(w, r)
+			// 格式: Bearer <token>
+			tokenString, ok := utils.ExtractBearer(r.Header.Get("Authorization"))
+			if !ok {
+				writeJSONError(w, http.StatusUnauthorized, "请先登录")
 				return
 			}
 
-			// 格式: Bearer <token>
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				next.ServeHTTP(w, r)
+			claims, err := validator.ValidateToken(r.Context(), tokenString)
+			if err != nil || !isFirstParty(claims) {
+				writeJSONError(w, http.StatusUnauthorized, "登录已失效，请重新登录")
+				return
+			}
+			if claims.Type == utils.TokenTypeAPIKey && !isReadOnlyMethod(r.Method) && !hasScope(claims, model.APIKeyScopeWrite) {
+				writeJSONError(w, http.StatusForbidden, "API Key 没有写权限")
 				return
 			}
 
-			tokenString := parts[1]
-			token, _ := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-				return []byte(secret), nil
-			})
+			ctx := utils.ContextWithClaims(r.Context(), claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-				// 将 userID 注入 Context
-				ctx := context.WithValue(r.Context(), UserIDKey, claims["user_id"])
-				next.ServeHTTP(w, r.WithContext(ctx))
+// OAuthScopeAuth 用于接受 OIDC 客户端 Token 的协议端点 (/oauth/userinfo)，Token 的 scope 必须包含全部 scopes
+// 错误按 RFC 6750 返回 WWW-Authenticate 与 {"error", "error_description"}
+func OAuthScopeAuth(validator TokenValidator, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, ok := utils.ExtractBearer(r.Header.Get("Authorization"))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="oauth"`)
+				writeBearerError(w, http.StatusUnauthorized, "invalid_request", "access token is required")
 				return
 			}
+			claims, err := validator.ValidateToken(r.Context(), tokenString)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeBearerError(w, http.StatusUnauthorized, "invalid_token", "access token is invalid or expired")
+				return
+			}
+			for _, sc := range scopes {
+				if !hasScope(claims, sc) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+					writeBearerError(w, http.StatusForbidden, "insufficient_scope", "scope "+sc+" is required")
+					return
+				}
+			}
+
+			ctx := utils.ContextWithClaims(r.Context(), claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-			next.ServeHTTP(w, r)
+// isFirstParty 只有直接登录签发的 Access Token 与个人 API Key 可以调用用户 API
+// OIDC 客户端 Token (oauth_access) 即使被 validator 接受也在此拒绝，只能访问 OAuthScopeAuth 保护的端点
+func isFirstParty(claims *utils.Claims) bool {
+	return claims.Type == utils.TokenTypeAccess || claims.Type == utils.TokenTypeAPIKey
+}
+
+// SessionOnly 只允许登录会话访问，拒绝 API Key，需放在 AuthMiddleware 之后
+// 用于修改密码、二次验证、API Key 管理等账号安全相关接口，避免 Key 泄露后被用来扩大权限
+func SessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := utils.ClaimsFromContext(r.Context()); ok && claims.Type == utils.TokenTypeAPIKey {
+			writeJSONError(w, http.StatusForbidden, "该操作不支持使用 API Key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServiceAuth 只允许内部服务调用 (服务间 Token，与 gRPC PolicyService 相同)
+// 校验通过后通过 utils.ContextWithService 注入调用方服务名
+func ServiceAuth(services ServiceTokenVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, ok := utils.ExtractBearer(r.Header.Get("Authorization"))
+			if !ok {
+				writeJSONError(w, http.StatusUnauthorized, "缺少服务凭证")
+				return
+			}
+			name, err := services.VerifyServiceToken(tokenString)
+			if err != nil {
+				writeJSONError(w, http.StatusUnauthorized, "服务凭证无效")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(utils.ContextWithService(r.Context(), name)))
 		})
 	}
 }
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func hasScope(claims *utils.Claims, scope string) bool {
+	return slices.Contains(strings.Fields(claims.Scope), scope)
+}
+
+// writeBearerError OAuth2 协议端点的错误结构，与 handler.writeOAuthError 一致
+func writeBearerError(w http.ResponseWriter, code int, errCode, desc string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             errCode,
+		"error_description": desc,
+	})
+}
+
+// writeJSONError 与 handler.Response 保持一致的错误返回结构
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code": code,
+		"msg":  msg,
+	})
+}
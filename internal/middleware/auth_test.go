@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+// fakeValidator 只接受其中登记的 Token
+type fakeValidator map[string]*utils.Claims
+
+func (v fakeValidator) ValidateToken(_ context.Context, token string) (*utils.Claims, error) {
+	if c, ok := v[token]; ok {
+		return c, nil
+	}
+	return nil, utils.ErrInvalidToken
+}
+
+func userClaims(sub, typ string) *utils.Claims {
+	return &utils.Claims{Type: typ, RegisteredClaims: jwt.RegisteredClaims{Subject: sub, ID: "jti-" + sub}}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	delegated := userClaims("7", utils.TokenTypeOAuthAccess)
+	delegated.Scope = "openid profile"
+	validator := fakeValidator{"good": userClaims("7", utils.TokenTypeAccess), "delegated": delegated}
+	var gotUser int
+	h := AuthMiddleware(validator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = utils.UserIDFromContext(r.Context())
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		code   int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"not bearer", "Basic good", http.StatusUnauthorized},
+		{"invalid", "Bearer bad", http.StatusUnauthorized},
+		{"oidc client token", "Bearer delegated", http.StatusUnauthorized},
+		{"valid", "Bearer good", http.StatusOK},
+	}
+	for _, tc := range cases {
+		gotUser = 0
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+		if tc.header != "" {
+			r.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.code)
+		}
+		if tc.code == http.StatusOK && gotUser != 7 {
+			t.Errorf("%s: handler saw user %d, want 7", tc.name, gotUser)
+		}
+		if tc.code != http.StatusOK && gotUser != 0 {
+			t.Errorf("%s: handler ran for rejected request", tc.name)
+		}
+	}
+}
+
+func TestOAuthScopeAuth(t *testing.T) {
+	withScope := func(scope string) *utils.Claims {
+		c := userClaims("7", utils.TokenTypeOAuthAccess)
+		c.Scope = scope
+		return c
+	}
+	validator := fakeValidator{
+		"openid":  withScope("openid email"),
+		"profile": withScope("profile"),
+	}
+	var gotUser int
+	h := OAuthScopeAuth(validator, "openid")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = utils.UserIDFromContext(r.Context())
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		code   int
+		errStr string
+	}{
+		{"missing", "", http.StatusUnauthorized, ""},
+		{"invalid", "Bearer bad", http.StatusUnauthorized, `error="invalid_token"`},
+		{"missing scope", "Bearer profile", http.StatusForbidden, `error="insufficient_scope"`},
+		{"valid", "Bearer openid", http.StatusOK, ""},
+	}
+	for _, tc := range cases {
+		gotUser = 0
+		r := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+		if tc.header != "" {
+			r.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.code)
+		}
+		if got := w.Header().Get("WWW-Authenticate"); !strings.Contains(got, tc.errStr) {
+			t.Errorf("%s: WWW-Authenticate %q, want %s", tc.name, got, tc.errStr)
+		}
+		if (tc.code == http.StatusOK) != (gotUser == 7) {
+			t.Errorf("%s: handler saw user %d", tc.name, gotUser)
+		}
+	}
+}
+
+func TestAuthMiddlewareAPIKey(t *testing.T) {
+	readOnly := userClaims("7", utils.TokenTypeAPIKey)
+	readOnly.Scope = "read"
+	readWrite := userClaims("7", utils.TokenTypeAPIKey)
+	readWrite.Scope = "read write"
+	validator := fakeValidator{"session": userClaims("7", utils.TokenTypeAccess), "uk_read": readOnly, "uk_write": readWrite}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := AuthMiddleware(validator)(ok)
+	sessionOnly := AuthMiddleware(validator)(SessionOnly(ok))
+
+	cases := []struct {
+		name    string
+		handler http.Handler
+		method  string
+		token   string
+		code    int
+	}{
+		{"read key GET", h, http.MethodGet, "uk_read", http.StatusOK},
+		{"read key PUT", h, http.MethodPut, "uk_read", http.StatusForbidden},
+		{"write key PUT", h, http.MethodPut, "uk_write", http.StatusOK},
+		{"key on session-only route", sessionOnly, http.MethodGet, "uk_write", http.StatusForbidden},
+		{"session on session-only route", sessionOnly, http.MethodPost, "session", http.StatusOK},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(tc.method, "/api/v1/me", nil)
+		r.Header.Set("Authorization", "Bearer "+tc.token)
+		w := httptest.NewRecorder()
+		tc.handler.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.code)
+		}
+	}
+}
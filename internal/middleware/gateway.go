@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// REST 网关转发请求时写入的 metadata，携带真实客户端信息
+// 网关丢弃客户端请求头中以 GatewayMetadataPrefix 开头的 metadata (handler.NewGateway)
+const (
+	GatewayMetadataPrefix = "x-gateway-"
+	GatewayClientIPKey    = GatewayMetadataPrefix + "client-ip"
+	GatewayUserAgentKey   = GatewayMetadataPrefix + "user-agent"
+	gatewaySecretKey      = GatewayMetadataPrefix + "secret"
+)
+
+// gatewaySecret 进程启动时随机生成，只有本进程内的网关知道；携带正确密钥的 metadata 才被视为网关写入
+var gatewaySecret = func() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}()
+
+// GatewayMetadata 网关转发 r 时附加的 metadata：真实客户端信息与本进程密钥
+func GatewayMetadata(r *http.Request) metadata.MD {
+	return metadata.Pairs(
+		gatewaySecretKey, gatewaySecret,
+		GatewayClientIPKey, GetClientIP(r),
+		GatewayUserAgentKey, r.UserAgent(),
+	)
+}
+
+// GrpcUserAgent 调用方 User-Agent，经 REST 网关转发的请求取 HTTP 请求的 User-Agent
+func GrpcUserAgent(ctx context.Context) string {
+	if ua := gatewayMetadata(ctx, GatewayUserAgentKey); ua != "" {
+		return ua
+	}
+	if ua := metadata.ValueFromIncomingContext(ctx, "user-agent"); len(ua) > 0 {
+		return ua[0]
+	}
+	return ""
+}
+
+// gatewayMetadata 只信任携带本进程密钥的 metadata，直连 gRPC 的调用方无法借此伪造客户端信息
+// 网关写入的值排在客户端请求头转换的 metadata 之后，取最后一个值
+func gatewayMetadata(ctx context.Context, key string) string {
+	secret := metadata.ValueFromIncomingContext(ctx, gatewaySecretKey)
+	if len(secret) == 0 || subtle.ConstantTimeCompare([]byte(secret[len(secret)-1]), []byte(gatewaySecret)) != 1 {
+		return ""
+	}
+	if v := metadata.ValueFromIncomingContext(ctx, key); len(v) > 0 {
+		return v[len(v)-1]
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestGatewayMetadataRequiresSecret(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v2/users/7", nil)
+	r.RemoteAddr = "203.0.113.9:5000"
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	gw := GatewayMetadata(r)
+
+	cases := map[string]struct {
+		md     metadata.MD
+		ip, ua string
+	}{
+		// 同一台机器上直连 gRPC 端口的调用方也拿不到密钥
+		"no secret":    {metadata.Pairs(GatewayClientIPKey, "10.0.0.1", GatewayUserAgentKey, "forged"), "127.0.0.1", ""},
+		"wrong secret": {metadata.Pairs(gatewaySecretKey, "guess", GatewayClientIPKey, "10.0.0.1"), "127.0.0.1", ""},
+		"gateway":      {gw, "203.0.113.9", "Mozilla/5.0"},
+		// 客户端请求头转换的 metadata 在前，网关写入的值在后
+		"forged before gateway": {metadata.Join(metadata.Pairs(GatewayClientIPKey, "10.0.0.1"), gw), "203.0.113.9", "Mozilla/5.0"},
+	}
+	for name, tc := range cases {
+		ctx := peer.NewContext(metadata.NewIncomingContext(context.Background(), tc.md), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}})
+		if got := GrpcPeerIP(ctx); got != tc.ip {
+			t.Errorf("%s: GrpcPeerIP = %q, want %q", name, got, tc.ip)
+		}
+		if got := GrpcUserAgent(ctx); got != tc.ua {
+			t.Errorf("%s: GrpcUserAgent = %q, want %q", name, got, tc.ua)
+		}
+	}
+}
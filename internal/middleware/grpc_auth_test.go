@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServices 服务间 Token 即服务名前加 "svc-"；certServices 为可凭证书认证的服务
+type fakeServices struct {
+	certServices map[string]bool
+}
+
+func (fakeServices) VerifyServiceToken(token string) (string, error) {
+	if len(token) > 4 && token[:4] == "svc-" {
+		return token[4:], nil
+	}
+	return "", utils.ErrInvalidToken
+}
+
+func (s fakeServices) TrustsCertService(name string) bool {
+	return s.certServices[name]
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestGrpcAuthenticatePolicies(t *testing.T) {
+	validator := fakeValidator{
+		"user-token": userClaims("7", utils.TokenTypeAccess),
+		"oidc-token": userClaims("7", utils.TokenTypeOAuthAccess),
+	}
+	policy := MethodPolicy{
+		Methods: map[string]AuthPolicy{
+			"/svc/Public":  PolicyPublic,
+			"/svc/Service": PolicyService,
+			"/svc/Any":     PolicyAny,
+		},
+	}
+
+	cases := []struct {
+		method  string
+		ctx     context.Context
+		code    codes.Code
+		user    int
+		service string
+	}{
+		{"/svc/Public", context.Background(), codes.OK, 0, ""},
+		{"/svc/User", context.Background(), codes.Unauthenticated, 0, ""}, // 未配置的方法默认要求用户登录
+		{"/svc/User", withToken("user-token"), codes.OK, 7, ""},
+		{"/svc/User", withToken("svc-order-service"), codes.Unauthenticated, 0, ""},
+		{"/svc/User", withToken("forged"), codes.Unauthenticated, 0, ""},
+		{"/svc/User", withToken("oidc-token"), codes.Unauthenticated, 0, ""}, // OIDC 客户端 Token 不能调用用户 API
+		{"/svc/Service", withToken("svc-order-service"), codes.OK, 0, "order-service"},
+		{"/svc/Service", withToken("user-token"), codes.Unauthenticated, 0, ""},
+		{"/svc/Any", withToken("user-token"), codes.OK, 7, ""},
+		{"/svc/Any", withToken("svc-order-service"), codes.OK, 0, "order-service"},
+	}
+	for _, tc := range cases {
+		ctx, err := grpcAuthenticate(tc.ctx, tc.method, validator, fakeServices{}, policy)
+		if got := status.Code(err); got != tc.code {
+			t.Errorf("%s: code %v, want %v", tc.method, got, tc.code)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if id, _ := utils.UserIDFromContext(ctx); id != tc.user {
+			t.Errorf("%s: user %d, want %d", tc.method, id, tc.user)
+		}
+		if name, _ := utils.ServiceFromContext(ctx); name != tc.service {
+			t.Errorf("%s: service %q, want %q", tc.method, name, tc.service)
+		}
+	}
+}
+
+// fakeClients client_credentials Token 即 client_id 前加 "client-"
+type fakeClients struct{}
+
+func (fakeClients) VerifyServiceToken(token string) (string, error) {
+	if len(token) > 7 && token[:7] == "client-" {
+		return token[7:], nil
+	}
+	return "", utils.ErrInvalidToken
+}
+
+func TestGrpcAuthenticateServiceVerifiers(t *testing.T) {
+	services := ServiceVerifiers{fakeServices{}, fakeClients{}}
+	policy := MethodPolicy{Methods: map[string]AuthPolicy{"/svc/Service": PolicyService}}
+
+	for token, want := range map[string]string{"svc-order-service": "order-service", "client-reporting": "reporting"} {
+		ctx, err := grpcAuthenticate(withToken(token), "/svc/Service", fakeValidator{}, services, policy)
+		if err != nil {
+			t.Fatalf("%s: %v", token, err)
+		}
+		if name, _ := utils.ServiceFromContext(ctx); name != want {
+			t.Errorf("%s: service %q, want %q", token, name, want)
+		}
+	}
+	if _, err := grpcAuthenticate(withToken("forged"), "/svc/Service", fakeValidator{}, services, policy); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("forged: got %v", err)
+	}
+}
+
+func TestGrpcAuthenticateAPIKeyWriteScope(t *testing.T) {
+	readOnly := userClaims("7", utils.TokenTypeAPIKey)
+	readOnly.Scope = "read"
+	readWrite := userClaims("8", utils.TokenTypeAPIKey)
+	readWrite.Scope = "read write"
+	validator := fakeValidator{"uk_read": readOnly, "uk_write": readWrite}
+	policy := MethodPolicy{Writes: map[string]bool{"/svc/Update": true}}
+
+	if _, err := grpcAuthenticate(withToken("uk_read"), "/svc/Get", validator, nil, policy); err != nil {
+		t.Fatalf("read-only key on read method: %v", err)
+	}
+	if _, err := grpcAuthenticate(withToken("uk_read"), "/svc/Update", validator, nil, policy); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("read-only key on write method: %v", err)
+	}
+	if _, err := grpcAuthenticate(withToken("uk_write"), "/svc/Update", validator, nil, policy); err != nil {
+		t.Fatalf("write key on write method: %v", err)
+	}
+}
+
+// withPeerCert 模拟 mTLS 连接：verified 为 false 时证书未通过 CA 校验
+func withPeerCert(ctx context.Context, cn string, verified bool) context.Context {
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: cn}}}}
+	if verified {
+		state.VerifiedChains = [][]*x509.Certificate{state.PeerCertificates}
+	}
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 5000}, AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+func TestGrpcAuthenticateCertService(t *testing.T) {
+	validator := fakeValidator{"user-token": userClaims("7", utils.TokenTypeAccess)}
+	// 与生产环境一致：证书名单来自 ServiceKeySet，OIDC 客户端凭证不参与证书认证
+	services := ServiceVerifiers{fakeServices{certServices: map[string]bool{"order-service": true}}, fakeClients{}}
+	policy := MethodPolicy{Methods: map[string]AuthPolicy{"/svc/Service": PolicyService, "/svc/Any": PolicyAny}}
+
+	cases := []struct {
+		name    string
+		method  string
+		ctx     context.Context
+		code    codes.Code
+		service string
+	}{
+		{"trusted cert without token", "/svc/Service", withPeerCert(context.Background(), "order-service", true), codes.OK, "order-service"},
+		{"trusted cert on any", "/svc/Any", withPeerCert(context.Background(), "order-service", true), codes.OK, "order-service"},
+		{"cert does not replace user token", "/svc/User", withPeerCert(context.Background(), "order-service", true), codes.Unauthenticated, ""},
+		{"unverified cert", "/svc/Service", withPeerCert(context.Background(), "order-service", false), codes.Unauthenticated, ""},
+		{"untrusted cert", "/svc/Service", withPeerCert(context.Background(), "report-service", true), codes.Unauthenticated, ""},
+		{"matching token", "/svc/Service", withPeerCert(withToken("svc-order-service"), "order-service", true), codes.OK, "order-service"},
+		{"token for another service", "/svc/Service", withPeerCert(withToken("svc-report-service"), "order-service", true), codes.Unauthenticated, ""},
+		{"untrusted cert with token", "/svc/Service", withPeerCert(withToken("svc-report-service"), "report-service", true), codes.OK, "report-service"},
+	}
+	for _, tc := range cases {
+		ctx, err := grpcAuthenticate(tc.ctx, tc.method, validator, services, policy)
+		if got := status.Code(err); got != tc.code {
+			t.Errorf("%s: code %v, want %v (%v)", tc.name, got, tc.code, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if name, _ := utils.ServiceFromContext(ctx); name != tc.service {
+			t.Errorf("%s: service %q, want %q", tc.name, name, tc.service)
+		}
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"time"
 
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
 	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -33,24 +37,182 @@ func GrpcLoggingInterceptor(ctx context.Context, req interface{}, info *grpc.Una
 	return resp, err
 }
 
-// 2. GrpcAuthInterceptor: 简单的 gRPC 鉴权 (从 Metadata 中提取 token)
-func GrpcAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	// 获取 gRPC 元数据 (类似 HTTP Header)
-	md, ok := metadata.FromIncomingContext(ctx)
+// GrpcStreamLoggingInterceptor 流式 RPC 在流结束时记录一次，duration 为整个流的持续时间
+func GrpcStreamLoggingInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	st, _ := status.FromError(err)
+
+	logger.Log.Info("gRPC Stream",
+		zap.String("method", info.FullMethod),
+		zap.Duration("duration", time.Since(start)),
+		zap.String("code", st.Code().String()),
+		zap.Error(err),
+	)
+	return err
+}
+
+// AuthPolicy gRPC 方法的鉴权策略
+type AuthPolicy string
+
+const (
+	PolicyPublic  AuthPolicy = "public"  // 无需鉴权
+	PolicyUser    AuthPolicy = "user"    // 终端用户 Access Token
+	PolicyService AuthPolicy = "service" // 内部服务间 Token (如 OrderService)
+	PolicyAny     AuthPolicy = "any"     // 用户或服务 Token 均可
+)
+
+// MethodPolicy 按 FullMethod 配置鉴权策略，未配置的方法使用 Default
+type MethodPolicy struct {
+	Default AuthPolicy
+	Methods map[string]AuthPolicy
+	// Writes 修改数据的方法，使用 API Key 调用时要求 write scope
+	Writes map[string]bool
+}
+
+func (p MethodPolicy) For(fullMethod string) AuthPolicy {
+	if policy, ok := p.Methods[fullMethod]; ok {
+		return policy
+	}
+	if p.Default == "" {
+		return PolicyUser // 默认最严格：要求用户登录
+	}
+	return p.Default
+}
+
+// ServiceTokenVerifier 校验服务间 Token，返回调用方服务名
+// 由 utils.ServiceKeySet (服务私钥签名) 与 service.OIDCService (client_credentials Token) 实现
+type ServiceTokenVerifier interface {
+	VerifyServiceToken(tokenString string) (string, error)
+}
+
+// CertServiceTruster 可凭 mTLS 客户端证书直接认证的服务名单 (由 utils.ServiceKeySet 实现)
+type CertServiceTruster interface {
+	TrustsCertService(name string) bool
+}
+
+// PeerCertIdentity 调用方 mTLS 客户端证书中的身份 (Subject CN)，仅在证书已通过 CA 校验时返回
+func PeerCertIdentity(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
 	if !ok {
-		return nil, status.Errorf(codes.Unauthenticated, "metadata is not provided")
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return "", false
+	}
+	cn := info.State.VerifiedChains[0][0].Subject.CommonName
+	return cn, cn != ""
+}
+
+// ServiceVerifiers 依次尝试多种服务凭证，任一校验通过即可
+type ServiceVerifiers []ServiceTokenVerifier
+
+func (v ServiceVerifiers) VerifyServiceToken(tokenString string) (string, error) {
+	for _, verifier := range v {
+		if name, err := verifier.VerifyServiceToken(tokenString); err == nil {
+			return name, nil
+		}
+	}
+	return "", utils.ErrInvalidToken
+}
+
+// TrustsCertService 任一成员信任该证书身份即可
+func (v ServiceVerifiers) TrustsCertService(name string) bool {
+	for _, verifier := range v {
+		if t, ok := verifier.(CertServiceTruster); ok && t.TrustsCertService(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// 2. GrpcAuthInterceptor: gRPC 鉴权
+// 与 HTTP AuthMiddleware 共用 TokenValidator；按方法策略区分公开、用户、服务间调用
+func GrpcAuthInterceptor(validator TokenValidator, services ServiceTokenVerifier, policy MethodPolicy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := grpcAuthenticate(ctx, info.FullMethod, validator, services, policy)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
 	}
+}
 
+// GrpcStreamAuthInterceptor 流式 RPC 鉴权，策略与 GrpcAuthInterceptor 相同
+func GrpcStreamAuthInterceptor(validator TokenValidator, services ServiceTokenVerifier, policy MethodPolicy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := grpcAuthenticate(ss.Context(), info.FullMethod, validator, services, policy)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// grpcAuthenticate 校验调用方身份，返回注入了用户 Claims 或服务名的 ctx
+func grpcAuthenticate(ctx context.Context, fullMethod string, validator TokenValidator, services ServiceTokenVerifier, policy MethodPolicy) (context.Context, error) {
+	p := policy.For(fullMethod)
+	if p == PolicyPublic {
+		return ctx, nil
+	}
+
+	// mTLS 客户端证书身份：列入 grpc_auth.cert_services 的服务可不携带 Token
+	certService := ""
+	if t, ok := services.(CertServiceTruster); ok {
+		if id, ok := PeerCertIdentity(ctx); ok && t.TrustsCertService(id) {
+			certService = id
+		}
+	}
+
+	// 获取 gRPC 元数据 (类似 HTTP Header)
+	md, _ := metadata.FromIncomingContext(ctx)
 	tokens := md.Get("authorization")
 	if len(tokens) == 0 {
+		if certService != "" && (p == PolicyService || p == PolicyAny) {
+			return utils.ContextWithService(ctx, certService), nil
+		}
 		return nil, status.Errorf(codes.Unauthenticated, "authorization token is not provided")
 	}
 
-	// 这里可以添加 JWT 校验逻辑 (类似 HTTP 中间件)
-	// token := tokens[0]
-	// ... 校验 ...
+	// 兼容 "Bearer <token>" 与裸 Token 两种写法
+	token := tokens[0]
+	if t, ok := utils.ExtractBearer(token); ok {
+		token = t
+	}
 
-	return handler(ctx, req)
+	// 用户 Token
+	if p == PolicyUser || p == PolicyAny {
+		if claims, err := validator.ValidateToken(ctx, token); err == nil && isFirstParty(claims) {
+			if claims.Type == utils.TokenTypeAPIKey && policy.Writes[fullMethod] && !hasScope(claims, model.APIKeyScopeWrite) {
+				return nil, status.Errorf(codes.PermissionDenied, "api key lacks write scope for %s", fullMethod)
+			}
+			return utils.ContextWithClaims(ctx, claims), nil
+		}
+	}
+
+	// 服务间 Token
+	if (p == PolicyService || p == PolicyAny) && services != nil {
+		if name, err := services.VerifyServiceToken(token); err == nil {
+			// 证书已认证为某个服务时，Token 必须属于同一服务
+			if certService != "" && name != certService {
+				return nil, status.Errorf(codes.Unauthenticated, "service token %q does not match client certificate %q", name, certService)
+			}
+			return utils.ContextWithService(ctx, name), nil
+		}
+	}
+
+	return nil, status.Errorf(codes.Unauthenticated, "invalid token for %s", fullMethod)
+}
+
+// contextStream 替换 ServerStream 的 Context，使流式 Handler 能读取鉴权后注入的身份
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
 }
 
 // 3. GrpcRecoveryInterceptor: 防止单个 Panic 导致整个 Server 崩溃
@@ -63,3 +225,14 @@ func GrpcRecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.Un
 	}()
 	return handler(ctx, req)
 }
+
+// GrpcStreamRecoveryInterceptor 流式 RPC 的 Panic 恢复
+func GrpcStreamRecoveryInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Log.Error("gRPC Panic Recovered", zap.String("method", info.FullMethod), zap.Any("panic", r))
+			err = status.Errorf(codes.Internal, "Internal server error")
+		}
+	}()
+	return handler(srv, ss)
+}
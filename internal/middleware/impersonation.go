@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// AuditRecorder 写入审计日志 (由 service.UserService 实现)
+type AuditRecorder interface {
+	RecordAudit(ctx context.Context, log *model.AuditLog)
+}
+
+// ImpersonationAudit 模拟登录期间的每个 HTTP 请求写入审计日志，需放在 AuthMiddleware 之后
+func ImpersonationAudit(recorder AuditRecorder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := utils.ClaimsFromContext(r.Context())
+			if !ok || !claims.Impersonated() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			recorder.RecordAudit(r.Context(), &model.AuditLog{
+				ActorID:   claims.ActorID(),
+				UserID:    claims.UserID(),
+				Action:    model.AuditHTTPRequest,
+				Target:    r.Method + " " + r.URL.Path,
+				Status:    rec.status,
+				IP:        GetClientIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		})
+	}
+}
+
+// RefuseImpersonation 模拟登录期间拒绝访问，用于修改密码、二次验证等账号安全相关接口
+func RefuseImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := utils.ActorIDFromContext(r.Context()); ok {
+			writeJSONError(w, http.StatusForbidden, "模拟登录期间不能执行该操作")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GrpcImpersonationAuditInterceptor 模拟登录期间的每个 RPC 写入审计日志，需放在 GrpcAuthInterceptor 之后
+func GrpcImpersonationAuditInterceptor(recorder AuditRecorder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		claims, ok := utils.ClaimsFromContext(ctx)
+		if !ok || !claims.Impersonated() {
+			return handler(ctx, req)
+		}
+
+		resp, err := handler(ctx, req)
+		recorder.RecordAudit(ctx, &model.AuditLog{
+			ActorID: claims.ActorID(),
+			UserID:  claims.UserID(),
+			Action:  model.AuditGRPCCall,
+			Target:  info.FullMethod,
+			Status:  int(status.Code(err)),
+			IP:      GrpcPeerIP(ctx),
+		})
+		return resp, err
+	}
+}
+
+// statusRecorder 记录 Handler 写入的状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// GrpcPeerIP gRPC 调用方地址（不含端口），对应 HTTP 的 GetClientIP
+// 经本进程 REST 网关转发的请求取网关传入的客户端 IP
+func GrpcPeerIP(ctx context.Context) string {
+	if ip := gatewayMetadata(ctx, GatewayClientIPKey); ip != "" {
+		return ip
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
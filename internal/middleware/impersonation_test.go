@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// memoryRecorder 收集写入的审计日志
+type memoryRecorder struct {
+	logs []model.AuditLog
+}
+
+func (r *memoryRecorder) RecordAudit(_ context.Context, l *model.AuditLog) {
+	r.logs = append(r.logs, *l)
+}
+
+func impersonatedClaims(userID, actorID string) *utils.Claims {
+	c := userClaims(userID, utils.TokenTypeAccess)
+	c.Actor = &utils.Actor{Subject: actorID}
+	return c
+}
+
+func TestImpersonationAudit(t *testing.T) {
+	rec := &memoryRecorder{}
+	validator := fakeValidator{"user": userClaims("7", utils.TokenTypeAccess), "imp": impersonatedClaims("7", "1")}
+	h := AuthMiddleware(validator)(ImpersonationAudit(rec)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})))
+	serve := func(token string) {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/orders", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("User-Agent", "Mozilla/5.0")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve("user")
+	if len(rec.logs) != 0 {
+		t.Fatalf("audited normal request: %+v", rec.logs)
+	}
+	serve("imp")
+	if len(rec.logs) != 1 {
+		t.Fatalf("audit logs = %+v", rec.logs)
+	}
+	l := rec.logs[0]
+	if l.ActorID != 1 || l.UserID != 7 || l.Action != model.AuditHTTPRequest || l.Target != "POST /api/v1/orders" ||
+		l.Status != http.StatusCreated || l.UserAgent != "Mozilla/5.0" {
+		t.Fatalf("audit = %+v", l)
+	}
+}
+
+func TestRefuseImpersonation(t *testing.T) {
+	validator := fakeValidator{"user": userClaims("7", utils.TokenTypeAccess), "imp": impersonatedClaims("7", "1")}
+	h := AuthMiddleware(validator)(RefuseImpersonation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	for token, want := range map[string]int{"user": http.StatusOK, "imp": http.StatusForbidden} {
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/password", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("%s: status %d, want %d", token, w.Code, want)
+		}
+	}
+}
+
+func TestGrpcImpersonationAuditInterceptor(t *testing.T) {
+	rec := &memoryRecorder{}
+	intercept := GrpcImpersonationAuditInterceptor(rec)
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/GetUser"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 5000}})
+
+	if _, err := intercept(utils.ContextWithClaims(ctx, userClaims("7", utils.TokenTypeAccess)), nil, info, handler); status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v", err)
+	}
+	if len(rec.logs) != 0 {
+		t.Fatalf("audited normal call: %+v", rec.logs)
+	}
+
+	_, _ = intercept(utils.ContextWithClaims(ctx, impersonatedClaims("7", "1")), nil, info, handler)
+	if len(rec.logs) != 1 {
+		t.Fatalf("audit logs = %+v", rec.logs)
+	}
+	l := rec.logs[0]
+	if l.ActorID != 1 || l.UserID != 7 || l.Target != info.FullMethod || l.Status != int(codes.NotFound) || l.IP != "198.51.100.7" {
+		t.Fatalf("audit = %+v", l)
+	}
+}
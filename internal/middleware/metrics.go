@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -19,6 +22,18 @@ var (
 		Help:    "Duration of HTTP requests",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"path"})
+
+	// gRPC 指标按方法统计，method 为 FullMethod (如 /pb.UserService/GetUserByID)，type 为 unary / stream
+	grpcRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of gRPC requests completed on the server, by status code",
+	}, []string{"method", "type", "code"})
+
+	grpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Duration of gRPC requests until completion on the server",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "type"})
 )
 
 func MetricsMiddleware(next http.Handler) http.Handler {
@@ -31,3 +46,24 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 		httpRequestDuration.WithLabelValues(r.URL.Path).Observe(duration)
 	})
 }
+
+// GrpcMetricsInterceptor 记录 unary RPC 的请求数、耗时与状态码，需放在拦截器链最前，鉴权失败与 Panic 也计入
+func GrpcMetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeGrpc(info.FullMethod, "unary", start, err)
+	return resp, err
+}
+
+// GrpcStreamMetricsInterceptor 记录流式 RPC，耗时为整个流的持续时间
+func GrpcStreamMetricsInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	observeGrpc(info.FullMethod, "stream", start, err)
+	return err
+}
+
+func observeGrpc(method, typ string, start time.Time, err error) {
+	grpcRequestsTotal.WithLabelValues(method, typ, status.Code(err).String()).Inc()
+	grpcRequestDuration.WithLabelValues(method, typ).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcMetricsInterceptor(t *testing.T) {
+	logger.Log = zap.NewNop()
+	const method = "/test.Metrics/Unary"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	denied := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.PermissionDenied, "denied")
+	}
+	// 与 main.go 相同的顺序：指标在 Recovery 之外，Panic 计为 Internal
+	panics := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return GrpcRecoveryInterceptor(ctx, req, info, func(context.Context, interface{}) (interface{}, error) { panic("boom") })
+	}
+
+	// 计数器为进程级全局变量，按增量断言
+	want := map[codes.Code]float64{codes.OK: 2, codes.PermissionDenied: 1, codes.Internal: 1}
+	before := make(map[codes.Code]float64)
+	for code := range want {
+		before[code] = testutil.ToFloat64(grpcRequestsTotal.WithLabelValues(method, "unary", code.String()))
+	}
+	for _, h := range []grpc.UnaryHandler{ok, ok, denied, panics} {
+		_, _ = GrpcMetricsInterceptor(context.Background(), nil, info, h)
+	}
+
+	for code, n := range want {
+		if got := testutil.ToFloat64(grpcRequestsTotal.WithLabelValues(method, "unary", code.String())) - before[code]; got != n {
+			t.Errorf("%s count = %v, want %v", code, got, n)
+		}
+	}
+	if n := testutil.CollectAndCount(grpcRequestDuration, "grpc_server_handling_seconds"); n == 0 {
+		t.Error("no duration observed")
+	}
+}
+
+func TestGrpcStreamMetricsInterceptor(t *testing.T) {
+	const method = "/test.Metrics/Stream"
+	info := &grpc.StreamServerInfo{FullMethod: method, IsServerStream: true}
+	stream := grpcRequestsTotal.WithLabelValues(method, "stream", codes.ResourceExhausted.String())
+	before := testutil.ToFloat64(stream)
+	_ = GrpcStreamMetricsInterceptor(nil, nil, info, func(interface{}, grpc.ServerStream) error {
+		return status.Error(codes.ResourceExhausted, "slow consumer")
+	})
+
+	if got := testutil.ToFloat64(stream) - before; got != 1 {
+		t.Errorf("stream count = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(grpcRequestsTotal.WithLabelValues(method, "unary", codes.ResourceExhausted.String())); got != 0 {
+		t.Errorf("stream counted as unary: %v", got)
+	}
+}
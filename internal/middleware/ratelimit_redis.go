@@ -1,50 +1,35 @@
 package middleware
 
 import (
+	"context"
 	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
 	"github.com/redis/go-redis/v9"
-	"net"
 	"net/http"
-	"strings"
 
 	"github.com/go-redis/redis_rate/v10"
 )
 
+// APIKeyLookup 校验 API Key 并返回其配置 (由 service.UserService 实现)
+type APIKeyLookup interface {
+	LookupAPIKey(ctx context.Context, key string) (*model.APIKey, error)
+}
+
 type RedisRateLimiter struct {
 	limiter *redis_rate.Limiter
 	cfg     config.RateLimitConfig
+	keys    APIKeyLookup // 为 nil 时不区分 API Key，全部按 IP 限流
 }
 
-func NewRedisRateLimiter(rdb *redis.Client, cfg config.RateLimitConfig) *RedisRateLimiter {
+func NewRedisRateLimiter(rdb *redis.Client, cfg config.RateLimitConfig, keys APIKeyLookup) *RedisRateLimiter {
 	return &RedisRateLimiter{
 		limiter: redis_rate.NewLimiter(rdb),
 		cfg:     cfg,
+		keys:    keys,
 	}
 }
 
-// GetClientIP 提取真实客户端 IP
-func GetClientIP(r *http.Request) string {
-	// 1. 优先获取 True-Client-IP (CDN/WAF 常用)
-	ip := r.Header.Get("True-Client-IP")
-	if ip != "" {
-		return ip
-	}
-
-	// 2. 备选 X-Forwarded-For (格式为: client, proxy1, proxy2)
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		parts := strings.Split(xForwardedFor, ",")
-		return strings.TrimSpace(parts[0])
-	}
-
-	// 3. 最后退回到 RemoteAddr
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
-}
-
 func (rl *RedisRateLimiter) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 1. 检查全局开关
@@ -64,6 +49,18 @@ func (rl *RedisRateLimiter) Handler(next http.Handler) http.Handler {
 		// Key 增加 Path 维度，实现针对不同接口独立限流
 		key := "limit:" + r.URL.Path + ":" + clientIP
 
+		// 有效的 API Key 按 Key 计数（所有接口共享额度）；无效的 Key 仍按 IP 限流，由鉴权中间件拒绝
+		if k := rl.apiKey(r); k != nil {
+			key = "limit:apikey:" + k.Prefix
+			limitNum = k.RateLimit
+			if limitNum <= 0 {
+				limitNum = rl.cfg.APIKey
+			}
+			if limitNum <= 0 {
+				limitNum = rl.cfg.Default
+			}
+		}
+
 		res, err := rl.limiter.Allow(r.Context(), key, redis_rate.PerMinute(limitNum))
 		if err != nil {
 			next.ServeHTTP(w, r) // 降级
@@ -78,3 +75,19 @@ func (rl *RedisRateLimiter) Handler(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// apiKey 请求携带有效 API Key 时返回该 Key
+func (rl *RedisRateLimiter) apiKey(r *http.Request) *model.APIKey {
+	if rl.keys == nil {
+		return nil
+	}
+	token, ok := utils.ExtractBearer(r.Header.Get("Authorization"))
+	if !ok || !utils.IsAPIKey(token) {
+		return nil
+	}
+	k, err := rl.keys.LookupAPIKey(r.Context(), token)
+	if err != nil {
+		return nil
+	}
+	return k
+}
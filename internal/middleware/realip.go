@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPKey 请求 Context 中已解析的客户端 IP
+type clientIPKey struct{}
+
+// ClientIPResolver 按可信代理列表解析真实客户端 IP
+// 只有直连对端属于可信代理时才读取 True-Client-IP / X-Forwarded-For，否则这些 Header 可由客户端任意伪造
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver proxies 为可信代理（负载均衡、CDN 回源地址），支持 CIDR 与单个 IP；为空时只使用 RemoteAddr
+func NewClientIPResolver(proxies []string) (*ClientIPResolver, error) {
+	r := &ClientIPResolver{}
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+			}
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+// Handler 解析一次客户端 IP 并写入 Context，之后 GetClientIP 直接读取；需作为最外层中间件
+func (c *ClientIPResolver) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPKey{}, c.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Resolve 直连对端不可信时返回 RemoteAddr；可信时优先 True-Client-IP，
+// 否则从右向左遍历 X-Forwarded-For，跳过可信代理，第一个不可信的地址即客户端
+func (c *ClientIPResolver) Resolve(r *http.Request) string {
+	remote := remoteHost(r)
+	if !c.isTrusted(remote) {
+		return remote
+	}
+
+	if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("True-Client-IP"))); err == nil {
+		return ip.Unmap().String()
+	}
+
+	client := remote
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // 格式错误的条目之前的内容不可信
+		}
+		client = ip.Unmap().String()
+		if !c.isTrusted(client) {
+			break
+		}
+	}
+	return client
+}
+
+func (c *ClientIPResolver) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range c.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP 提取真实客户端 IP：使用 ClientIPResolver 解析的结果，未经过该中间件时使用 RemoteAddr
+func GetClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return remoteHost(r)
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"direct", "203.0.113.9:5000", nil, "203.0.113.9"},
+		{"untrusted peer forges XFF", "203.0.113.9:5000", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.9"},
+		{"untrusted peer forges True-Client-IP", "203.0.113.9:5000", map[string]string{"True-Client-IP": "1.1.1.1"}, "203.0.113.9"},
+		{"trusted proxy", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "198.51.100.4"}, "198.51.100.4"},
+		// 客户端自带的 XFF 在最左侧，只取最右侧第一个不可信地址
+		{"client prepends fake hop", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.4"}, "198.51.100.4"},
+		{"multiple trusted hops", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "198.51.100.4, 192.0.2.1, 10.9.9.9"}, "198.51.100.4"},
+		{"all hops trusted", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "10.2.2.2"}, "10.2.2.2"},
+		{"malformed hop", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "198.51.100.4, garbage"}, "10.1.2.3"},
+		{"trusted CDN True-Client-IP", "192.0.2.1:443", map[string]string{"True-Client-IP": "198.51.100.4", "X-Forwarded-For": "1.1.1.1"}, "198.51.100.4"},
+		{"trusted proxy without headers", "10.1.2.3:80", nil, "10.1.2.3"},
+		{"ipv6 peer", "[2001:db8::1]:443", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "2001:db8::1"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tc.remote
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+
+		var got string
+		resolver.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = GetClientIP(r)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		if got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestGetClientIPWithoutResolver 未经过 ClientIPResolver 时不信任任何转发 Header
+func TestGetClientIPWithoutResolver(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.9:5000"
+	r.Header.Set("X-Forwarded-For", "1.1.1.1")
+	r.Header.Set("True-Client-IP", "1.1.1.1")
+	if got := GetClientIP(r); got != "203.0.113.9" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestNewClientIPResolverRejectsInvalid(t *testing.T) {
+	for _, bad := range []string{"not-an-ip", "10.0.0.0/99"} {
+		if _, err := NewClientIPResolver([]string{bad}); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
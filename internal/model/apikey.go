@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// API Key 授权范围：read / write 控制 HTTP 接口的读写，权限点（如 user:read）与用户角色取交集
+const (
+	APIKeyScopeRead  = "read"  // 只读接口 (GET / HEAD)
+	APIKeyScopeWrite = "write" // 修改类接口
+)
+
+// APIKey 个人 API Key，供脚本、CI 等程序化访问使用；明文只在创建时返回一次
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 明文前缀，便于用户在列表中辨认，也用于查找
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rate_limit"` // 每分钟请求数，0 表示使用默认值
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Expired 是否已过期
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// 审计动作
+const (
+	AuditImpersonateStart = "impersonate.start" // 管理员签发模拟登录 Token
+	AuditHTTPRequest      = "http.request"      // 模拟登录期间的 HTTP 请求
+	AuditGRPCCall         = "grpc.call"         // 模拟登录期间的 gRPC 调用
+)
+
+// AuditLog 审计日志
+type AuditLog struct {
+	ID        int64     `json:"id"`
+	ActorID   int       `json:"actor_id"` // 实际操作者（管理员）
+	UserID    int       `json:"user_id"`  // 被操作 / 被模拟的用户
+	Action    string    `json:"action"`
+	Target    string    `json:"target"` // 如 "GET /api/v1/me"、gRPC FullMethod
+	Status    int       `json:"status"` // HTTP 状态码或 gRPC 状态码
+	Detail    string    `json:"detail,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter 审计日志查询条件，0 表示不限
+type AuditFilter struct {
+	ActorID int
+	UserID  int
+	Offset  int
+	Limit   int
+}
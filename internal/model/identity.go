@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// LinkedIdentity 关联到本地账号的外部身份（第三方账号登录）
+type LinkedIdentity struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"-"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// SocialLoginState 发起第三方登录时保存的上下文，回调时按 state 取回
+type SocialLoginState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkUserID   int    `json:"link_user_id,omitempty"` // 非 0 表示已登录用户绑定新的外部身份
+}
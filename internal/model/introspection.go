@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// TokenIntrospection Token 内省结果，供其他服务鉴权终端用户请求
+// Token 无效（签名、过期、已吊销、会话已移除）时只有 Active=false
+type TokenIntrospection struct {
+	Active    bool       `json:"active"`
+	UserID    int        `json:"user_id,omitempty"`
+	TokenType string     `json:"token_type,omitempty"` // access / api_key
+	Scopes    []string   `json:"scopes,omitempty"`
+	SessionID string     `json:"session_id,omitempty"`
+	ActorID   int        `json:"actor_id,omitempty"` // 模拟登录时为操作的管理员 ID
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
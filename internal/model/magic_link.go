@@ -0,0 +1,9 @@
+package model
+
+// MagicLink 邮件登录链接对应的服务端记录，Token 本身只保存哈希
+type MagicLink struct {
+	UserID    int    `json:"user_id"` // 0 表示邮箱尚未注册（开启自动注册时）
+	Email     string `json:"email"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// UserMFA 用户的 TOTP 二次验证配置
+// Secret 为加密后的密钥；Enabled 为 false 表示已发起绑定但尚未确认
+type UserMFA struct {
+	UserID    int       `db:"user_id" json:"user_id"`
+	Secret    string    `db:"secret" json:"-"`
+	Enabled   bool      `db:"enabled" json:"enabled"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
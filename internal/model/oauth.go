@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// OAuth2 授权类型
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OAuthClient 接入本服务登录的应用 (OIDC Relying Party)
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	SecretHash   string    `json:"-"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"` // 公开客户端（SPA / App）无密钥，必须使用 PKCE
+	OwnerID      int       `json:"owner_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// OAuthAuthCode 授权码绑定的上下文，换取 Token 时逐项校验
+type OAuthAuthCode struct {
+	ClientID            string `json:"client_id"`
+	UserID              int    `json:"user_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	Nonce               string `json:"nonce,omitempty"`
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+	AuthTime            int64  `json:"auth_time"`
+}
@@ -0,0 +1,20 @@
+package model
+
+// 权限点：角色与权限的对应关系保存在 role_permissions 表，代码中只引用权限点
+const (
+	PermUserRead    = "user:read"        // 查看、搜索用户
+	PermUserSuspend = "user:suspend"     // 停用、恢复用户
+	PermUserDelete  = "user:delete"      // 删除用户
+	PermRoleAssign  = "role:assign"      // 为用户分配、移除角色
+	PermLoginUnlock = "login:unlock"     // 解除登录锁定
+	PermOAuthClient = "oauth:client"     // 注册、管理 OIDC 客户端
+	PermImpersonate = "user:impersonate" // 模拟用户登录（排查问题）
+	PermAuditRead   = "audit:read"       // 查看审计日志
+)
+
+type Role struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Session 登录会话（一次登录派生出的 Access/Refresh Token 共享同一会话）
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    int       `json:"-"`
+	Device    string    `json:"device"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	ClientID  string    `json:"client_id,omitempty"` // 通过 OIDC 登录第三方应用时的 client_id
+	Scope     string    `json:"scope,omitempty"`     // OIDC 授权范围
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	Current   bool      `json:"current"` // 是否为发起本次请求的会话
+}
@@ -2,6 +2,13 @@ package model
 
 import "time"
 
+// 用户状态
+const (
+	UserStatusNormal    = 1 // 正常
+	UserStatusPending   = 2 // 待验证邮箱
+	UserStatusSuspended = 3 // 已被管理员停用
+)
+
 type User struct {
 	ID        int       `db:"id" json:"id"`
 	Name      string    `db:"name" json:"name"`         // 账号名
@@ -15,3 +22,11 @@ type User struct {
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
+
+// UserFilter 管理后台用户列表的查询条件
+type UserFilter struct {
+	Keyword string // 模糊匹配账号名、昵称、邮箱
+	Status  int    // 0 表示不限
+	Offset  int
+	Limit   int
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// 用户变更事件类型
+const (
+	UserEventUpdated       = "updated"        // 资料修改
+	UserEventStatusChanged = "status_changed" // 停用 / 恢复 / 邮箱验证
+	UserEventDeleted       = "deleted"
+)
+
+// UserEvent 用户变更通知，下游服务据此失效自身缓存；ID 为 Redis Stream 消息 ID，可用于断线续传
+type UserEvent struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"user_id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// 表结构（迁移脚本 migrations/006_api_keys.sql）：
+//
+//	CREATE TABLE api_keys (
+//	    id           INT AUTO_INCREMENT PRIMARY KEY,
+//	    user_id      INT NOT NULL,
+//	    name         VARCHAR(64) NOT NULL,
+//	    prefix       VARCHAR(16) NOT NULL UNIQUE,
+//	    key_hash     CHAR(64) NOT NULL,
+//	    scopes       VARCHAR(512) NOT NULL, -- JSON 数组
+//	    rate_limit   INT NOT NULL DEFAULT 0,
+//	    expires_at   DATETIME NULL,
+//	    last_used_at DATETIME NULL,
+//	    created_at   DATETIME NOT NULL,
+//	    INDEX idx_user (user_id)
+//	);
+
+// ErrAPIKeyPrefixExists 新 Key 的前缀与已有 Key 重复（前缀随机生成，概率极低，重新生成即可）
+var ErrAPIKeyPrefixExists = errors.New("api key prefix already exists")
+
+// apiKeyCacheTTL 每个使用 API Key 的请求都会查询，缓存到 Redis；删除时主动清除
+const apiKeyCacheTTL = 5 * time.Minute
+
+// APIKeyRepository 个人 API Key
+type APIKeyRepository interface {
+	// Create 前缀重复时返回 ErrAPIKeyPrefixExists
+	Create(ctx context.Context, k *model.APIKey) error
+	// GetByPrefix 按前缀查找（带缓存），不存在时返回 nil, nil
+	GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	ListByUser(ctx context.Context, userID int) ([]model.APIKey, error)
+	CountByUser(ctx context.Context, userID int) (int, error)
+	// Delete 删除用户自己的 Key，不存在时返回 sql.ErrNoRows
+	Delete(ctx context.Context, userID, id int) error
+	// TouchLastUsed 记录最后使用时间并清除缓存
+	TouchLastUsed(ctx context.Context, k *model.APIKey) error
+}
+
+type apiKeyRepo struct {
+	db    *sql.DB
+	redis *redis.Client
+}
+
+func NewAPIKeyRepository(db *sql.DB, rdb *redis.Client) APIKeyRepository {
+	return &apiKeyRepo{db: db, redis: rdb}
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, rate_limit, expires_at, last_used_at, created_at"
+
+func apiKeyCacheKey(prefix string) string {
+	return fmt.Sprintf("apikey:%s", prefix)
+}
+
+func (r *apiKeyRepo) Create(ctx context.Context, k *model.APIKey) error {
+	scopes, _ := json.Marshal(k.Scopes)
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, rate_limit, expires_at, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, query,
+		k.UserID, k.Name, k.Prefix, k.KeyHash, string(scopes), k.RateLimit, k.ExpiresAt, k.CreatedAt,
+	)
+	if err != nil {
+		var me *mysql.MySQLError
+		if errors.As(err, &me) && me.Number == 1062 {
+			return ErrAPIKeyPrefixExists
+		}
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	k.ID = int(id)
+	return nil
+}
+
+func (r *apiKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	key := apiKeyCacheKey(prefix)
+	if val, err := r.redis.Get(ctx, key).Result(); err == nil {
+		var c apiKeyCache
+		if json.Unmarshal([]byte(val), &c) == nil {
+			k := c.APIKey
+			k.UserID, k.KeyHash = c.UserID, c.KeyHash
+			return &k, nil
+		}
+	}
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE prefix = ?"
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx, query, prefix))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.Marshal(apiKeyCache{APIKey: *k, UserID: k.UserID, KeyHash: k.KeyHash})
+	r.redis.Set(ctx, key, data, apiKeyCacheTTL)
+	return k, nil
+}
+
+// apiKeyCache 缓存结构：model.APIKey 的 json 标签隐藏了 user_id 与 key_hash，这里单独保存
+type apiKeyCache struct {
+	model.APIKey
+	UserID  int    `json:"user_id"`
+	KeyHash string `json:"key_hash"`
+}
+
+func (r *apiKeyRepo) ListByUser(ctx context.Context, userID int) ([]model.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE user_id = ? ORDER BY id DESC"
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *apiKeyRepo) CountByUser(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM api_keys WHERE user_id = ?", userID).Scan(&n)
+	return n, err
+}
+
+func (r *apiKeyRepo) Delete(ctx context.Context, userID, id int) error {
+	var prefix string
+	err := r.db.QueryRowContext(ctx, "SELECT prefix FROM api_keys WHERE id = ? AND user_id = ?", id, userID).Scan(&prefix)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM api_keys WHERE id = ?", id); err != nil {
+		return err
+	}
+	return r.redis.Del(ctx, apiKeyCacheKey(prefix)).Err()
+}
+
+func (r *apiKeyRepo) TouchLastUsed(ctx context.Context, k *model.APIKey) error {
+	if _, err := r.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = NOW() WHERE id = ?", k.ID); err != nil {
+		return err
+	}
+	return r.redis.Del(ctx, apiKeyCacheKey(k.Prefix)).Err()
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var k model.APIKey
+	var scopes string
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &scopes, &k.RateLimit, &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &k.Scopes); err != nil {
+		return nil, fmt.Errorf("api key %s: bad scopes: %w", k.Prefix, err)
+	}
+	return &k, nil
+}
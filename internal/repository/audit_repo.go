@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+)
+
+// 表结构（迁移脚本 migrations/007_audit_logs.sql）：
+//
+//	CREATE TABLE audit_logs (
+//	    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+//	    actor_id   INT NOT NULL,
+//	    user_id    INT NOT NULL,
+//	    action     VARCHAR(32) NOT NULL,
+//	    target     VARCHAR(255) NOT NULL,
+//	    status     INT NOT NULL DEFAULT 0,
+//	    detail     VARCHAR(512) NOT NULL DEFAULT '',
+//	    ip         VARCHAR(64) NOT NULL DEFAULT '',
+//	    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+//	    created_at DATETIME NOT NULL,
+//	    INDEX idx_actor (actor_id, id),
+//	    INDEX idx_user (user_id, id)
+//	);
+
+// AuditRepository 审计日志，只追加不修改
+type AuditRepository interface {
+	Create(ctx context.Context, log *model.AuditLog) error
+	// List 按时间倒序分页查询，返回当前页与总数
+	List(ctx context.Context, f model.AuditFilter) ([]model.AuditLog, int, error)
+}
+
+type auditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Create(ctx context.Context, l *model.AuditLog) error {
+	query := `INSERT INTO audit_logs (actor_id, user_id, action, target, status, detail, ip, user_agent, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, query,
+		l.ActorID, l.UserID, l.Action, l.Target, l.Status, l.Detail, l.IP, l.UserAgent, l.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	l.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r *auditRepo) List(ctx context.Context, f model.AuditFilter) ([]model.AuditLog, int, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	if f.ActorID != 0 {
+		where = append(where, "actor_id = ?")
+		args = append(args, f.ActorID)
+	}
+	if f.UserID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_logs WHERE "+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, actor_id, user_id, action, target, status, detail, ip, user_agent, created_at
+              FROM audit_logs WHERE ` + cond + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	rows, err := r.db.QueryContext(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	logs := make([]model.AuditLog, 0, f.Limit)
+	for rows.Next() {
+		var l model.AuditLog
+		if err := rows.Scan(&l.ID, &l.ActorID, &l.UserID, &l.Action, &l.Target, &l.Status, &l.Detail, &l.IP, &l.UserAgent, &l.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, total, rows.Err()
+}
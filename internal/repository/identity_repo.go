@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// 表结构（迁移脚本 migrations/005_linked_identities.sql）：
+//
+//	CREATE TABLE linked_identities (
+//	    id            INT AUTO_INCREMENT PRIMARY KEY,
+//	    user_id       INT NOT NULL,
+//	    provider      VARCHAR(32) NOT NULL,
+//	    subject       VARCHAR(255) NOT NULL,
+//	    email         VARCHAR(255) NOT NULL DEFAULT '',
+//	    created_at    DATETIME NOT NULL,
+//	    last_login_at DATETIME NULL,
+//	    UNIQUE KEY uk_provider_subject (provider, subject),
+//	    UNIQUE KEY uk_user_provider (user_id, provider)
+//	);
+
+var (
+	// ErrIdentityExists 该外部身份已关联到某个账号，或该账号已关联同一提供方的其他身份
+	ErrIdentityExists = errors.New("identity already linked")
+	// ErrSocialStateNotFound state 不存在、已使用或已过期
+	ErrSocialStateNotFound = errors.New("social login state not found")
+)
+
+// IdentityRepository 外部身份与本地账号的关联
+type IdentityRepository interface {
+	// Find 不存在时返回 nil, nil
+	Find(ctx context.Context, provider, subject string) (*model.LinkedIdentity, error)
+	ListByUser(ctx context.Context, userID int) ([]model.LinkedIdentity, error)
+	Create(ctx context.Context, id *model.LinkedIdentity) error
+	Delete(ctx context.Context, userID int, provider string) error
+	TouchLogin(ctx context.Context, id int) error
+
+	// 发起登录到回调之间的 state（Redis，单次有效）
+	SaveState(ctx context.Context, state string, s *model.SocialLoginState, ttl time.Duration) error
+	ConsumeState(ctx context.Context, state string) (*model.SocialLoginState, error)
+}
+
+type identityRepo struct {
+	db    *sql.DB
+	redis *redis.Client
+}
+
+func NewIdentityRepository(db *sql.DB, rdb *redis.Client) IdentityRepository {
+	return &identityRepo{db: db, redis: rdb}
+}
+
+func (r *identityRepo) Find(ctx context.Context, provider, subject string) (*model.LinkedIdentity, error) {
+	var li model.LinkedIdentity
+	query := `SELECT id, user_id, provider, subject, email, created_at, last_login_at
+              FROM linked_identities WHERE provider = ? AND subject = ?`
+	err := r.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&li.ID, &li.UserID, &li.Provider, &li.Subject, &li.Email, &li.CreatedAt, &li.LastLoginAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &li, err
+}
+
+func (r *identityRepo) ListByUser(ctx context.Context, userID int) ([]model.LinkedIdentity, error) {
+	query := `SELECT id, user_id, provider, subject, email, created_at, last_login_at
+              FROM linked_identities WHERE user_id = ? ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.LinkedIdentity
+	for rows.Next() {
+		var li model.LinkedIdentity
+		if err := rows.Scan(&li.ID, &li.UserID, &li.Provider, &li.Subject, &li.Email, &li.CreatedAt, &li.LastLoginAt); err != nil {
+			return nil, err
+		}
+		list = append(list, li)
+	}
+	return list, rows.Err()
+}
+
+func (r *identityRepo) Create(ctx context.Context, li *model.LinkedIdentity) error {
+	query := `INSERT INTO linked_identities (user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, NOW())`
+	res, err := r.db.ExecContext(ctx, query, li.UserID, li.Provider, li.Subject, li.Email)
+	if err != nil {
+		// 唯一键冲突：并发绑定或已被其他账号绑定
+		var me *mysql.MySQLError
+		if errors.As(err, &me) && me.Number == 1062 {
+			return ErrIdentityExists
+		}
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	li.ID = int(id)
+	return nil
+}
+
+func (r *identityRepo) Delete(ctx context.Context, userID int, provider string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM linked_identities WHERE user_id = ? AND provider = ?", userID, provider)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *identityRepo) TouchLogin(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE linked_identities SET last_login_at = NOW() WHERE id = ?", id)
+	return err
+}
+
+func (r *identityRepo) SaveState(ctx context.Context, state string, s *model.SocialLoginState, ttl time.Duration) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return r.redis.Set(ctx, fmt.Sprintf("social:state:%s", state), data, ttl).Err()
+}
+
+func (r *identityRepo) ConsumeState(ctx context.Context, state string) (*model.SocialLoginState, error) {
+	val, err := r.redis.GetDel(ctx, fmt.Sprintf("social:state:%s", state)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSocialStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s model.SocialLoginState
+	if err := json.Unmarshal([]byte(val), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
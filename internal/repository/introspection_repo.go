@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// IntrospectionRepository Token 内省结果的短期缓存，以 Token 哈希为键
+type IntrospectionRepository interface {
+	// Get 未命中时返回 nil, nil
+	Get(ctx context.Context, tokenHash string) (*model.TokenIntrospection, error)
+	Set(ctx context.Context, tokenHash string, result *model.TokenIntrospection, ttl time.Duration) error
+}
+
+type introspectionRepo struct {
+	redis *redis.Client
+}
+
+func NewIntrospectionRepository(rdb *redis.Client) IntrospectionRepository {
+	return &introspectionRepo{redis: rdb}
+}
+
+func (r *introspectionRepo) Get(ctx context.Context, tokenHash string) (*model.TokenIntrospection, error) {
+	val, err := r.redis.Get(ctx, fmt.Sprintf("introspect:%s", tokenHash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result model.TokenIntrospection
+	if err := json.Unmarshal(val, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *introspectionRepo) Set(ctx context.Context, tokenHash string, result *model.TokenIntrospection, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return r.redis.Set(ctx, fmt.Sprintf("introspect:%s", tokenHash), data, ttl).Err()
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginAttemptRepository 登录失败计数与临时封禁（防暴力破解）
+// 计数与封禁同时按邮箱和 IP 两个维度记录；邮箱维度对不存在的账号同样生效，避免泄露账号是否存在
+type LoginAttemptRepository interface {
+	// RecordFailure 记录一次失败，返回窗口内邮箱与 IP 的累计失败次数
+	RecordFailure(ctx context.Context, email, ip string, window time.Duration) (emailFails, ipFails int64, err error)
+	// ResetEmail 登录成功后清零邮箱维度的失败计数
+	ResetEmail(ctx context.Context, email string) error
+	BlockEmail(ctx context.Context, email string, d time.Duration) error
+	BlockIP(ctx context.Context, ip string, d time.Duration) error
+	// BlockedFor 返回邮箱或 IP 剩余的封禁时间（取较大者），0 表示未封禁
+	BlockedFor(ctx context.Context, email, ip string) (time.Duration, error)
+	// Unlock 运维解锁：清除封禁与失败计数，参数为空则跳过对应维度
+	Unlock(ctx context.Context, email, ip string) error
+}
+
+type loginAttemptRepo struct {
+	redis *redis.Client
+}
+
+func NewLoginAttemptRepository(rdb *redis.Client) LoginAttemptRepository {
+	return &loginAttemptRepo{redis: rdb}
+}
+
+func (r *loginAttemptRepo) RecordFailure(ctx context.Context, email, ip string, window time.Duration) (int64, int64, error) {
+	emailKey := fmt.Sprintf("login:fail:email:%s", email)
+	ipKey := fmt.Sprintf("login:fail:ip:%s", ip)
+
+	pipe := r.redis.TxPipeline()
+	emailIncr := pipe.Incr(ctx, emailKey)
+	pipe.Expire(ctx, emailKey, window)
+	ipIncr := pipe.Incr(ctx, ipKey)
+	pipe.Expire(ctx, ipKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+	return emailIncr.Val(), ipIncr.Val(), nil
+}
+
+func (r *loginAttemptRepo) ResetEmail(ctx context.Context, email string) error {
+	return r.redis.Del(ctx, fmt.Sprintf("login:fail:email:%s", email)).Err()
+}
+
+func (r *loginAttemptRepo) BlockEmail(ctx context.Context, email string, d time.Duration) error {
+	return r.redis.Set(ctx, fmt.Sprintf("login:block:email:%s", email), 1, d).Err()
+}
+
+func (r *loginAttemptRepo) BlockIP(ctx context.Context, ip string, d time.Duration) error {
+	return r.redis.Set(ctx, fmt.Sprintf("login:block:ip:%s", ip), 1, d).Err()
+}
+
+func (r *loginAttemptRepo) BlockedFor(ctx context.Context, email, ip string) (time.Duration, error) {
+	pipe := r.redis.Pipeline()
+	emailTTL := pipe.PTTL(ctx, fmt.Sprintf("login:block:email:%s", email))
+	ipTTL := pipe.PTTL(ctx, fmt.Sprintf("login:block:ip:%s", ip))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	// Key 不存在时 PTTL 返回负值
+	d := emailTTL.Val()
+	if ipTTL.Val() > d {
+		d = ipTTL.Val()
+	}
+	if d < 0 {
+		return 0, nil
+	}
+	return d, nil
+}
+
+func (r *loginAttemptRepo) Unlock(ctx context.Context, email, ip string) error {
+	var keys []string
+	if email != "" {
+		keys = append(keys, fmt.Sprintf("login:block:email:%s", email), fmt.Sprintf("login:fail:email:%s", email))
+	}
+	if ip != "" {
+		keys = append(keys, fmt.Sprintf("login:block:ip:%s", ip), fmt.Sprintf("login:fail:ip:%s", ip))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.redis.Del(ctx, keys...).Err()
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMagicLinkNotFound 登录链接不存在、已使用或已过期
+var ErrMagicLinkNotFound = errors.New("magic link not found")
+
+// MagicLinkRepository 邮件登录链接与发送频率限制
+type MagicLinkRepository interface {
+	// Save 保存链接，并使该邮箱之前未使用的链接失效
+	Save(ctx context.Context, tokenHash string, link *model.MagicLink, ttl time.Duration) error
+	// Consume 原子地取出并删除链接
+	Consume(ctx context.Context, tokenHash string) (*model.MagicLink, error)
+	// Allow 固定窗口计数，超过 limit 时返回 false 与窗口剩余时间
+	Allow(ctx context.Context, subject string, limit int, window time.Duration) (bool, time.Duration, error)
+}
+
+type magicLinkRepo struct {
+	redis *redis.Client
+}
+
+func NewMagicLinkRepository(rdb *redis.Client) MagicLinkRepository {
+	return &magicLinkRepo{redis: rdb}
+}
+
+func (r *magicLinkRepo) Save(ctx context.Context, tokenHash string, link *model.MagicLink, ttl time.Duration) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	emailKey := fmt.Sprintf("magic:email:%s", link.Email)
+
+	// 同一邮箱只保留最新的链接
+	if old, err := r.redis.Get(ctx, emailKey).Result(); err == nil {
+		r.redis.Del(ctx, fmt.Sprintf("magic:link:%s", old))
+	}
+
+	pipe := r.redis.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("magic:link:%s", tokenHash), data, ttl)
+	pipe.Set(ctx, emailKey, tokenHash, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *magicLinkRepo) Consume(ctx context.Context, tokenHash string) (*model.MagicLink, error) {
+	val, err := r.redis.GetDel(ctx, fmt.Sprintf("magic:link:%s", tokenHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMagicLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var link model.MagicLink
+	if err := json.Unmarshal([]byte(val), &link); err != nil {
+		return nil, err
+	}
+	r.redis.Del(ctx, fmt.Sprintf("magic:email:%s", link.Email))
+	return &link, nil
+}
+
+func (r *magicLinkRepo) Allow(ctx context.Context, subject string, limit int, window time.Duration) (bool, time.Duration, error) {
+	key := fmt.Sprintf("magic:limit:%s", subject)
+	n, err := r.redis.Incr(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	// 窗口从第一次请求开始计时
+	if n == 1 {
+		r.redis.Expire(ctx, key, window)
+	}
+	if n > int64(limit) {
+		ttl, err := r.redis.PTTL(ctx, key).Result()
+		if err != nil {
+			return false, 0, err
+		}
+		if ttl < 0 {
+			// 设置过期时间失败的残留 Key，补设后按整个窗口计算
+			r.redis.Expire(ctx, key, window)
+			ttl = window
+		}
+		return false, ttl, nil
+	}
+	return true, 0, nil
+}
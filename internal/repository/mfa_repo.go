@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+)
+
+// 表结构（迁移脚本 migrations/001_user_mfa.sql）：
+//
+//	CREATE TABLE user_mfa (
+//	    user_id    INT PRIMARY KEY,
+//	    secret     VARCHAR(255) NOT NULL,
+//	    enabled    TINYINT NOT NULL DEFAULT 0,
+//	    created_at DATETIME NOT NULL,
+//	    updated_at DATETIME NOT NULL
+//	);
+//
+//	CREATE TABLE user_recovery_codes (
+//	    id        INT AUTO_INCREMENT PRIMARY KEY,
+//	    user_id   INT NOT NULL,
+//	    code_hash CHAR(64) NOT NULL,
+//	    used_at   DATETIME NULL,
+//	    UNIQUE KEY uk_user_code (user_id, code_hash)
+//	);
+
+type MFARepository interface {
+	Get(ctx context.Context, userID int) (*model.UserMFA, error)
+	// SavePending 保存（或覆盖）尚未确认的绑定
+	SavePending(ctx context.Context, userID int, encryptedSecret string) error
+	// Enable 确认绑定，并用新的恢复码替换旧恢复码
+	Enable(ctx context.Context, userID int, codeHashes []string) error
+	Disable(ctx context.Context, userID int) error
+	// UseRecoveryCode 核销一个未使用的恢复码，返回是否核销成功
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+}
+
+type mfaRepo struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) MFARepository {
+	return &mfaRepo{db: db}
+}
+
+func (r *mfaRepo) Get(ctx context.Context, userID int) (*model.UserMFA, error) {
+	var m model.UserMFA
+	query := "SELECT user_id, secret, enabled, created_at, updated_at FROM user_mfa WHERE user_id = ?"
+
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&m.UserID, &m.Secret, &m.Enabled, &m.CreatedAt, &m.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &m, err
+}
+
+func (r *mfaRepo) SavePending(ctx context.Context, userID int, encryptedSecret string) error {
+	query := `INSERT INTO user_mfa (user_id, secret, enabled, created_at, updated_at)
+              VALUES (?, ?, 0, NOW(), NOW())
+              ON DUPLICATE KEY UPDATE secret = VALUES(secret), enabled = 0, updated_at = NOW()`
+	_, err := r.db.ExecContext(ctx, query, userID, encryptedSecret)
+	return err
+}
+
+func (r *mfaRepo) Enable(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE user_mfa SET enabled = 1, updated_at = NOW() WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *mfaRepo) Disable(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *mfaRepo) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	query := `UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// 表结构（迁移脚本 migrations/004_oauth_clients.sql）：
+//
+//	CREATE TABLE oauth_clients (
+//	    client_id     VARCHAR(64) PRIMARY KEY,
+//	    secret_hash   CHAR(64) NOT NULL DEFAULT '',
+//	    name          VARCHAR(128) NOT NULL,
+//	    redirect_uris TEXT NOT NULL,         -- JSON 数组
+//	    grant_types   VARCHAR(255) NOT NULL, -- JSON 数组
+//	    scopes        VARCHAR(255) NOT NULL, -- JSON 数组
+//	    public        TINYINT NOT NULL DEFAULT 0,
+//	    owner_id      INT NOT NULL,
+//	    created_at    DATETIME NOT NULL
+//	);
+
+// ErrAuthCodeNotFound 授权码不存在、已使用或已过期
+var ErrAuthCodeNotFound = errors.New("authorization code not found")
+
+// OAuthClientRepository OIDC 客户端注册信息
+type OAuthClientRepository interface {
+	Create(ctx context.Context, c *model.OAuthClient) error
+	// Get 不存在时返回 nil, nil
+	Get(ctx context.Context, clientID string) (*model.OAuthClient, error)
+	List(ctx context.Context) ([]model.OAuthClient, error)
+	Delete(ctx context.Context, clientID string) error
+}
+
+type oauthClientRepo struct {
+	db *sql.DB
+}
+
+func NewOAuthClientRepository(db *sql.DB) OAuthClientRepository {
+	return &oauthClientRepo{db: db}
+}
+
+const oauthClientColumns = "client_id, secret_hash, name, redirect_uris, grant_types, scopes, public, owner_id, created_at"
+
+func (r *oauthClientRepo) Create(ctx context.Context, c *model.OAuthClient) error {
+	redirects, _ := json.Marshal(c.RedirectURIs)
+	grants, _ := json.Marshal(c.GrantTypes)
+	scopes, _ := json.Marshal(c.Scopes)
+
+	query := `INSERT INTO oauth_clients (` + oauthClientColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		c.ID, c.SecretHash, c.Name, string(redirects), string(grants), string(scopes), c.Public, c.OwnerID, c.CreatedAt,
+	)
+	return err
+}
+
+func (r *oauthClientRepo) Get(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	query := "SELECT " + oauthClientColumns + " FROM oauth_clients WHERE client_id = ?"
+	c, err := scanOAuthClient(r.db.QueryRowContext(ctx, query, clientID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+func (r *oauthClientRepo) List(ctx context.Context) ([]model.OAuthClient, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []model.OAuthClient
+	for rows.Next() {
+		c, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *c)
+	}
+	return clients, rows.Err()
+}
+
+func (r *oauthClientRepo) Delete(ctx context.Context, clientID string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE client_id = ?", clientID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOAuthClient(row rowScanner) (*model.OAuthClient, error) {
+	var c model.OAuthClient
+	var redirects, grants, scopes string
+	if err := row.Scan(&c.ID, &c.SecretHash, &c.Name, &redirects, &grants, &scopes, &c.Public, &c.OwnerID, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(redirects), &c.RedirectURIs); err != nil {
+		return nil, fmt.Errorf("oauth client %s: bad redirect_uris: %w", c.ID, err)
+	}
+	if err := json.Unmarshal([]byte(grants), &c.GrantTypes); err != nil {
+		return nil, fmt.Errorf("oauth client %s: bad grant_types: %w", c.ID, err)
+	}
+	if err := json.Unmarshal([]byte(scopes), &c.Scopes); err != nil {
+		return nil, fmt.Errorf("oauth client %s: bad scopes: %w", c.ID, err)
+	}
+	return &c, nil
+}
+
+// OAuthCodeRepository 授权码（Redis，单次有效）
+type OAuthCodeRepository interface {
+	Save(ctx context.Context, code string, ac *model.OAuthAuthCode, ttl time.Duration) error
+	// Consume 原子地读取并删除授权码，不存在时返回 ErrAuthCodeNotFound
+	Consume(ctx context.Context, code string) (*model.OAuthAuthCode, error)
+}
+
+type oauthCodeRepo struct {
+	redis *redis.Client
+}
+
+func NewOAuthCodeRepository(rdb *redis.Client) OAuthCodeRepository {
+	return &oauthCodeRepo{redis: rdb}
+}
+
+func (r *oauthCodeRepo) Save(ctx context.Context, code string, ac *model.OAuthAuthCode, ttl time.Duration) error {
+	data, err := json.Marshal(ac)
+	if err != nil {
+		return err
+	}
+	return r.redis.Set(ctx, fmt.Sprintf("oauth:code:%s", code), data, ttl).Err()
+}
+
+func (r *oauthCodeRepo) Consume(ctx context.Context, code string) (*model.OAuthAuthCode, error) {
+	val, err := r.redis.GetDel(ctx, fmt.Sprintf("oauth:code:%s", code)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrAuthCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ac model.OAuthAuthCode
+	if err := json.Unmarshal([]byte(val), &ac); err != nil {
+		return nil, err
+	}
+	return &ac, nil
+}
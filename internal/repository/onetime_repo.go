@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrOneTimeTokenNotFound 一次性 Token 不存在（已使用、已被新 Token 取代或已过期）
+var ErrOneTimeTokenNotFound = errors.New("one-time token not found")
+
+// OneTimeTokenRepository 一次性 Token 存储（邮箱验证、密码重置等）
+// purpose 区分用途，避免不同流程的 Token 互相混用
+type OneTimeTokenRepository interface {
+	// Save 保存 Token 并使该用户同一用途下的旧 Token 失效
+	Save(ctx context.Context, purpose, tokenID string, userID int, ttl time.Duration) error
+	// Consume 原子地取出并删除 Token，返回所属用户 ID
+	Consume(ctx context.Context, purpose, tokenID string) (int, error)
+	// AcquireCooldown 冷却期内只允许一次操作（如重发邮件），返回 false 表示仍在冷却中
+	AcquireCooldown(ctx context.Context, purpose, subject string, interval time.Duration) (bool, error)
+	// IncrAttempts 记录 Token 的校验失败次数（如二次验证码输错），返回累计次数
+	IncrAttempts(ctx context.Context, purpose, tokenID string, ttl time.Duration) (int64, error)
+}
+
+type oneTimeTokenRepo struct {
+	redis *redis.Client
+}
+
+func NewOneTimeTokenRepository(rdb *redis.Client) OneTimeTokenRepository {
+	return &oneTimeTokenRepo{redis: rdb}
+}
+
+func (r *oneTimeTokenRepo) Save(ctx context.Context, purpose, tokenID string, userID int, ttl time.Duration) error {
+	userKey := fmt.Sprintf("ott:%s:user:%d", purpose, userID)
+
+	// 删除该用户上一次签发的 Token，保证同一时间只有最新的链接有效
+	if old, err := r.redis.Get(ctx, userKey).Result(); err == nil {
+		r.redis.Del(ctx, fmt.Sprintf("ott:%s:%s", purpose, old))
+	}
+
+	pipe := r.redis.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("ott:%s:%s", purpose, tokenID), userID, ttl)
+	pipe.Set(ctx, userKey, tokenID, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *oneTimeTokenRepo) Consume(ctx context.Context, purpose, tokenID string) (int, error) {
+	userID, err := r.redis.GetDel(ctx, fmt.Sprintf("ott:%s:%s", purpose, tokenID)).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrOneTimeTokenNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	r.redis.Del(ctx, fmt.Sprintf("ott:%s:user:%d", purpose, userID))
+	return userID, nil
+}
+
+func (r *oneTimeTokenRepo) AcquireCooldown(ctx context.Context, purpose, subject string, interval time.Duration) (bool, error) {
+	return r.redis.SetNX(ctx, fmt.Sprintf("ott:%s:cooldown:%s", purpose, subject), 1, interval).Result()
+}
+
+func (r *oneTimeTokenRepo) IncrAttempts(ctx context.Context, purpose, tokenID string, ttl time.Duration) (int64, error) {
+	key := fmt.Sprintf("ott:%s:attempts:%s", purpose, tokenID)
+	pipe := r.redis.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
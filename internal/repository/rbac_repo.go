@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// 表结构（迁移脚本 migrations/002_rbac.sql）：
+//
+//	CREATE TABLE roles (
+//	    id          INT AUTO_INCREMENT PRIMARY KEY,
+//	    name        VARCHAR(32) NOT NULL UNIQUE,
+//	    description VARCHAR(255) NOT NULL DEFAULT ''
+//	);
+//
+//	CREATE TABLE role_permissions (
+//	    role_id    INT NOT NULL,
+//	    permission VARCHAR(64) NOT NULL,
+//	    PRIMARY KEY (role_id, permission)
+//	);
+//
+//	CREATE TABLE user_roles (
+//	    user_id    INT NOT NULL,
+//	    role_id    INT NOT NULL,
+//	    created_at DATETIME NOT NULL,
+//	    PRIMARY KEY (user_id, role_id)
+//	);
+//
+// 初始角色：
+//
+//	INSERT INTO roles (id, name, description) VALUES (1, 'admin', '管理员'), (2, 'support', '客服');
+//	INSERT INTO role_permissions (role_id, permission) VALUES
+//	    (1, 'user:read'), (1, 'user:suspend'), (1, 'user:delete'), (1, 'role:assign'), (1, 'login:unlock'), (1, 'oauth:client'),
+//	    (1, 'user:impersonate'), (1, 'audit:read'),
+//	    (2, 'user:read'), (2, 'login:unlock'), (2, 'user:impersonate');
+
+// ErrRoleNotFound 角色不存在
+var ErrRoleNotFound = errors.New("role not found")
+
+// permCacheTTL 用户权限缓存时间；角色变更时主动清除，TTL 只作兜底
+const permCacheTTL = 5 * time.Minute
+
+type RBACRepository interface {
+	ListRoles(ctx context.Context) ([]model.Role, error)
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	// GetUserPermissions 返回用户所有角色的权限并集（带 Redis 缓存，每个鉴权请求都会调用）
+	GetUserPermissions(ctx context.Context, userID int) ([]string, error)
+	AssignRole(ctx context.Context, userID int, role string) error
+	RemoveRole(ctx context.Context, userID int, role string) error
+	DeleteCache(ctx context.Context, userID int) error
+}
+
+type rbacRepo struct {
+	db    *sql.DB
+	redis *redis.Client
+}
+
+func NewRBACRepository(db *sql.DB, rdb *redis.Client) RBACRepository {
+	return &rbacRepo{db: db, redis: rdb}
+}
+
+func (r *rbacRepo) ListRoles(ctx context.Context) ([]model.Role, error) {
+	query := `
+		SELECT r.id, r.name, r.description, COALESCE(rp.permission, '')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		ORDER BY r.id, rp.permission`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []model.Role
+	for rows.Next() {
+		var role model.Role
+		var perm string
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &perm); err != nil {
+			return nil, err
+		}
+		if n := len(roles); n == 0 || roles[n-1].ID != role.ID {
+			roles = append(roles, role)
+		}
+		if perm != "" {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, perm)
+		}
+	}
+	return roles, rows.Err()
+}
+
+func (r *rbacRepo) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	query := `
+		SELECT r.name FROM roles r
+		INNER JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = ?
+		ORDER BY r.id`
+	return r.queryStrings(ctx, query, userID)
+}
+
+func (r *rbacRepo) GetUserPermissions(ctx context.Context, userID int) ([]string, error) {
+	key := fmt.Sprintf("rbac:perms:%d", userID)
+	if val, err := r.redis.Get(ctx, key).Result(); err == nil {
+		var perms []string
+		if json.Unmarshal([]byte(val), &perms) == nil {
+			return perms, nil
+		}
+	}
+
+	query := `
+		SELECT DISTINCT rp.permission FROM role_permissions rp
+		INNER JOIN user_roles ur ON ur.role_id = rp.role_id
+		WHERE ur.user_id = ?`
+	perms, err := r.queryStrings(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有任何角色的普通用户同样缓存（空数组），避免每次鉴权都查库
+	if perms == nil {
+		perms = []string{}
+	}
+	data, _ := json.Marshal(perms)
+	r.redis.Set(ctx, key, data, permCacheTTL)
+	return perms, nil
+}
+
+func (r *rbacRepo) AssignRole(ctx context.Context, userID int, role string) error {
+	roleID, err := r.roleID(ctx, role)
+	if err != nil {
+		return err
+	}
+	query := `INSERT IGNORE INTO user_roles (user_id, role_id, created_at) VALUES (?, ?, NOW())`
+	if _, err := r.db.ExecContext(ctx, query, userID, roleID); err != nil {
+		return err
+	}
+	return r.DeleteCache(ctx, userID)
+}
+
+func (r *rbacRepo) RemoveRole(ctx context.Context, userID int, role string) error {
+	roleID, err := r.roleID(ctx, role)
+	if err != nil {
+		return err
+	}
+	query := `DELETE FROM user_roles WHERE user_id = ? AND role_id = ?`
+	if _, err := r.db.ExecContext(ctx, query, userID, roleID); err != nil {
+		return err
+	}
+	return r.DeleteCache(ctx, userID)
+}
+
+func (r *rbacRepo) DeleteCache(ctx context.Context, userID int) error {
+	return r.redis.Del(ctx, fmt.Sprintf("rbac:perms:%d", userID)).Err()
+}
+
+func (r *rbacRepo) roleID(ctx context.Context, role string) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, "SELECT id FROM roles WHERE name = ?", role).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrRoleNotFound
+	}
+	return id, err
+}
+
+func (r *rbacRepo) queryStrings(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newRBACTestRepo(t *testing.T) (RBACRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	_, rdb := newTestRedis(t)
+	return NewRBACRepository(db, rdb), mock
+}
+
+func expectPermissions(mock sqlmock.Sqlmock, userID int, perms ...string) {
+	rows := sqlmock.NewRows([]string{"permission"})
+	for _, p := range perms {
+		rows.AddRow(p)
+	}
+	mock.ExpectQuery("SELECT DISTINCT rp.permission").WithArgs(userID).WillReturnRows(rows)
+}
+
+func TestGetUserPermissionsCached(t *testing.T) {
+	repo, mock := newRBACTestRepo(t)
+	ctx := context.Background()
+
+	// 只查询一次数据库，之后命中缓存（sqlmock 对未声明的查询报错）
+	expectPermissions(mock, 1, "user:read", "user:suspend")
+	for i := 0; i < 3; i++ {
+		perms, err := repo.GetUserPermissions(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(perms, []string{"user:read", "user:suspend"}) {
+			t.Fatalf("perms = %v", perms)
+		}
+	}
+
+	// 没有角色的用户同样缓存
+	expectPermissions(mock, 2)
+	for i := 0; i < 2; i++ {
+		perms, err := repo.GetUserPermissions(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perms == nil || len(perms) != 0 {
+			t.Fatalf("perms = %#v, want empty slice", perms)
+		}
+	}
+}
+
+func TestRoleChangeInvalidatesPermissionCache(t *testing.T) {
+	repo, mock := newRBACTestRepo(t)
+	ctx := context.Background()
+
+	expectPermissions(mock, 1)
+	if _, err := repo.GetUserPermissions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery("SELECT id FROM roles").WithArgs("admin").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT IGNORE INTO user_roles").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.AssignRole(ctx, 1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	expectPermissions(mock, 1, "user:read")
+	perms, err := repo.GetUserPermissions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(perms, []string{"user:read"}) {
+		t.Fatalf("perms after assign = %v", perms)
+	}
+
+	mock.ExpectQuery("SELECT id FROM roles").WithArgs("admin").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("DELETE FROM user_roles").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.RemoveRole(ctx, 1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	expectPermissions(mock, 1)
+	if perms, err = repo.GetUserPermissions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(perms) != 0 {
+		t.Fatalf("perms after remove = %v", perms)
+	}
+}
+
+func TestAssignUnknownRole(t *testing.T) {
+	repo, mock := newRBACTestRepo(t)
+	ctx := context.Background()
+
+	expectPermissions(mock, 1, "user:read")
+	if _, err := repo.GetUserPermissions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery("SELECT id FROM roles").WithArgs("root").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if err := repo.AssignRole(ctx, 1, "root"); !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("got %v, want ErrRoleNotFound", err)
+	}
+	// 失败的分配不影响缓存
+	if _, err := repo.GetUserPermissions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrSessionNotFound 会话不存在（已退出登录、已被移除或已过期）
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrRefreshReused Refresh Token 被重复使用，整个会话已被吊销
+	ErrRefreshReused = errors.New("refresh token reused")
+)
+
+// TokenRepository Token 吊销与登录会话相关的 Redis 存储
+type TokenRepository interface {
+	// 吊销单个 Token，ttl 为 Token 的剩余有效期，过期后 Key 自动清理
+	Revoke(ctx context.Context, jti string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+
+	// Token 代数：退出所有设备时自增，小于当前代数的 Token 一律视为失效
+	GetGeneration(ctx context.Context, userID int) (int64, error)
+	IncrGeneration(ctx context.Context, userID int) (int64, error)
+
+	// 会话：一次登录对应一个会话，记录设备信息与当前唯一有效的 Refresh Token jti
+	CreateSession(ctx context.Context, sess *model.Session, refreshID string, ttl time.Duration) error
+	// RotateSession 同时延长用户会话索引的有效期，使其不早于会话本身过期
+	RotateSession(ctx context.Context, userID int, sessionID, oldRefreshID, newRefreshID string, ttl time.Duration) error
+	// TouchSession 刷新最后活跃时间（间隔小于 minInterval 时跳过写入），返回会话是否存在
+	TouchSession(ctx context.Context, sessionID string, minInterval time.Duration) (bool, error)
+	GetSession(ctx context.Context, sessionID string) (*model.Session, error)
+	ListSessions(ctx context.Context, userID int) ([]model.Session, error)
+	DeleteSession(ctx context.Context, userID int, sessionID string) error
+	DeleteAllSessions(ctx context.Context, userID int) error
+}
+
+type tokenRepo struct {
+	redis *redis.Client
+}
+
+func NewTokenRepository(rdb *redis.Client) TokenRepository {
+	return &tokenRepo{redis: rdb}
+}
+
+func (r *tokenRepo) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil // 已自然过期，无需记录
+	}
+	return r.redis.Set(ctx, fmt.Sprintf("token:revoked:%s", jti), 1, ttl).Err()
+}
+
+func (r *tokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := r.redis.Exists(ctx, fmt.Sprintf("token:revoked:%s", jti)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *tokenRepo) GetGeneration(ctx context.Context, userID int) (int64, error) {
+	gen, err := r.redis.Get(ctx, fmt.Sprintf("token:gen:%d", userID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return gen, err
+}
+
+func (r *tokenRepo) IncrGeneration(ctx context.Context, userID int) (int64, error) {
+	return r.redis.Incr(ctx, fmt.Sprintf("token:gen:%d", userID)).Result()
+}
+
+// --- 会话 (Refresh Token 轮换) ---
+// token:session:{id}     Hash，会话详情与当前 refresh_id
+// token:sessions:{uid}   Set，用户的会话 ID 索引（列表时顺带清理已过期的会话）
+
+// rotateScript 原子地比较并替换会话内当前 Refresh Token，并续期会话与用户会话索引
+// KEYS[1] 会话，KEYS[2] 用户会话索引；ARGV: old, new, ttl(ms), now, session_id
+// 返回 1: 轮换成功; 0: 会话不存在; -1: 旧 Token 被重放，已删除整个会话
+var rotateScript = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], 'refresh_id')
+if not cur then
+	return 0
+end
+if cur ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	redis.call('SREM', KEYS[2], ARGV[5])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'refresh_id', ARGV[2], 'last_seen', ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+redis.call('SADD', KEYS[2], ARGV[5])
+if redis.call('PTTL', KEYS[2]) < tonumber(ARGV[3]) then
+	redis.call('PEXPIRE', KEYS[2], ARGV[3])
+end
+return 1
+`)
+
+// touchScript 会话存在时按最小间隔更新 last_seen，返回 0 表示会话不存在
+var touchScript = redis.NewScript(`
+local last = redis.call('HGET', KEYS[1], 'last_seen')
+if not last then
+	return 0
+end
+if tonumber(ARGV[1]) - tonumber(last) >= tonumber(ARGV[2]) then
+	redis.call('HSET', KEYS[1], 'last_seen', ARGV[1])
+end
+return 1
+`)
+
+func sessionKey(sessionID string) string {
+	return fmt.Sprintf("token:session:%s", sessionID)
+}
+
+func userSessionsKey(userID int) string {
+	return fmt.Sprintf("token:sessions:%d", userID)
+}
+
+func (r *tokenRepo) CreateSession(ctx context.Context, sess *model.Session, refreshID string, ttl time.Duration) error {
+	key := sessionKey(sess.ID)
+	pipe := r.redis.TxPipeline()
+	pipe.HSet(ctx, key,
+		"user_id", sess.UserID,
+		"refresh_id", refreshID,
+		"device", sess.Device,
+		"user_agent", sess.UserAgent,
+		"ip", sess.IP,
+		"client_id", sess.ClientID,
+		"scope", sess.Scope,
+		"created_at", sess.CreatedAt.Unix(),
+		"last_seen", sess.LastSeen.Unix(),
+	)
+	pipe.Expire(ctx, key, ttl)
+	pipe.SAdd(ctx, userSessionsKey(sess.UserID), sess.ID)
+	pipe.Expire(ctx, userSessionsKey(sess.UserID), ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *tokenRepo) RotateSession(ctx context.Context, userID int, sessionID, oldRefreshID, newRefreshID string, ttl time.Duration) error {
+	res, err := rotateScript.Run(ctx, r.redis, []string{sessionKey(sessionID), userSessionsKey(userID)},
+		oldRefreshID, newRefreshID, ttl.Milliseconds(), time.Now().Unix(), sessionID).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 1:
+		return nil
+	case -1:
+		return ErrRefreshReused
+	default:
+		return ErrSessionNotFound
+	}
+}
+
+func (r *tokenRepo) TouchSession(ctx context.Context, sessionID string, minInterval time.Duration) (bool, error) {
+	res, err := touchScript.Run(ctx, r.redis, []string{sessionKey(sessionID)},
+		time.Now().Unix(), int64(minInterval.Seconds())).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+func (r *tokenRepo) GetSession(ctx context.Context, sessionID string) (*model.Session, error) {
+	fields, err := r.redis.HGetAll(ctx, sessionKey(sessionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return sessionFromHash(sessionID, fields), nil
+}
+
+func (r *tokenRepo) ListSessions(ctx context.Context, userID int) ([]model.Session, error) {
+	ids, err := r.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := r.redis.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, sessionKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	sessions := make([]model.Session, 0, len(ids))
+	var stale []interface{}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			stale = append(stale, ids[i]) // 会话已过期或被吊销，清理索引
+			continue
+		}
+		sessions = append(sessions, *sessionFromHash(ids[i], fields))
+	}
+	if len(stale) > 0 {
+		r.redis.SRem(ctx, userSessionsKey(userID), stale...)
+	}
+	return sessions, nil
+}
+
+func (r *tokenRepo) DeleteSession(ctx context.Context, userID int, sessionID string) error {
+	pipe := r.redis.TxPipeline()
+	pipe.Del(ctx, sessionKey(sessionID))
+	pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *tokenRepo) DeleteAllSessions(ctx context.Context, userID int) error {
+	ids, err := r.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id))
+	}
+	keys = append(keys, userSessionsKey(userID))
+	return r.redis.Del(ctx, keys...).Err()
+}
+
+func sessionFromHash(id string, f map[string]string) *model.Session {
+	userID, _ := strconv.Atoi(f["user_id"])
+	createdAt, _ := strconv.ParseInt(f["created_at"], 10, 64)
+	lastSeen, _ := strconv.ParseInt(f["last_seen"], 10, 64)
+	return &model.Session{
+		ID:        id,
+		UserID:    userID,
+		Device:    f["device"],
+		UserAgent: f["user_agent"],
+		IP:        f["ip"],
+		ClientID:  f["client_id"],
+		Scope:     f["scope"],
+		CreatedAt: time.Unix(createdAt, 0),
+		LastSeen:  time.Unix(lastSeen, 0),
+	}
+}
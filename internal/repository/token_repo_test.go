@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, rdb
+}
+
+func createSession(t *testing.T, repo TokenRepository, userID int, id, refreshID string, ttl time.Duration) {
+	t.Helper()
+	now := time.Now()
+	sess := &model.Session{ID: id, UserID: userID, Device: "test", CreatedAt: now, LastSeen: now}
+	if err := repo.CreateSession(context.Background(), sess, refreshID, ttl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRotateSessionExtendsIndex 会话通过刷新续期后，用户会话索引不能先于会话过期
+func TestRotateSessionExtendsIndex(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	repo := NewTokenRepository(rdb)
+	ctx := context.Background()
+	const ttl = time.Hour
+
+	createSession(t, repo, 1, "s1", "r1", ttl)
+	mr.FastForward(50 * time.Minute)
+	if err := repo.RotateSession(ctx, 1, "s1", "r1", "r2", ttl); err != nil {
+		t.Fatal(err)
+	}
+	if got := mr.TTL(userSessionsKey(1)); got != ttl {
+		t.Fatalf("index ttl after rotation = %v, want %v", got, ttl)
+	}
+
+	// 超过创建时的有效期，会话仍有效，且仍出现在会话列表中
+	mr.FastForward(30 * time.Minute)
+	if sess, _ := repo.GetSession(ctx, "s1"); sess == nil {
+		t.Fatal("session expired despite rotation")
+	}
+	sessions, err := repo.ListSessions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "s1" {
+		t.Fatalf("ListSessions = %+v, want [s1]", sessions)
+	}
+}
+
+func TestRotateSessionDoesNotShortenIndex(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	repo := NewTokenRepository(rdb)
+	createSession(t, repo, 1, "long", "r1", 7*24*time.Hour)
+	createSession(t, repo, 1, "short", "r2", time.Hour)
+	// 索引有效期取各会话中最晚的过期时间，轮换较短的会话不会缩短索引
+	if err := repo.RotateSession(context.Background(), 1, "long", "r1", "r3", 7*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RotateSession(context.Background(), 1, "short", "r2", "r4", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := mr.TTL(userSessionsKey(1)); got != 7*24*time.Hour {
+		t.Fatalf("index ttl shortened to %v", got)
+	}
+}
+
+func TestRotateSessionReuse(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	repo := NewTokenRepository(rdb)
+	ctx := context.Background()
+	createSession(t, repo, 1, "s1", "r1", time.Hour)
+
+	if err := repo.RotateSession(ctx, 1, "s1", "r1", "r2", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RotateSession(ctx, 1, "s1", "r1", "r3", time.Hour); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("replay: got %v, want ErrRefreshReused", err)
+	}
+	// 重放后会话与索引成员一并删除
+	if mr.Exists(sessionKey("s1")) {
+		t.Fatal("session kept after reuse")
+	}
+	if ok, _ := mr.SIsMember(userSessionsKey(1), "s1"); ok {
+		t.Fatal("index member kept after reuse")
+	}
+	if err := repo.RotateSession(ctx, 1, "s1", "r2", "r4", time.Hour); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("rotate deleted session: got %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestListSessionsPrunesExpired(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	repo := NewTokenRepository(rdb)
+	createSession(t, repo, 1, "old", "r1", time.Hour)
+	mr.FastForward(30 * time.Minute)
+	createSession(t, repo, 1, "new", "r2", time.Hour)
+	mr.FastForward(45 * time.Minute)
+
+	sessions, err := repo.ListSessions(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "new" {
+		t.Fatalf("ListSessions = %+v, want [new]", sessions)
+	}
+	if ok, _ := mr.SIsMember(userSessionsKey(1), "old"); ok {
+		t.Fatal("expired session not pruned from index")
+	}
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// userEventStream 用户变更事件流，按 max_len 近似裁剪
+const userEventStream = "user:events"
+
+// UserEventRepository 用户变更事件 (Redis Stream)
+type UserEventRepository interface {
+	// Publish 追加事件，回填 e.ID
+	Publish(ctx context.Context, e *model.UserEvent) error
+	// Read 阻塞读取 afterID 之后的事件，超时无事件时返回空
+	Read(ctx context.Context, afterID string, count int64, block time.Duration) ([]model.UserEvent, error)
+	// Range 非阻塞读取 afterID 之后的事件，用于断线续传时补发
+	Range(ctx context.Context, afterID string, count int64) ([]model.UserEvent, error)
+	// FirstID / LastID 流中最早 / 最新的事件 ID，流为空时返回 ""
+	FirstID(ctx context.Context) (string, error)
+	LastID(ctx context.Context) (string, error)
+}
+
+type userEventRepo struct {
+	redis  *redis.Client
+	maxLen int64
+}
+
+func NewUserEventRepository(rdb *redis.Client, maxLen int64) UserEventRepository {
+	return &userEventRepo{redis: rdb, maxLen: maxLen}
+}
+
+func (r *userEventRepo) Publish(ctx context.Context, e *model.UserEvent) error {
+	id, err := r.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: userEventStream,
+		MaxLen: r.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"user_id": e.UserID,
+			"type":    e.Type,
+			"at":      e.OccurredAt.UnixMilli(),
+		},
+	}).Result()
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *userEventRepo) Read(ctx context.Context, afterID string, count int64, block time.Duration) ([]model.UserEvent, error) {
+	streams, err := r.redis.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{userEventStream, afterID},
+		Count:   count,
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var events []model.UserEvent
+	for _, s := range streams {
+		events = append(events, toUserEvents(s.Messages)...)
+	}
+	return events, nil
+}
+
+func (r *userEventRepo) Range(ctx context.Context, afterID string, count int64) ([]model.UserEvent, error) {
+	msgs, err := r.redis.XRangeN(ctx, userEventStream, "("+afterID, "+", count).Result()
+	if err != nil {
+		return nil, err
+	}
+	return toUserEvents(msgs), nil
+}
+
+func (r *userEventRepo) FirstID(ctx context.Context) (string, error) {
+	msgs, err := r.redis.XRangeN(ctx, userEventStream, "-", "+", 1).Result()
+	if err != nil || len(msgs) == 0 {
+		return "", err
+	}
+	return msgs[0].ID, nil
+}
+
+func (r *userEventRepo) LastID(ctx context.Context) (string, error) {
+	msgs, err := r.redis.XRevRangeN(ctx, userEventStream, "+", "-", 1).Result()
+	if err != nil || len(msgs) == 0 {
+		return "", err
+	}
+	return msgs[0].ID, nil
+}
+
+func toUserEvents(msgs []redis.XMessage) []model.UserEvent {
+	events := make([]model.UserEvent, 0, len(msgs))
+	for _, m := range msgs {
+		userID, _ := strconv.Atoi(toString(m.Values["user_id"]))
+		at, _ := strconv.ParseInt(toString(m.Values["at"]), 10, 64)
+		events = append(events, model.UserEvent{
+			ID:         m.ID,
+			UserID:     userID,
+			Type:       toString(m.Values["type"]),
+			OccurredAt: time.UnixMilli(at),
+		})
+	}
+	return events
+}
+
+func toString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// CompareEventID 比较两个 Stream 消息 ID (<ms>-<seq>)，a < b 返回 -1，相等返回 0，a > b 返回 1
+func CompareEventID(a, b string) int {
+	ams, aseq := splitEventID(a)
+	bms, bseq := splitEventID(b)
+	switch {
+	case ams != bms:
+		if ams < bms {
+			return -1
+		}
+		return 1
+	case aseq != bseq:
+		if aseq < bseq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func splitEventID(id string) (uint64, uint64) {
+	msStr, seqStr, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msStr, 10, 64)
+	seq, _ := strconv.ParseUint(seqStr, 10, 64)
+	return ms, seq
+}
+
+// ValidEventID 是否为合法的 Stream 消息 ID
+func ValidEventID(id string) bool {
+	msStr, seqStr, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	_, err1 := strconv.ParseUint(msStr, 10, 64)
+	_, err2 := strconv.ParseUint(seqStr, 10, 64)
+	return err1 == nil && err2 == nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/netkey/golang-user-mysql-redis/internal/model"
@@ -15,11 +16,23 @@ type UserRepository interface {
 	Create(ctx context.Context, u *model.User) error
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
 	GetByID(ctx context.Context, id int) (*model.User, error)
+	// GetByIDs 单条 IN 查询，不保证返回顺序，不存在的 ID 不返回
+	GetByIDs(ctx context.Context, ids []int) ([]model.User, error)
 	UpdateProfile(ctx context.Context, id int, nickname string, age int, avatar string) error
+	UpdateStatus(ctx context.Context, id int, status int) error
+	UpdatePassword(ctx context.Context, id int, hashedPassword string) error
+	// List 管理后台分页查询，返回当前页与总数
+	List(ctx context.Context, f model.UserFilter) ([]model.User, int, error)
+	// Delete 删除用户及其好友关系、二次验证、角色等关联数据
+	Delete(ctx context.Context, id int) error
 
 	// 缓存操作
 	GetCache(ctx context.Context, id int) (*model.User, error)
 	SetCache(ctx context.Context, user *model.User) error
+	// GetCacheMulti MGET 批量读取缓存，只返回命中的用户
+	GetCacheMulti(ctx context.Context, ids []int) (map[int]*model.User, error)
+	// SetCacheMulti Pipeline 批量回写缓存
+	SetCacheMulti(ctx context.Context, users []model.User) error
 	DeleteCache(ctx context.Context, id int) error
 
 	// 好友操作
@@ -43,18 +56,28 @@ func (r *userRepo) Create(ctx context.Context, u *model.User) error {
 	query := `INSERT INTO users (name, nickname, email, password, age, gender, avatar, status, created_at, updated_at) 
               VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`
 
-	_, err := r.db.ExecContext(ctx, query,
+	res, err := r.db.ExecContext(ctx, query,
 		u.Name, u.Nickname, u.Email, u.Password, u.Age, u.Gender, u.Avatar, u.Status,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// 回填自增 ID，便于注册后续流程（如发送验证邮件）使用
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	u.ID = int(id)
+	return nil
 }
 
 func (r *userRepo) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	var u model.User
-	query := "SELECT id, name, nickname, email, password, age, gender, avatar, status FROM users WHERE email = ? LIMIT 1"
+	query := "SELECT id, name, nickname, email, password, age, gender, avatar, status, created_at, updated_at FROM users WHERE email = ? LIMIT 1"
 
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&u.ID, &u.Name, &u.Nickname, &u.Email, &u.Password, &u.Age, &u.Gender, &u.Avatar, &u.Status,
+		&u.ID, &u.Name, &u.Nickname, &u.Email, &u.Password, &u.Age, &u.Gender, &u.Avatar, &u.Status, &u.CreatedAt, &u.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -64,20 +87,132 @@ func (r *userRepo) GetByEmail(ctx context.Context, email string) (*model.User, e
 
 func (r *userRepo) GetByID(ctx context.Context, id int) (*model.User, error) {
 	var u model.User
-	query := "SELECT id, name, nickname, email, age, gender, avatar, status FROM users WHERE id = ?"
+	query := "SELECT id, name, nickname, email, password, age, gender, avatar, status, created_at, updated_at FROM users WHERE id = ?"
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&u.ID, &u.Name, &u.Nickname, &u.Email, &u.Age, &u.Gender, &u.Avatar, &u.Status,
+		&u.ID, &u.Name, &u.Nickname, &u.Email, &u.Password, &u.Age, &u.Gender, &u.Avatar, &u.Status, &u.CreatedAt, &u.UpdatedAt,
 	)
 	return &u, err
 }
 
+func (r *userRepo) GetByIDs(ctx context.Context, ids []int) ([]model.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := `SELECT id, name, nickname, email, password, age, gender, avatar, status, created_at, updated_at
+              FROM users WHERE id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]model.User, 0, len(ids))
+	for rows.Next() {
+		var u model.User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Nickname, &u.Email, &u.Password, &u.Age, &u.Gender, &u.Avatar, &u.Status, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
 func (r *userRepo) UpdateProfile(ctx context.Context, id int, nickname string, age int, avatar string) error {
 	query := `UPDATE users SET nickname = ?, age = ?, avatar = ?, updated_at = NOW() WHERE id = ?`
 	_, err := r.db.ExecContext(ctx, query, nickname, age, avatar, id)
 	return err
 }
 
+func (r *userRepo) UpdateStatus(ctx context.Context, id int, status int) error {
+	query := `UPDATE users SET status = ?, updated_at = NOW() WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, status, id)
+	return err
+}
+
+func (r *userRepo) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	query := `UPDATE users SET password = ?, updated_at = NOW() WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, hashedPassword, id)
+	return err
+}
+
+func (r *userRepo) List(ctx context.Context, f model.UserFilter) ([]model.User, int, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	if f.Keyword != "" {
+		// 转义 LIKE 通配符，关键字按字面量匹配
+		kw := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Keyword) + "%"
+		where = append(where, "(name LIKE ? OR nickname LIKE ? OR email LIKE ?)")
+		args = append(args, kw, kw, kw)
+	}
+	if f.Status != 0 {
+		where = append(where, "status = ?")
+		args = append(args, f.Status)
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE "+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, name, nickname, email, age, gender, avatar, status, created_at, updated_at
+              FROM users WHERE ` + cond + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	rows, err := r.db.QueryContext(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]model.User, 0, f.Limit)
+	for rows.Next() {
+		var u model.User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Nickname, &u.Email, &u.Age, &u.Gender, &u.Avatar, &u.Status, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	return users, total, rows.Err()
+}
+
+func (r *userRepo) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM friends WHERE user_id = ? OR friend_id = ?", []interface{}{id, id}},
+		{"DELETE FROM user_recovery_codes WHERE user_id = ?", []interface{}{id}},
+		{"DELETE FROM user_mfa WHERE user_id = ?", []interface{}{id}},
+		{"DELETE FROM user_roles WHERE user_id = ?", []interface{}{id}},
+		{"DELETE FROM linked_identities WHERE user_id = ?", []interface{}{id}},
+		{"DELETE FROM api_keys WHERE user_id = ?", []interface{}{id}},
+	}
+	for _, st := range stmts {
+		if _, err := tx.ExecContext(ctx, st.query, st.args...); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
 // --- 好友操作 ---
 
 func (r *userRepo) AddFriend(ctx context.Context, userID, friendID int) error {
@@ -99,7 +234,7 @@ func (r *userRepo) AddFriend(ctx context.Context, userID, friendID int) error {
 
 func (r *userRepo) GetFriends(ctx context.Context, userID int) ([]model.User, error) {
 	query := `
-		SELECT u.id, u.name, u.nickname, u.email, u.avatar 
+		SELECT u.id, u.name, u.nickname, u.email, u.age, u.gender, u.avatar, u.status, u.created_at, u.updated_at
 		FROM users u
 		INNER JOIN friends f ON u.id = f.friend_id
 		WHERE f.user_id = ? AND f.status = 2`
@@ -113,7 +248,7 @@ func (r *userRepo) GetFriends(ctx context.Context, userID int) ([]model.User, er
 	var friends []model.User
 	for rows.Next() {
 		var f model.User
-		if err := rows.Scan(&f.ID, &f.Name, &f.Nickname, &f.Email, &f.Avatar); err != nil {
+		if err := rows.Scan(&f.ID, &f.Name, &f.Nickname, &f.Email, &f.Age, &f.Gender, &f.Avatar, &f.Status, &f.CreatedAt, &f.UpdatedAt); err != nil {
 			return nil, err
 		}
 		friends = append(friends, f)
@@ -138,6 +273,47 @@ func (r *userRepo) SetCache(ctx context.Context, user *model.User) error {
 	return r.redis.Set(ctx, fmt.Sprintf("user:%d", user.ID), data, 15*time.Minute).Err()
 }
 
+func (r *userRepo) GetCacheMulti(ctx context.Context, ids []int) (map[int]*model.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = fmt.Sprintf("user:%d", id)
+	}
+	vals, err := r.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[int]*model.User, len(ids))
+	for _, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue // 未命中
+		}
+		var user model.User
+		if err := json.Unmarshal([]byte(s), &user); err != nil || user.ID == 0 {
+			continue
+		}
+		users[user.ID] = &user
+	}
+	return users, nil
+}
+
+func (r *userRepo) SetCacheMulti(ctx context.Context, users []model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	pipe := r.redis.Pipeline()
+	for i := range users {
+		data, _ := json.Marshal(&users[i])
+		pipe.Set(ctx, fmt.Sprintf("user:%d", users[i].ID), data, 15*time.Minute)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
 func (r *userRepo) DeleteCache(ctx context.Context, id int) error {
 	return r.redis.Del(ctx, fmt.Sprintf("user:%d", id)).Err()
 }
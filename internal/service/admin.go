@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// 分页参数限制
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	ErrAccountSuspended = errors.New("账号已被停用")
+	ErrUserNotFound     = errors.New("用户不存在")
+	ErrRoleNotFound     = errors.New("角色不存在")
+	// ErrOperateSelf 管理员不能停用、删除自己或修改自己的角色，避免误操作导致无人可管理
+	ErrOperateSelf = errors.New("不能对自己执行该操作")
+)
+
+// HasPermission 判断用户是否拥有指定权限（HTTP / gRPC 授权中间件使用）
+// 使用 API Key 的请求还要求该权限在 Key 的 scope 内
+func (s *UserService) HasPermission(ctx context.Context, userID int, perm string) (bool, error) {
+	if !apiKeyAllows(ctx, perm) {
+		return false, nil
+	}
+	perms, err := s.rbacRepo.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(perms, perm), nil
+}
+
+// ListUsers 管理后台分页查询用户，page 从 1 开始
+func (s *UserService) ListUsers(ctx context.Context, keyword string, status, page, size int) ([]model.User, int, error) {
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	size = min(size, maxPageSize)
+	page = max(page, 1)
+
+	return s.repo.List(ctx, model.UserFilter{
+		Keyword: keyword,
+		Status:  status,
+		Offset:  (page - 1) * size,
+		Limit:   size,
+	})
+}
+
+// SuspendUser 停用用户：禁止登录并立即下线所有设备
+func (s *UserService) SuspendUser(ctx context.Context, operatorID, userID int) error {
+	if err := s.checkAdminTarget(ctx, operatorID, userID); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateStatus(ctx, userID, model.UserStatusSuspended); err != nil {
+		return err
+	}
+	_ = s.repo.DeleteCache(ctx, userID)
+	s.publishUserEvent(ctx, userID, model.UserEventStatusChanged)
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	logger.Log.Info("用户已停用", zap.Int("user_id", userID), zap.Int("operator_id", operatorID))
+	return nil
+}
+
+// ReactivateUser 恢复已停用的用户
+func (s *UserService) ReactivateUser(ctx context.Context, operatorID, userID int) error {
+	if err := s.checkAdminTarget(ctx, operatorID, userID); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateStatus(ctx, userID, model.UserStatusNormal); err != nil {
+		return err
+	}
+	_ = s.repo.DeleteCache(ctx, userID)
+	s.publishUserEvent(ctx, userID, model.UserEventStatusChanged)
+
+	logger.Log.Info("用户已恢复", zap.Int("user_id", userID), zap.Int("operator_id", operatorID))
+	return nil
+}
+
+// DeleteUser 删除用户及其关联数据，并吊销其全部 Token
+func (s *UserService) DeleteUser(ctx context.Context, operatorID, userID int) error {
+	if err := s.checkAdminTarget(ctx, operatorID, userID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	_ = s.repo.DeleteCache(ctx, userID)
+	_ = s.rbacRepo.DeleteCache(ctx, userID)
+	s.publishUserEvent(ctx, userID, model.UserEventDeleted)
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	logger.Log.Info("用户已删除", zap.Int("user_id", userID), zap.Int("operator_id", operatorID))
+	return nil
+}
+
+// ListRoles 列出所有角色及其权限
+func (s *UserService) ListRoles(ctx context.Context) ([]model.Role, error) {
+	return s.rbacRepo.ListRoles(ctx)
+}
+
+// GetUserRoles 查询用户拥有的角色
+func (s *UserService) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	return s.rbacRepo.GetUserRoles(ctx, userID)
+}
+
+// AssignRole 为用户分配角色
+func (s *UserService) AssignRole(ctx context.Context, operatorID, userID int, role string) error {
+	if err := s.checkAdminTarget(ctx, operatorID, userID); err != nil {
+		return err
+	}
+	if err := s.rbacRepo.AssignRole(ctx, userID, role); err != nil {
+		if errors.Is(err, repository.ErrRoleNotFound) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+
+	logger.Log.Info("已分配角色", zap.Int("user_id", userID), zap.String("role", role), zap.Int("operator_id", operatorID))
+	return nil
+}
+
+// RemoveRole 移除用户的角色
+func (s *UserService) RemoveRole(ctx context.Context, operatorID, userID int, role string) error {
+	if err := s.checkAdminTarget(ctx, operatorID, userID); err != nil {
+		return err
+	}
+	if err := s.rbacRepo.RemoveRole(ctx, userID, role); err != nil {
+		if errors.Is(err, repository.ErrRoleNotFound) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+
+	logger.Log.Info("已移除角色", zap.Int("user_id", userID), zap.String("role", role), zap.Int("operator_id", operatorID))
+	return nil
+}
+
+// checkAdminTarget 校验目标用户存在且不是操作者本人
+func (s *UserService) checkAdminTarget(ctx context.Context, operatorID, userID int) error {
+	if operatorID == userID {
+		return ErrOperateSelf
+	}
+	if _, err := s.repo.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
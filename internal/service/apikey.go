@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// apiKeyTouchInterval 最后使用时间的最小更新间隔，避免每个请求都写库
+	apiKeyTouchInterval = time.Minute
+	maxAPIKeyNameLen    = 64
+	// apiKeyCreateAttempts 前缀只有 8 位十六进制，与已有 Key 冲突时重新生成的次数
+	apiKeyCreateAttempts = 3
+)
+
+var (
+	ErrAPIKeyNotFound     = errors.New("API Key 不存在")
+	ErrAPIKeyInvalid      = errors.New("API Key 参数无效")
+	ErrAPIKeyLimitReached = errors.New("API Key 数量已达上限")
+)
+
+// CreateAPIKeyRequest 创建 API Key 的参数
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit"` // 每分钟请求数，0 表示使用默认值
+	ExpiresIn int      `json:"expires_in"` // 有效期（天），0 表示永不过期（受配置上限约束）
+}
+
+// CreatedAPIKey 创建结果，Key 明文仅此一次返回
+type CreatedAPIKey struct {
+	*model.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey 创建个人 API Key；权限点类 scope 只能选择用户当前拥有的权限
+func (s *UserService) CreateAPIKey(ctx context.Context, userID int, req CreateAPIKeyRequest) (*CreatedAPIKey, error) {
+	if err := refuseImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	cfg := s.cfg.APIKey
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > maxAPIKeyNameLen {
+		return nil, fmt.Errorf("%w: 名称不能为空且不超过 %d 个字符", ErrAPIKeyInvalid, maxAPIKeyNameLen)
+	}
+	if req.RateLimit < 0 || (cfg.MaxRateLimit > 0 && req.RateLimit > cfg.MaxRateLimit) {
+		return nil, fmt.Errorf("%w: 请求限额不能超过 %d 次/分", ErrAPIKeyInvalid, cfg.MaxRateLimit)
+	}
+	if req.ExpiresIn < 0 || (cfg.MaxTTLDays > 0 && (req.ExpiresIn == 0 || req.ExpiresIn > cfg.MaxTTLDays)) {
+		return nil, fmt.Errorf("%w: 有效期需在 1-%d 天之间", ErrAPIKeyInvalid, cfg.MaxTTLDays)
+	}
+	if err := s.checkAPIKeyScopes(ctx, userID, req.Scopes); err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxPerUser > 0 {
+		n, err := s.apiKeyRepo.CountByUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if n >= cfg.MaxPerUser {
+			return nil, ErrAPIKeyLimitReached
+		}
+	}
+
+	k := &model.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+		RateLimit: req.RateLimit,
+		CreatedAt: time.Now(),
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := k.CreatedAt.AddDate(0, 0, req.ExpiresIn)
+		k.ExpiresAt = &expiresAt
+	}
+	// 前缀与已有 Key 冲突时重新生成
+	var key string
+	for attempt := 1; ; attempt++ {
+		var err error
+		if key, k.Prefix, err = utils.GenerateAPIKey(); err != nil {
+			return nil, err
+		}
+		k.KeyHash = utils.SHA256Hex(key)
+		err = s.apiKeyRepo.Create(ctx, k)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, repository.ErrAPIKeyPrefixExists) || attempt == apiKeyCreateAttempts {
+			return nil, err
+		}
+	}
+	logger.Log.Info("API Key 已创建", zap.Int("user_id", userID), zap.String("prefix", k.Prefix), zap.Strings("scopes", k.Scopes))
+	return &CreatedAPIKey{APIKey: k, Key: key}, nil
+}
+
+// checkAPIKeyScopes scope 只能是 read / write 或用户当前拥有的权限点
+func (s *UserService) checkAPIKeyScopes(ctx context.Context, userID int, scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: 至少指定一个 scope", ErrAPIKeyInvalid)
+	}
+	var perms []string
+	for _, scope := range scopes {
+		if scope == model.APIKeyScopeRead || scope == model.APIKeyScopeWrite {
+			continue
+		}
+		if perms == nil {
+			var err error
+			if perms, err = s.rbacRepo.GetUserPermissions(ctx, userID); err != nil {
+				return err
+			}
+		}
+		if !slices.Contains(perms, scope) {
+			return fmt.Errorf("%w: 无效的 scope %q", ErrAPIKeyInvalid, scope)
+		}
+	}
+	return nil
+}
+
+func (s *UserService) ListAPIKeys(ctx context.Context, userID int) ([]model.APIKey, error) {
+	keys, err := s.apiKeyRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []model.APIKey{}
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey 删除用户自己的 API Key，立即失效
+func (s *UserService) RevokeAPIKey(ctx context.Context, userID, id int) error {
+	if err := refuseImpersonation(ctx); err != nil {
+		return err
+	}
+	if err := s.apiKeyRepo.Delete(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+	logger.Log.Info("API Key 已删除", zap.Int("user_id", userID), zap.Int("id", id))
+	return nil
+}
+
+// LookupAPIKey 校验 API Key 本身（格式、哈希、有效期），不检查所属用户状态
+// 限流中间件据此按 Key 计数
+func (s *UserService) LookupAPIKey(ctx context.Context, key string) (*model.APIKey, error) {
+	prefix, ok := utils.APIKeyPrefixOf(key)
+	if !ok {
+		return nil, utils.ErrInvalidToken
+	}
+	k, err := s.apiKeyRepo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if k == nil || subtle.ConstantTimeCompare([]byte(k.KeyHash), []byte(utils.SHA256Hex(key))) != 1 {
+		return nil, utils.ErrInvalidToken
+	}
+	if k.Expired(time.Now()) {
+		return nil, utils.ErrInvalidToken
+	}
+	return k, nil
+}
+
+// validateAPIKey API Key 鉴权：构造与 JWT 相同的 Claims，scope 为 Key 的授权范围
+func (s *UserService) validateAPIKey(ctx context.Context, key string) (*utils.Claims, error) {
+	k, err := s.LookupAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已停用或已删除的用户，其 Key 一并失效
+	user, err := s.GetUser(ctx, k.UserID)
+	if err != nil || user.Status == model.UserStatusSuspended {
+		return nil, utils.ErrInvalidToken
+	}
+
+	if k.LastUsedAt == nil || time.Since(*k.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeyRepo.TouchLastUsed(ctx, k); err != nil {
+			logger.Log.Warn("更新 API Key 使用时间失败", zap.String("prefix", k.Prefix), zap.Error(err))
+		}
+	}
+
+	return &utils.Claims{
+		Type:  utils.TokenTypeAPIKey,
+		Scope: strings.Join(k.Scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:      k.Prefix,
+			Subject: strconv.Itoa(k.UserID),
+		},
+	}, nil
+}
+
+// apiKeyAllows API Key 请求的权限点需同时在 Key 的 scope 内；JWT 请求不受影响
+func apiKeyAllows(ctx context.Context, perm string) bool {
+	claims, ok := utils.ClaimsFromContext(ctx)
+	if !ok || claims.Type != utils.TokenTypeAPIKey {
+		return true
+	}
+	return slices.Contains(strings.Fields(claims.Scope), perm)
+}
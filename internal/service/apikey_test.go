@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+func withAPIKeys(c *config.Config) {
+	c.APIKey = config.APIKeyConfig{MaxPerUser: 2, MaxRateLimit: 1000, MaxTTLDays: 365}
+}
+
+func TestAPIKeyAuthenticates(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	created, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "ci", Scopes: []string{"write", "read", "read"}, ExpiresIn: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := env.svc.ValidateToken(ctx, created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Type != utils.TokenTypeAPIKey || claims.UserID() != u.ID || claims.Scope != "read write" {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	// 只保存哈希：同前缀但密文不同的 Key 无效
+	if _, err := env.svc.ValidateToken(ctx, created.Prefix+"_forged"); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("forged key: got %v", err)
+	}
+	if stored, _ := env.apiKeys.GetByPrefix(ctx, created.Prefix); stored.KeyHash != utils.SHA256Hex(created.Key) {
+		t.Fatal("key hash not stored")
+	}
+
+	env.apiKeys.expire(created.Prefix)
+	if _, err := env.svc.ValidateToken(ctx, created.Key); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("expired key: got %v", err)
+	}
+}
+
+func TestAPIKeyRevokedWithUser(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	created, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "ci", Scopes: []string{"read"}, ExpiresIn: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "cron", Scopes: []string{"read"}, ExpiresIn: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只能删除自己的 Key，删除后立即失效
+	if err := env.svc.RevokeAPIKey(ctx, u.ID+1, created.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("revoke other user's key: got %v", err)
+	}
+	if err := env.svc.RevokeAPIKey(ctx, u.ID, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, created.Key); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("revoked key: got %v", err)
+	}
+
+	// 用户停用后其 Key 一并失效
+	if err := env.users.UpdateStatus(ctx, u.ID, model.UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+	_ = env.users.DeleteCache(ctx, u.ID)
+	if _, err := env.svc.ValidateToken(ctx, other.Key); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("key of suspended user: got %v", err)
+	}
+}
+
+func TestCreateAPIKeyRetriesPrefixCollision(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	env.apiKeys.collisions = apiKeyCreateAttempts - 1
+	created, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "ci", Scopes: []string{"read"}, ExpiresIn: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, created.Key); err != nil {
+		t.Fatalf("key created after retry: %v", err)
+	}
+
+	// 连续冲突超过重试次数时返回错误，不写入半成品
+	env.apiKeys.collisions = apiKeyCreateAttempts
+	if _, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "cron", Scopes: []string{"read"}, ExpiresIn: 30}); !errors.Is(err, repository.ErrAPIKeyPrefixExists) {
+		t.Fatalf("persistent collision: got %v", err)
+	}
+	if keys, _ := env.svc.ListAPIKeys(ctx, u.ID); len(keys) != 1 {
+		t.Fatalf("keys = %+v", keys)
+	}
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	invalid := []CreateAPIKeyRequest{
+		{Name: "", Scopes: []string{"read"}, ExpiresIn: 30},
+		{Name: "ci", Scopes: nil, ExpiresIn: 30},
+		{Name: "ci", Scopes: []string{"admin"}, ExpiresIn: 30},
+		{Name: "ci", Scopes: []string{model.PermUserRead}, ExpiresIn: 30}, // 用户没有该权限
+		{Name: "ci", Scopes: []string{"read"}, ExpiresIn: 0},              // 配置了有效期上限时必须指定
+		{Name: "ci", Scopes: []string{"read"}, ExpiresIn: 366},
+		{Name: "ci", Scopes: []string{"read"}, ExpiresIn: 30, RateLimit: 1001},
+	}
+	for _, req := range invalid {
+		if _, err := env.svc.CreateAPIKey(ctx, u.ID, req); !errors.Is(err, ErrAPIKeyInvalid) {
+			t.Errorf("CreateAPIKey(%+v): got %v, want ErrAPIKeyInvalid", req, err)
+		}
+	}
+
+	// 权限点 scope 只能选择用户拥有的权限
+	if err := env.rbac.AssignRole(ctx, u.ID, "support"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "ops", Scopes: []string{model.PermUserRead}, ExpiresIn: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "ci", Scopes: []string{"read"}, ExpiresIn: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "more", Scopes: []string{"read"}, ExpiresIn: 30}); !errors.Is(err, ErrAPIKeyLimitReached) {
+		t.Fatalf("over limit: got %v", err)
+	}
+
+	// 模拟登录期间不能创建
+	impersonated := utils.ContextWithClaims(ctx, &utils.Claims{Actor: &utils.Actor{Subject: "99"}})
+	if _, err := env.svc.CreateAPIKey(impersonated, u.ID, CreateAPIKeyRequest{Name: "x", Scopes: []string{"read"}, ExpiresIn: 30}); !errors.Is(err, ErrImpersonationForbidden) {
+		t.Fatalf("impersonated: got %v", err)
+	}
+}
+
+func TestHasPermissionWithAPIKey(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	if err := env.rbac.AssignRole(ctx, u.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	created, err := env.svc.CreateAPIKey(ctx, u.ID, CreateAPIKeyRequest{Name: "ops", Scopes: []string{"read", model.PermUserRead}, ExpiresIn: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := env.svc.ValidateToken(ctx, created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyCtx := utils.ContextWithClaims(ctx, claims)
+
+	// 权限取用户角色与 Key scope 的交集
+	for perm, want := range map[string]bool{model.PermUserRead: true, model.PermUserDelete: false} {
+		got, err := env.svc.HasPermission(keyCtx, u.ID, perm)
+		if err != nil || got != want {
+			t.Errorf("HasPermission(key, %s) = %v, %v; want %v", perm, got, err, want)
+		}
+	}
+	if ok, _ := env.svc.HasPermission(ctx, u.ID, model.PermUserDelete); !ok {
+		t.Error("session without key lost admin permission")
+	}
+
+	// 角色被移除后，Key 的权限点 scope 随之失效
+	if err := env.rbac.RemoveRole(ctx, u.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := env.svc.HasPermission(keyCtx, u.ID, model.PermUserRead); ok {
+		t.Error("key kept permission after role removal")
+	}
+}
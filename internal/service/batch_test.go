@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBatchGetUsersOrderAndMisses(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	a := env.createUser(t, "a@example.com", "Correct-Horse-9")
+	b := env.createUser(t, "b@example.com", "Correct-Horse-9")
+	c := env.createUser(t, "c@example.com", "Correct-Horse-9")
+
+	// 先缓存 b，其余回源；输入含重复、不存在与非法 ID
+	if _, err := env.svc.GetUser(ctx, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	env.users.dbReads = 0
+
+	ids := []int{c.ID, 999, a.ID, b.ID, c.ID, 0, -1}
+	users, err := env.svc.BatchGetUsers(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, u := range users {
+		got = append(got, u.ID)
+	}
+	if want := []int{c.ID, a.ID, b.ID}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+	// 只为未命中的 c、999、a 查一次库
+	if env.users.dbReads != 3 {
+		t.Fatalf("db reads = %d, want 3", env.users.dbReads)
+	}
+
+	// 回源结果已写入缓存，再次查询只有不存在的 ID 回源
+	env.users.dbReads = 0
+	if users, _ = env.svc.BatchGetUsers(ctx, ids); len(users) != 3 {
+		t.Fatalf("second batch = %d users", len(users))
+	}
+	if env.users.dbReads != 1 {
+		t.Fatalf("db reads on warm cache = %d, want 1", env.users.dbReads)
+	}
+}
+
+func TestBatchGetUsersLimits(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	users, err := env.svc.BatchGetUsers(ctx, nil)
+	if err != nil || users == nil || len(users) != 0 {
+		t.Fatalf("empty batch = %v, %v", users, err)
+	}
+	if _, err := env.svc.BatchGetUsers(ctx, make([]int, maxBatchGetUsers+1)); !errors.Is(err, ErrTooManyIDs) {
+		t.Fatalf("over limit: got %v", err)
+	}
+}
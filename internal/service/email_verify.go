@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/mailer"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const purposeEmailVerify = "email_verify"
+
+var (
+	// ErrEmailNotVerified 开启 block_login 时，未验证邮箱的账号禁止登录
+	ErrEmailNotVerified = errors.New("邮箱尚未验证，请先完成邮箱验证")
+	// ErrVerifyLinkInvalid 验证链接无效、已使用或已过期
+	ErrVerifyLinkInvalid = errors.New("验证链接无效或已过期")
+	// ErrResendTooFrequent 重发验证邮件过于频繁
+	ErrResendTooFrequent = errors.New("发送过于频繁，请稍后再试")
+)
+
+func (s *UserService) verifyTokenTTL() time.Duration {
+	if s.cfg.EmailVerify.TokenTTL <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(s.cfg.EmailVerify.TokenTTL) * time.Hour
+}
+
+// sendVerificationEmail 签发一次性验证 Token 并发送验证邮件
+// Token 为带签名的 JWT (typ=email_verify)，jti 存入 Redis 保证单次有效；重发会使旧链接失效
+func (s *UserService) sendVerificationEmail(ctx context.Context, user *model.User) error {
+	claims := s.jwt.NewClaims(user.ID, utils.TokenTypeEmailVerify, s.verifyTokenTTL())
+	token, err := s.jwt.Sign(claims)
+	if err != nil {
+		return err
+	}
+	if err := s.otRepo.Save(ctx, purposeEmailVerify, claims.ID, user.ID, s.verifyTokenTTL()); err != nil {
+		return err
+	}
+
+	link := s.cfg.EmailVerify.LinkURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "请验证您的邮箱",
+		Body: fmt.Sprintf("%s 您好：\n\n请在 %d 小时内点击以下链接完成邮箱验证：\n%s\n\n如非本人操作，请忽略此邮件。\n",
+			user.Nickname, int(s.verifyTokenTTL().Hours()), link),
+	})
+}
+
+// ResendVerificationEmail 重发验证邮件
+// 冷却按邮箱计算且先于查询用户：邮箱不存在、已验证与待验证时的返回完全一致，避免被用于探测注册邮箱
+func (s *UserService) ResendVerificationEmail(ctx context.Context, email string) error {
+	interval := time.Duration(s.cfg.EmailVerify.ResendInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ok, err := s.otRepo.AcquireCooldown(ctx, purposeEmailVerify, normalizeEmail(email), interval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrResendTooFrequent
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status != model.UserStatusPending {
+		return nil
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// VerifyEmail 校验验证链接中的 Token，并将账号状态置为正常
+func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.jwt.Parse(token, utils.TokenTypeEmailVerify)
+	if err != nil {
+		return ErrVerifyLinkInvalid
+	}
+
+	userID, err := s.otRepo.Consume(ctx, purposeEmailVerify, claims.ID)
+	if errors.Is(err, repository.ErrOneTimeTokenNotFound) || (err == nil && userID != claims.UserID()) {
+		return ErrVerifyLinkInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	// 只有待验证状态才转为正常，避免已停用的账号借验证链接恢复
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Status != model.UserStatusPending {
+		return nil
+	}
+	if err := s.repo.UpdateStatus(ctx, userID, model.UserStatusNormal); err != nil {
+		return err
+	}
+	_ = s.repo.DeleteCache(ctx, userID)
+	s.publishUserEvent(ctx, userID, model.UserEventStatusChanged)
+
+	logger.Log.Info("邮箱验证成功", zap.Int("user_id", userID))
+	return nil
+}
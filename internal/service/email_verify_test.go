@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+)
+
+func withEmailVerify(blockLogin bool) func(*config.Config) {
+	return func(c *config.Config) {
+		c.EmailVerify = config.EmailVerifyConfig{
+			Enable:         true,
+			BlockLogin:     blockLogin,
+			TokenTTL:       24,
+			ResendInterval: 60,
+			LinkURL:        "http://localhost/verify",
+		}
+	}
+}
+
+var linkTokenRe = regexp.MustCompile(`\?token=(\S+)`)
+
+// mailToken 取出发给 to 的最后一封邮件中链接携带的 Token
+func (e *testEnv) mailToken(t *testing.T, to string) string {
+	t.Helper()
+	msg, ok := e.mail.Last(to)
+	if !ok {
+		t.Fatalf("no mail sent to %s", to)
+	}
+	m := linkTokenRe.FindStringSubmatch(msg.Body)
+	if m == nil {
+		t.Fatalf("no link in mail: %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRegisterSendsVerificationEmail(t *testing.T) {
+	env := newTestEnv(t, withEmailVerify(false))
+	ctx := context.Background()
+	if err := env.svc.Register(ctx, "alice", "Alice", "alice@example.com", "Correct-Horse-9"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := env.users.GetByEmail(ctx, "alice@example.com")
+	if u.Status != model.UserStatusPending {
+		t.Fatalf("status after register = %d, want pending", u.Status)
+	}
+
+	token := env.mailToken(t, "alice@example.com")
+	if err := env.svc.VerifyEmail(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.users.get(u.ID).Status; got != model.UserStatusNormal {
+		t.Fatalf("status after verify = %d, want normal", got)
+	}
+
+	// 链接单次有效
+	if err := env.svc.VerifyEmail(ctx, token); !errors.Is(err, ErrVerifyLinkInvalid) {
+		t.Fatalf("second verify: got %v, want ErrVerifyLinkInvalid", err)
+	}
+	if err := env.svc.VerifyEmail(ctx, "garbage"); !errors.Is(err, ErrVerifyLinkInvalid) {
+		t.Fatalf("garbage token: got %v", err)
+	}
+}
+
+func TestRegisterWithoutVerification(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	if err := env.svc.Register(ctx, "alice", "Alice", "alice@example.com", "Correct-Horse-9"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := env.users.GetByEmail(ctx, "alice@example.com")
+	if u.Status != model.UserStatusNormal {
+		t.Fatalf("status = %d, want normal", u.Status)
+	}
+	if n := len(env.mail.Messages()); n != 0 {
+		t.Fatalf("sent %d mails with verification disabled", n)
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	env := newTestEnv(t, withEmailVerify(false))
+	ctx := context.Background()
+	if err := env.svc.Register(ctx, "alice", "Alice", "alice@example.com", "Correct-Horse-9"); err != nil {
+		t.Fatal(err)
+	}
+	first := env.mailToken(t, "alice@example.com")
+
+	if err := env.svc.ResendVerificationEmail(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	second := env.mailToken(t, "alice@example.com")
+	if second == first {
+		t.Fatal("resend reused the old token")
+	}
+
+	// 冷却期内再次重发被拒绝
+	if err := env.svc.ResendVerificationEmail(ctx, "alice@example.com"); !errors.Is(err, ErrResendTooFrequent) {
+		t.Fatalf("resend within cooldown: got %v", err)
+	}
+
+	// 重发后旧链接失效
+	if err := env.svc.VerifyEmail(ctx, first); !errors.Is(err, ErrVerifyLinkInvalid) {
+		t.Fatalf("old link: got %v, want ErrVerifyLinkInvalid", err)
+	}
+	if err := env.svc.VerifyEmail(ctx, second); err != nil {
+		t.Fatalf("new link: %v", err)
+	}
+
+	// 已验证或不存在的邮箱：返回成功但不发送
+	sent := len(env.mail.Messages())
+	env.mr.FastForward(2 * time.Minute)
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		if err := env.svc.ResendVerificationEmail(ctx, email); err != nil {
+			t.Fatalf("%s: %v", email, err)
+		}
+	}
+	if got := len(env.mail.Messages()); got != sent {
+		t.Fatalf("sent %d extra mails", got-sent)
+	}
+}
+
+// TestResendVerificationSameForEveryEmail 冷却期内的响应不因邮箱是否注册、是否已验证而不同
+func TestResendVerificationSameForEveryEmail(t *testing.T) {
+	env := newTestEnv(t, withEmailVerify(false))
+	ctx := context.Background()
+	if err := env.svc.Register(ctx, "alice", "Alice", "pending@example.com", "Correct-Horse-9"); err != nil {
+		t.Fatal(err)
+	}
+	env.createUser(t, "verified@example.com", "Correct-Horse-9")
+
+	for _, email := range []string{"pending@example.com", "verified@example.com", "nobody@example.com"} {
+		if err := env.svc.ResendVerificationEmail(ctx, email); err != nil {
+			t.Fatalf("%s: first resend: %v", email, err)
+		}
+		if err := env.svc.ResendVerificationEmail(ctx, email); !errors.Is(err, ErrResendTooFrequent) {
+			t.Fatalf("%s: second resend: got %v, want ErrResendTooFrequent", email, err)
+		}
+	}
+
+	// 冷却不区分大小写
+	if err := env.svc.ResendVerificationEmail(ctx, " Pending@Example.COM"); !errors.Is(err, ErrResendTooFrequent) {
+		t.Fatalf("case variant: got %v, want ErrResendTooFrequent", err)
+	}
+}
+
+func TestBlockLoginUntilVerified(t *testing.T) {
+	env := newTestEnv(t, withEmailVerify(true))
+	ctx := context.Background()
+	if err := env.svc.Register(ctx, "alice", "Alice", "alice@example.com", "Correct-Horse-9"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", ClientInfo{}); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("login before verify: got %v, want ErrEmailNotVerified", err)
+	}
+	if err := env.svc.VerifyEmail(ctx, env.mailToken(t, "alice@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+}
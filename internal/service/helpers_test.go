@@ -0,0 +1,596 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/mailer"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// testEnv 测试用的 UserService：Redis 相关存储使用 miniredis 上的真实实现，MySQL 相关存储使用内存实现
+type testEnv struct {
+	mr      *miniredis.Miniredis
+	rdb     *redis.Client
+	cfg     *config.Config
+	jwt     *utils.JWTManager
+	users   *fakeUserRepo
+	mfa     *fakeMFARepo
+	rbac    *fakeRBACRepo
+	apiKeys *fakeAPIKeyRepo
+	audit   *fakeAuditRepo
+	mail    *mailer.MemorySender
+	svc     *UserService
+}
+
+func newTestEnv(t *testing.T, opts ...func(*config.Config)) *testEnv {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	cfg := &config.Config{
+		JWT: config.JWTConfig{Issuer: "user-service", Audience: "user-service-api", Expire: 1, RefreshExpire: 168},
+		MFA: config.MFAConfig{Issuer: "UserService", SecretKey: "test-mfa-key", PendingTTL: 300},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	keys, err := utils.LoadKeyring(t.TempDir(), utils.AlgEdDSA, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtMgr := utils.NewJWTManager(keys, cfg.JWT.Issuer, cfg.JWT.Audience)
+	// 测试使用最低的哈希参数
+	hasher, err := utils.NewPasswordHasher(utils.HashArgon2id, 0, utils.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := utils.NewPasswordPolicy(8, 128, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &testEnv{
+		mr:      mr,
+		rdb:     rdb,
+		cfg:     cfg,
+		jwt:     jwtMgr,
+		users:   newFakeUserRepo(rdb),
+		mfa:     newFakeMFARepo(),
+		rbac:    newFakeRBACRepo(),
+		apiKeys: newFakeAPIKeyRepo(),
+		audit:   &fakeAuditRepo{},
+		mail:    mailer.NewMemorySender(),
+	}
+	env.svc = NewUserService(Repositories{
+		User:         env.users,
+		Token:        repository.NewTokenRepository(rdb),
+		OneTime:      repository.NewOneTimeTokenRepository(rdb),
+		MFA:          env.mfa,
+		RBAC:         env.rbac,
+		APIKey:       env.apiKeys,
+		Audit:        env.audit,
+		LoginAttempt: repository.NewLoginAttemptRepository(rdb),
+		MagicLink:    repository.NewMagicLinkRepository(rdb),
+		Introspect:   repository.NewIntrospectionRepository(rdb),
+		UserEvent:    repository.NewUserEventRepository(rdb, 1000),
+	}, jwtMgr, hasher, policy, env.mail, cfg)
+	return env
+}
+
+// createUser 直接写入一个正常状态的用户
+func (e *testEnv) createUser(t *testing.T, email, password string) *model.User {
+	t.Helper()
+	hashed, err := e.svc.hasher.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &model.User{Name: strings.Split(email, "@")[0], Email: email, Password: hashed, Status: model.UserStatusNormal}
+	if err := e.users.Create(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// login 密码登录并返回 Token 对
+func (e *testEnv) login(t *testing.T, email, password string) *utils.TokenPair {
+	t.Helper()
+	res, err := e.svc.Login(context.Background(), email, password, ClientInfo{IP: "203.0.113.1", UserAgent: "test"})
+	if err != nil {
+		t.Fatalf("login %s: %v", email, err)
+	}
+	if res.TokenPair == nil {
+		t.Fatalf("login %s: no token pair", email)
+	}
+	return res.TokenPair
+}
+
+// fakeUserRepo 内存中的 users 表；缓存操作沿用真实实现
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	mu      sync.Mutex
+	nextID  int
+	users   map[int]*model.User
+	dbReads int // GetByID / GetByIDs 查询的用户数，用于断言回源次数
+}
+
+func newFakeUserRepo(rdb *redis.Client) *fakeUserRepo {
+	return &fakeUserRepo{
+		UserRepository: repository.NewUserRepository(nil, rdb),
+		users:          make(map[int]*model.User),
+	}
+}
+
+func (r *fakeUserRepo) Create(_ context.Context, u *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	u.ID = r.nextID
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = u.CreatedAt
+	cp := *u
+	r.users[u.ID] = &cp
+	return nil
+}
+
+func (r *fakeUserRepo) GetByEmail(_ context.Context, email string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == email {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) GetByID(_ context.Context, id int) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dbReads++
+	u, ok := r.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *fakeUserRepo) GetByIDs(_ context.Context, ids []int) ([]model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []model.User
+	// 与 IN 查询一样不保证顺序：按 ID 倒序返回
+	for i := len(ids) - 1; i >= 0; i-- {
+		r.dbReads++
+		if u, ok := r.users[ids[i]]; ok {
+			out = append(out, *u)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeUserRepo) UpdateProfile(_ context.Context, id int, nickname string, age int, avatar string) error {
+	return r.update(id, func(u *model.User) { u.Nickname, u.Age, u.Avatar = nickname, age, avatar })
+}
+
+func (r *fakeUserRepo) UpdateStatus(_ context.Context, id int, status int) error {
+	return r.update(id, func(u *model.User) { u.Status = status })
+}
+
+func (r *fakeUserRepo) UpdatePassword(_ context.Context, id int, hashedPassword string) error {
+	return r.update(id, func(u *model.User) { u.Password = hashedPassword })
+}
+
+func (r *fakeUserRepo) update(id int, fn func(*model.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	fn(u)
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// get 读取存储中的用户（测试断言使用）
+func (r *fakeUserRepo) get(id int) model.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.users[id]
+}
+
+// fakeMFARepo 内存中的 user_mfa / user_recovery_codes 表
+type fakeMFARepo struct {
+	mu    sync.Mutex
+	mfa   map[int]*model.UserMFA
+	codes map[int]map[string]bool // user_id -> code_hash -> 已使用
+}
+
+func newFakeMFARepo() *fakeMFARepo {
+	return &fakeMFARepo{mfa: make(map[int]*model.UserMFA), codes: make(map[int]map[string]bool)}
+}
+
+func (r *fakeMFARepo) Get(_ context.Context, userID int) (*model.UserMFA, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.mfa[userID]
+	if !ok {
+		return nil, nil
+	}
+	cp := *m
+	return &cp, nil
+}
+
+func (r *fakeMFARepo) SavePending(_ context.Context, userID int, encryptedSecret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mfa[userID] = &model.UserMFA{UserID: userID, Secret: encryptedSecret}
+	return nil
+}
+
+func (r *fakeMFARepo) Enable(_ context.Context, userID int, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.mfa[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	m.Enabled = true
+	r.codes[userID] = make(map[string]bool, len(codeHashes))
+	for _, h := range codeHashes {
+		r.codes[userID][h] = false
+	}
+	return nil
+}
+
+func (r *fakeMFARepo) Disable(_ context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.mfa, userID)
+	delete(r.codes, userID)
+	return nil
+}
+
+func (r *fakeMFARepo) UseRecoveryCode(_ context.Context, userID int, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used, ok := r.codes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	r.codes[userID][codeHash] = true
+	return true, nil
+}
+
+// fakeOAuthClientRepo 内存中的 oauth_clients 表
+type fakeOAuthClientRepo struct {
+	mu      sync.Mutex
+	clients map[string]model.OAuthClient
+}
+
+func newFakeOAuthClientRepo() *fakeOAuthClientRepo {
+	return &fakeOAuthClientRepo{clients: make(map[string]model.OAuthClient)}
+}
+
+func (r *fakeOAuthClientRepo) Create(_ context.Context, c *model.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[c.ID] = *c
+	return nil
+}
+
+func (r *fakeOAuthClientRepo) Get(_ context.Context, clientID string) (*model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.clients[clientID]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (r *fakeOAuthClientRepo) List(_ context.Context) ([]model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []model.OAuthClient
+	for _, c := range r.clients {
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (r *fakeOAuthClientRepo) Delete(_ context.Context, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[clientID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.clients, clientID)
+	return nil
+}
+
+// fakeIdentityRepo 内存中的 linked_identities 表；state 沿用 Redis 实现
+type fakeIdentityRepo struct {
+	repository.IdentityRepository
+
+	mu     sync.Mutex
+	nextID int
+	list   []model.LinkedIdentity
+}
+
+func newFakeIdentityRepo(rdb *redis.Client) *fakeIdentityRepo {
+	return &fakeIdentityRepo{IdentityRepository: repository.NewIdentityRepository(nil, rdb)}
+}
+
+func (r *fakeIdentityRepo) Find(_ context.Context, provider, subject string) (*model.LinkedIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, li := range r.list {
+		if li.Provider == provider && li.Subject == subject {
+			return &li, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeIdentityRepo) ListByUser(_ context.Context, userID int) ([]model.LinkedIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []model.LinkedIdentity
+	for _, li := range r.list {
+		if li.UserID == userID {
+			out = append(out, li)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeIdentityRepo) Create(_ context.Context, li *model.LinkedIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 与 uk_provider_subject、uk_user_provider 一致
+	for _, x := range r.list {
+		if x.Provider == li.Provider && (x.Subject == li.Subject || x.UserID == li.UserID) {
+			return repository.ErrIdentityExists
+		}
+	}
+	r.nextID++
+	li.ID = r.nextID
+	li.CreatedAt = time.Now()
+	r.list = append(r.list, *li)
+	return nil
+}
+
+func (r *fakeIdentityRepo) Delete(_ context.Context, userID int, provider string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, li := range r.list {
+		if li.UserID == userID && li.Provider == provider {
+			r.list = append(r.list[:i], r.list[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *fakeIdentityRepo) TouchLogin(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i := range r.list {
+		if r.list[i].ID == id {
+			r.list[i].LastLoginAt = &now
+		}
+	}
+	return nil
+}
+
+// fakeRBACRepo 内存中的角色表：admin / support 与迁移脚本中的初始角色一致
+type fakeRBACRepo struct {
+	mu    sync.Mutex
+	roles map[string][]string
+	users map[int][]string // user_id -> 角色名
+}
+
+func newFakeRBACRepo() *fakeRBACRepo {
+	return &fakeRBACRepo{
+		roles: map[string][]string{
+			"admin": {model.PermUserRead, model.PermUserSuspend, model.PermUserDelete, model.PermRoleAssign,
+				model.PermLoginUnlock, model.PermOAuthClient, model.PermImpersonate, model.PermAuditRead},
+			"support": {model.PermUserRead, model.PermLoginUnlock, model.PermImpersonate},
+		},
+		users: make(map[int][]string),
+	}
+}
+
+func (r *fakeRBACRepo) ListRoles(_ context.Context) ([]model.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []model.Role
+	for name, perms := range r.roles {
+		out = append(out, model.Role{Name: name, Permissions: perms})
+	}
+	return out, nil
+}
+
+func (r *fakeRBACRepo) GetUserRoles(_ context.Context, userID int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.users[userID]), nil
+}
+
+func (r *fakeRBACRepo) GetUserPermissions(_ context.Context, userID int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	perms := []string{}
+	for _, role := range r.users[userID] {
+		perms = append(perms, r.roles[role]...)
+	}
+	return perms, nil
+}
+
+func (r *fakeRBACRepo) AssignRole(_ context.Context, userID int, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.roles[role]; !ok {
+		return repository.ErrRoleNotFound
+	}
+	if !slices.Contains(r.users[userID], role) {
+		r.users[userID] = append(r.users[userID], role)
+	}
+	return nil
+}
+
+func (r *fakeRBACRepo) RemoveRole(_ context.Context, userID int, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.roles[role]; !ok {
+		return repository.ErrRoleNotFound
+	}
+	r.users[userID] = slices.DeleteFunc(r.users[userID], func(s string) bool { return s == role })
+	return nil
+}
+
+func (r *fakeRBACRepo) DeleteCache(_ context.Context, _ int) error { return nil }
+
+// fakeAPIKeyRepo 内存中的 api_keys 表
+type fakeAPIKeyRepo struct {
+	mu         sync.Mutex
+	nextID     int
+	keys       map[string]*model.APIKey // prefix -> key
+	collisions int                      // 接下来 Create 按前缀冲突失败的次数
+}
+
+func newFakeAPIKeyRepo() *fakeAPIKeyRepo {
+	return &fakeAPIKeyRepo{keys: make(map[string]*model.APIKey)}
+}
+
+func (r *fakeAPIKeyRepo) Create(_ context.Context, k *model.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[k.Prefix]; ok || r.collisions > 0 {
+		r.collisions--
+		return repository.ErrAPIKeyPrefixExists
+	}
+	r.nextID++
+	k.ID = r.nextID
+	cp := *k
+	r.keys[k.Prefix] = &cp
+	return nil
+}
+
+func (r *fakeAPIKeyRepo) GetByPrefix(_ context.Context, prefix string) (*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[prefix]
+	if !ok {
+		return nil, nil
+	}
+	cp := *k
+	return &cp, nil
+}
+
+func (r *fakeAPIKeyRepo) ListByUser(_ context.Context, userID int) ([]model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []model.APIKey
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			out = append(out, *k)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeAPIKeyRepo) CountByUser(ctx context.Context, userID int) (int, error) {
+	keys, err := r.ListByUser(ctx, userID)
+	return len(keys), err
+}
+
+func (r *fakeAPIKeyRepo) Delete(_ context.Context, userID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for prefix, k := range r.keys {
+		if k.ID == id && k.UserID == userID {
+			delete(r.keys, prefix)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *fakeAPIKeyRepo) TouchLastUsed(_ context.Context, k *model.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.keys[k.Prefix]; ok {
+		now := time.Now()
+		stored.LastUsedAt = &now
+	}
+	return nil
+}
+
+// expire 将 Key 的过期时间改到过去（测试断言使用）
+func (r *fakeAPIKeyRepo) expire(prefix string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	past := time.Now().Add(-time.Minute)
+	r.keys[prefix].ExpiresAt = &past
+}
+
+// fakeAuditRepo 内存中的 audit_logs 表
+type fakeAuditRepo struct {
+	mu   sync.Mutex
+	logs []model.AuditLog
+}
+
+func (r *fakeAuditRepo) Create(ctx context.Context, l *model.AuditLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l.ID = int64(len(r.logs) + 1)
+	r.logs = append(r.logs, *l)
+	return nil
+}
+
+func (r *fakeAuditRepo) List(_ context.Context, f model.AuditFilter) ([]model.AuditLog, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []model.AuditLog
+	for i := len(r.logs) - 1; i >= 0; i-- {
+		l := r.logs[i]
+		if (f.ActorID == 0 || l.ActorID == f.ActorID) && (f.UserID == 0 || l.UserID == f.UserID) {
+			matched = append(matched, l)
+		}
+	}
+	end := min(f.Offset+f.Limit, len(matched))
+	if f.Offset >= end {
+		return nil, len(matched), nil
+	}
+	return matched[f.Offset:end], len(matched), nil
+}
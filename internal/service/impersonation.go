@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// 审计日志字段长度上限，与表结构一致（按字符计）
+const (
+	maxAuditDetailLen = 512
+	maxAuditFieldLen  = 255
+)
+
+var (
+	// ErrImpersonationForbidden 模拟登录期间禁止执行账号安全相关操作
+	ErrImpersonationForbidden = errors.New("模拟登录期间不能执行该操作")
+	ErrImpersonateReason      = errors.New("请填写模拟登录的原因")
+	// ErrImpersonateAdmin 拥有管理权限的用户不能被模拟，防止借此提升权限
+	ErrImpersonateAdmin = errors.New("不能模拟拥有管理权限的用户")
+)
+
+// ImpersonationToken 模拟登录 Token，只有 Access Token，过期后需重新申请
+type ImpersonationToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	UserID      int    `json:"user_id"`
+	ActorID     int    `json:"actor_id"`
+}
+
+func (s *UserService) impersonationTTL() time.Duration {
+	if s.cfg.Impersonation.TokenTTL <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(s.cfg.Impersonation.TokenTTL) * time.Minute
+}
+
+// Impersonate 管理员以目标用户身份签发短期 Access Token，act claim 记录管理员 ID
+// 不创建会话、不可刷新；目标用户"退出所有设备"或修改密码后同样失效
+func (s *UserService) Impersonate(ctx context.Context, operatorID, userID int, reason string, client ClientInfo) (*ImpersonationToken, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrImpersonateReason
+	}
+	if err := refuseImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.checkAdminTarget(ctx, operatorID, userID); err != nil {
+		return nil, err
+	}
+	perms, err := s.rbacRepo.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(perms) > 0 {
+		return nil, ErrImpersonateAdmin
+	}
+
+	gen, err := s.tokenRepo.GetGeneration(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	claims := s.jwt.NewClaims(userID, utils.TokenTypeAccess, s.impersonationTTL())
+	claims.Generation = gen
+	claims.Actor = &utils.Actor{Subject: strconv.Itoa(operatorID)}
+	token, err := s.jwt.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RecordAudit(ctx, &model.AuditLog{
+		ActorID:   operatorID,
+		UserID:    userID,
+		Action:    model.AuditImpersonateStart,
+		Target:    claims.ID,
+		Detail:    reason,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	})
+	return &ImpersonationToken{
+		AccessToken: token,
+		ExpiresIn:   int64(s.impersonationTTL().Seconds()),
+		UserID:      userID,
+		ActorID:     operatorID,
+	}, nil
+}
+
+// RecordAudit 写入审计日志（HTTP 中间件与 gRPC 拦截器同样调用）
+// 写库失败时完整记录到应用日志，不影响请求本身
+func (s *UserService) RecordAudit(ctx context.Context, l *model.AuditLog) {
+	if l.CreatedAt.IsZero() {
+		l.CreatedAt = time.Now()
+	}
+	l.Target = truncateRunes(l.Target, maxAuditFieldLen)
+	l.Detail = truncateRunes(l.Detail, maxAuditDetailLen)
+	l.UserAgent = truncateRunes(l.UserAgent, maxAuditFieldLen)
+
+	fields := []zap.Field{
+		zap.Int("actor_id", l.ActorID),
+		zap.Int("user_id", l.UserID),
+		zap.String("action", l.Action),
+		zap.String("target", l.Target),
+		zap.Int("status", l.Status),
+		zap.String("ip", l.IP),
+	}
+	// 请求已结束或被取消时审计仍需落库
+	if err := s.auditRepo.Create(context.WithoutCancel(ctx), l); err != nil {
+		logger.Log.Error("审计日志写入失败", append(fields, zap.String("detail", l.Detail), zap.Error(err))...)
+		return
+	}
+	logger.Log.Info("审计", fields...)
+}
+
+// ListAuditLogs 管理后台分页查询审计日志，page 从 1 开始
+func (s *UserService) ListAuditLogs(ctx context.Context, actorID, userID, page, size int) ([]model.AuditLog, int, error) {
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	size = min(size, maxPageSize)
+	page = max(page, 1)
+
+	return s.auditRepo.List(ctx, model.AuditFilter{
+		ActorID: actorID,
+		UserID:  userID,
+		Offset:  (page - 1) * size,
+		Limit:   size,
+	})
+}
+
+// refuseImpersonation 账号安全相关操作（修改密码、二次验证、API Key 等）在模拟登录期间一律拒绝
+func refuseImpersonation(ctx context.Context) error {
+	if _, ok := utils.ActorIDFromContext(ctx); ok {
+		return ErrImpersonationForbidden
+	}
+	return nil
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
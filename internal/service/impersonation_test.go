@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+func TestImpersonate(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := env.createUser(t, "admin@example.com", "Correct-Horse-9")
+	alice := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	if err := env.rbac.AssignRole(ctx, admin.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	client := ClientInfo{IP: "203.0.113.1", UserAgent: "Mozilla/5.0"}
+
+	if _, err := env.svc.Impersonate(ctx, admin.ID, alice.ID, "  ", client); !errors.Is(err, ErrImpersonateReason) {
+		t.Fatalf("empty reason: got %v", err)
+	}
+	if _, err := env.svc.Impersonate(ctx, admin.ID, admin.ID, "ticket-42", client); !errors.Is(err, ErrOperateSelf) {
+		t.Fatalf("impersonate self: got %v", err)
+	}
+	if _, err := env.svc.Impersonate(ctx, alice.ID, admin.ID, "ticket-42", client); !errors.Is(err, ErrImpersonateAdmin) {
+		t.Fatalf("impersonate admin: got %v", err)
+	}
+
+	tok, err := env.svc.Impersonate(ctx, admin.ID, alice.ID, "ticket-42", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := env.svc.ValidateToken(ctx, tok.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID() != alice.ID || claims.ActorID() != admin.ID || claims.SessionID != "" {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	// 签发即记录审计
+	logs, total, _ := env.svc.ListAuditLogs(ctx, admin.ID, 0, 1, 10)
+	if total != 1 || logs[0].Action != model.AuditImpersonateStart || logs[0].UserID != alice.ID ||
+		logs[0].Target != claims.ID || logs[0].Detail != "ticket-42" || logs[0].IP != client.IP {
+		t.Fatalf("audit = %+v", logs)
+	}
+
+	// 模拟身份不能执行账号安全操作，也不能再次模拟
+	impCtx := utils.ContextWithClaims(ctx, claims)
+	if _, err := env.svc.ChangePassword(impCtx, alice.ID, "Correct-Horse-9", "Another-Horse-9", client); !errors.Is(err, ErrImpersonationForbidden) {
+		t.Fatalf("change password while impersonating: got %v", err)
+	}
+	if _, err := env.svc.Impersonate(impCtx, admin.ID, alice.ID, "again", client); !errors.Is(err, ErrImpersonationForbidden) {
+		t.Fatalf("nested impersonation: got %v", err)
+	}
+
+	// 目标用户退出所有设备后模拟 Token 失效
+	if err := env.svc.LogoutAll(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, tok.AccessToken); err == nil {
+		t.Fatal("impersonation token valid after logout-all")
+	}
+}
+
+func TestRecordAudit(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 请求已取消时仍然落库；超长字段按字符截断
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	env.svc.RecordAudit(ctx, &model.AuditLog{
+		ActorID: 1, UserID: 2, Action: model.AuditHTTPRequest,
+		Target: "GET /" + strings.Repeat("路", 300), Detail: strings.Repeat("x", 600),
+	})
+	logs, total, err := env.svc.ListAuditLogs(context.Background(), 0, 2, 1, 10)
+	if err != nil || total != 1 {
+		t.Fatalf("ListAuditLogs = %d, %v", total, err)
+	}
+	l := logs[0]
+	if n := len([]rune(l.Target)); n != maxAuditFieldLen {
+		t.Errorf("target length %d", n)
+	}
+	if len(l.Detail) != maxAuditDetailLen || l.CreatedAt.IsZero() {
+		t.Errorf("detail length %d, created_at %v", len(l.Detail), l.CreatedAt)
+	}
+
+	for i := 0; i < 5; i++ {
+		env.svc.RecordAudit(context.Background(), &model.AuditLog{ActorID: 1, UserID: 3, Action: model.AuditGRPCCall})
+	}
+	logs, total, _ = env.svc.ListAuditLogs(context.Background(), 1, 0, 2, 2)
+	if total != 6 || len(logs) != 2 || logs[0].ID != 4 {
+		t.Fatalf("page 2 = %+v (total %d)", logs, total)
+	}
+}
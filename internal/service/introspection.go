@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+)
+
+func (s *UserService) introspectionCacheTTL() time.Duration {
+	if s.cfg.Introspection.CacheTTL <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(s.cfg.Introspection.CacheTTL) * time.Second
+}
+
+// IntrospectToken 供其他服务校验终端用户的 Access Token 或 API Key
+// 与 ValidateToken 相同：校验签名、有效期、吊销状态与所属会话；结果按 Token 哈希短期缓存
+// Token 无效时返回 Active=false；只有存储层故障才返回 error
+func (s *UserService) IntrospectToken(ctx context.Context, token string) (*model.TokenIntrospection, error) {
+	if token == "" {
+		return &model.TokenIntrospection{}, nil
+	}
+	key := utils.SHA256Hex(token)
+	if cached, err := s.introRepo.Get(ctx, key); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	claims, err := s.ValidateToken(ctx, token)
+	if err != nil && !errors.Is(err, utils.ErrInvalidToken) && !errors.Is(err, ErrTokenRevoked) {
+		return nil, err
+	}
+
+	result := &model.TokenIntrospection{}
+	ttl := s.introspectionCacheTTL()
+	if err == nil {
+		result = &model.TokenIntrospection{
+			Active:    true,
+			UserID:    claims.UserID(),
+			TokenType: claims.Type,
+			Scopes:    strings.Fields(claims.Scope),
+			SessionID: claims.SessionID,
+			ActorID:   claims.ActorID(),
+		}
+		if claims.ExpiresAt != nil {
+			exp := claims.ExpiresAt.Time
+			result.ExpiresAt = &exp
+			// 缓存不能比 Token 本身活得更久
+			ttl = min(ttl, time.Until(exp))
+		}
+	}
+
+	if ttl > 0 {
+		if err := s.introRepo.Set(ctx, key, result, ttl); err != nil {
+			logger.Log.Warn("内省结果缓存失败", zap.Error(err))
+		}
+	}
+	return result, nil
+}
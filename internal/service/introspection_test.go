@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+func TestIntrospectToken(t *testing.T) {
+	env := newTestEnv(t, func(c *config.Config) { c.Introspection.CacheTTL = 10 })
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	pair := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	res, err := env.svc.IntrospectToken(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, _ := env.svc.ValidateToken(ctx, pair.AccessToken)
+	if !res.Active || res.UserID != u.ID || res.TokenType != utils.TokenTypeAccess || res.SessionID != claims.SessionID || res.ExpiresAt == nil {
+		t.Fatalf("introspection = %+v", res)
+	}
+
+	// 结果按 Token 哈希缓存，不保存明文
+	key := "introspect:" + utils.SHA256Hex(pair.AccessToken)
+	if ttl := env.mr.TTL(key); ttl <= 0 || ttl > 10*time.Second {
+		t.Fatalf("cache ttl = %v", ttl)
+	}
+
+	// 吊销后在缓存有效期内仍返回 active，过期后反映吊销状态
+	if err := env.svc.Logout(ctx, claims); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := env.svc.IntrospectToken(ctx, pair.AccessToken); !res.Active {
+		t.Fatal("cached result not used")
+	}
+	env.mr.FastForward(11 * time.Second)
+	if res, _ := env.svc.IntrospectToken(ctx, pair.AccessToken); res.Active {
+		t.Fatalf("revoked token active after cache expiry: %+v", res)
+	}
+}
+
+func TestIntrospectInvalidToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	for _, token := range []string{"", "not-a-jwt", "uk_missing_secret"} {
+		res, err := env.svc.IntrospectToken(ctx, token)
+		if err != nil {
+			t.Fatalf("IntrospectToken(%q): %v", token, err)
+		}
+		if res.Active || res.UserID != 0 {
+			t.Fatalf("IntrospectToken(%q) = %+v", token, res)
+		}
+	}
+	// 空 Token 不写缓存
+	if env.mr.Exists("introspect:" + utils.SHA256Hex("")) {
+		t.Fatal("empty token cached")
+	}
+}
+
+func TestIntrospectCacheBoundedByExpiry(t *testing.T) {
+	env := newTestEnv(t, func(c *config.Config) { c.Introspection.CacheTTL = 3600 * 24 })
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	pair := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	if _, err := env.svc.IntrospectToken(ctx, pair.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	// 缓存不超过 Access Token 剩余有效期 (1 小时)
+	if ttl := env.mr.TTL("introspect:" + utils.SHA256Hex(pair.AccessToken)); ttl > time.Hour {
+		t.Fatalf("cache ttl %v outlives token", ttl)
+	}
+}
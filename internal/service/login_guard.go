@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ErrLoginThrottled 登录失败次数过多，暂时禁止登录
+// 对存在与不存在的账号返回完全相同的结果，不泄露账号是否存在
+var ErrLoginThrottled = errors.New("登录尝试过于频繁，请稍后再试")
+
+// LoginThrottledError 携带剩余等待时间，Handler 据此设置 Retry-After
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string { return ErrLoginThrottled.Error() }
+func (e *LoginThrottledError) Unwrap() error { return ErrLoginThrottled }
+
+// ClientInfo 发起请求的客户端信息
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	Device    string // 客户端自报的设备名，可为空
+
+	// 通过 OIDC 授权码登录第三方应用时记录到会话中
+	OAuthClientID string
+	OAuthScope    string
+}
+
+// checkLoginAllowed 登录前检查邮箱 / IP 是否处于封禁（退避或锁定）中
+func (s *UserService) checkLoginAllowed(ctx context.Context, email, ip string) error {
+	if !s.cfg.LoginGuard.Enable {
+		return nil
+	}
+	d, err := s.attemptRepo.BlockedFor(ctx, normalizeEmail(email), ip)
+	if err != nil {
+		return err
+	}
+	if d > 0 {
+		return &LoginThrottledError{RetryAfter: d}
+	}
+	return nil
+}
+
+// recordLoginFailure 记录失败并按次数施加指数退避或临时锁定
+func (s *UserService) recordLoginFailure(ctx context.Context, email, ip string) {
+	g := s.cfg.LoginGuard
+	if !g.Enable {
+		return
+	}
+	email = normalizeEmail(email)
+
+	emailFails, ipFails, err := s.attemptRepo.RecordFailure(ctx, email, ip, time.Duration(g.Window)*time.Second)
+	if err != nil {
+		logger.Log.Error("记录登录失败次数出错", zap.Error(err))
+		return
+	}
+
+	switch {
+	case g.LockAfter > 0 && emailFails >= int64(g.LockAfter):
+		// 达到上限：锁定账号，需等待锁定期结束或由运维解锁
+		_ = s.attemptRepo.BlockEmail(ctx, email, time.Duration(g.LockDuration)*time.Second)
+		logger.Log.Warn("账号因多次登录失败被临时锁定", zap.String("email", email), zap.String("ip", ip), zap.Int64("failures", emailFails))
+	case g.BackoffAfter > 0 && emailFails >= int64(g.BackoffAfter):
+		_ = s.attemptRepo.BlockEmail(ctx, email, backoffDelay(emailFails-int64(g.BackoffAfter), g.BackoffBase, g.BackoffMax))
+	}
+
+	if g.IPMaxFailures > 0 && ipFails >= int64(g.IPMaxFailures) {
+		_ = s.attemptRepo.BlockIP(ctx, ip, time.Duration(g.Window)*time.Second)
+		logger.Log.Warn("IP 因多次登录失败被临时封禁", zap.String("ip", ip), zap.Int64("failures", ipFails))
+	}
+}
+
+// recordLoginSuccess 登录成功后清零邮箱维度的失败计数（IP 维度保留，防止撞库时穿插成功登录重置计数）
+func (s *UserService) recordLoginSuccess(ctx context.Context, email string) {
+	if !s.cfg.LoginGuard.Enable {
+		return
+	}
+	_ = s.attemptRepo.ResetEmail(ctx, normalizeEmail(email))
+}
+
+// UnlockLogin 运维解锁账号或 IP
+func (s *UserService) UnlockLogin(ctx context.Context, email, ip string) error {
+	if email == "" && ip == "" {
+		return fmt.Errorf("email 与 ip 至少指定一个")
+	}
+	return s.attemptRepo.Unlock(ctx, normalizeEmail(email), ip)
+}
+
+// backoffDelay 第 n 次（从 0 开始）退避的等待时间：base * 2^n，不超过 max
+func backoffDelay(n int64, baseSec, maxSec int) time.Duration {
+	if baseSec <= 0 {
+		baseSec = 1
+	}
+	max := time.Duration(maxSec) * time.Second
+	d := time.Duration(baseSec) * time.Second
+	for i := int64(0); i < n; i++ {
+		d *= 2
+		if max > 0 && d >= max {
+			return max
+		}
+	}
+	return d
+}
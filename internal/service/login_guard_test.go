@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+)
+
+func withLoginGuard(c *config.Config) {
+	c.LoginGuard = config.LoginGuardConfig{
+		Enable:        true,
+		Window:        900,
+		BackoffAfter:  3,
+		BackoffBase:   1,
+		BackoffMax:    300,
+		LockAfter:     6,
+		LockDuration:  1800,
+		IPMaxFailures: 10,
+	}
+}
+
+// failLogin 以错误密码登录，返回错误
+func (e *testEnv) failLogin(email, ip string) error {
+	_, err := e.svc.Login(context.Background(), email, "wrong-password", ClientInfo{IP: ip})
+	return err
+}
+
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("got %v, want LoginThrottledError", err)
+	}
+	return throttled.RetryAfter
+}
+
+func TestLoginBackoff(t *testing.T) {
+	env := newTestEnv(t, withLoginGuard)
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	for i := 0; i < 2; i++ {
+		if err := env.failLogin("alice@example.com", "203.0.113.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: got %v", i+1, err)
+		}
+	}
+	// 第 3 次失败后开始退避，正确密码在退避期内同样被拒绝
+	env.failLogin("alice@example.com", "203.0.113.1")
+	_, err := env.svc.Login(context.Background(), "alice@example.com", "Correct-Horse-9", ClientInfo{IP: "203.0.113.1"})
+	if d := retryAfter(t, err); d <= 0 || d > time.Second {
+		t.Fatalf("first backoff = %v, want (0, 1s]", d)
+	}
+
+	// 退避时长翻倍；大小写不同的邮箱计入同一账号
+	env.mr.FastForward(time.Second)
+	env.failLogin("ALICE@example.com", "203.0.113.1")
+	if d := retryAfter(t, env.failLogin("alice@example.com", "203.0.113.1")); d <= time.Second || d > 2*time.Second {
+		t.Fatalf("second backoff = %v, want (1s, 2s]", d)
+	}
+
+	// 退避结束后登录成功并清零计数
+	env.mr.FastForward(2 * time.Second)
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+	if err := env.failLogin("alice@example.com", "203.0.113.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("after success: got %v", err)
+	}
+}
+
+func TestLoginLockAndUnlock(t *testing.T) {
+	env := newTestEnv(t, withLoginGuard)
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	for i := 0; i < env.cfg.LoginGuard.LockAfter; i++ {
+		env.mr.FastForward(time.Duration(env.cfg.LoginGuard.BackoffMax) * time.Second) // 跳过退避
+		env.failLogin("alice@example.com", "203.0.113.1")
+	}
+	d := retryAfter(t, env.failLogin("alice@example.com", "203.0.113.1"))
+	if lock := time.Duration(env.cfg.LoginGuard.LockDuration) * time.Second; d <= lock-time.Second || d > lock {
+		t.Fatalf("lock = %v, want about %v", d, lock)
+	}
+
+	// 不存在的账号与已锁定账号返回相同结果
+	for i := 0; i < env.cfg.LoginGuard.LockAfter; i++ {
+		env.mr.FastForward(time.Duration(env.cfg.LoginGuard.BackoffMax) * time.Second)
+		env.failLogin("ghost@example.com", "203.0.113.2")
+	}
+	retryAfter(t, env.failLogin("ghost@example.com", "203.0.113.2"))
+
+	if err := env.svc.UnlockLogin(ctx, "", ""); err == nil {
+		t.Fatal("unlock without target accepted")
+	}
+	if err := env.svc.UnlockLogin(ctx, "Alice@Example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+}
+
+func TestLoginLockOnBadSecondFactor(t *testing.T) {
+	env := newTestEnv(t, withLoginGuard)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	env.enableMFA(t, u.ID)
+	client := ClientInfo{IP: "203.0.113.1"}
+
+	// 密码正确但验证码错误：每轮都计入失败，不会因密码通过而清零
+	for i := 0; i < env.cfg.LoginGuard.LockAfter; i++ {
+		env.mr.FastForward(time.Duration(env.cfg.LoginGuard.BackoffMax) * time.Second) // 跳过退避
+		res, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", client)
+		if err != nil {
+			t.Fatalf("round %d: login: %v", i+1, err)
+		}
+		if _, err := env.svc.CompleteMFALogin(ctx, res.MFAToken, "000000", client); !errors.Is(err, ErrMFACodeInvalid) {
+			t.Fatalf("round %d: got %v", i+1, err)
+		}
+	}
+
+	_, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", client)
+	if d := retryAfter(t, err); d <= time.Duration(env.cfg.LoginGuard.BackoffMax)*time.Second {
+		t.Fatalf("lock = %v, want account lock", d)
+	}
+}
+
+func TestLoginIPBlock(t *testing.T) {
+	env := newTestEnv(t, withLoginGuard)
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	// 同一 IP 对不同账号撞库，每个账号都未达到退避次数
+	for i := 0; i < env.cfg.LoginGuard.IPMaxFailures; i++ {
+		env.failLogin(string(rune('a'+i))+"@example.com", "198.51.100.7")
+	}
+	_, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", ClientInfo{IP: "198.51.100.7"})
+	if d := retryAfter(t, err); d <= 0 {
+		t.Fatalf("ip block = %v", d)
+	}
+
+	// 其他 IP 不受影响
+	if _, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", ClientInfo{IP: "203.0.113.1"}); err != nil {
+		t.Fatalf("other ip: %v", err)
+	}
+
+	if err := env.svc.UnlockLogin(ctx, "", "198.51.100.7"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", ClientInfo{IP: "198.51.100.7"}); err != nil {
+		t.Fatalf("after unlock: %v", err)
+	}
+}
+
+func TestLoginGuardDisabled(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	for i := 0; i < 20; i++ {
+		if err := env.failLogin("alice@example.com", "203.0.113.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: got %v", i+1, err)
+		}
+	}
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+}
+
+func TestBackoffDelay(t *testing.T) {
+	cases := []struct {
+		n    int64
+		want time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{4, 16 * time.Second},
+		{10, 60 * time.Second}, // 上限
+	}
+	for _, tc := range cases {
+		if got := backoffDelay(tc.n, 1, 60); got != tc.want {
+			t.Errorf("backoffDelay(%d) = %v, want %v", tc.n, got, tc.want)
+		}
+	}
+}
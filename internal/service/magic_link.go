@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/mailer"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrMagicLinkDisabled = errors.New("未开启邮件链接登录")
+	// ErrMagicLinkInvalid 登录链接无效、已使用、已过期或不是在申请链接的设备上打开
+	ErrMagicLinkInvalid = errors.New("登录链接无效或已过期，请重新获取")
+)
+
+func (s *UserService) magicLinkTTL() time.Duration {
+	if s.cfg.MagicLink.TokenTTL <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(s.cfg.MagicLink.TokenTTL) * time.Minute
+}
+
+// RequestMagicLink 向邮箱发送单次有效的登录链接
+// 按邮箱与 IP 双维度限制发送频率；邮箱不存在时同样返回成功，避免被用于探测注册邮箱
+func (s *UserService) RequestMagicLink(ctx context.Context, email string, client ClientInfo) error {
+	cfg := s.cfg.MagicLink
+	if !cfg.Enable {
+		return ErrMagicLinkDisabled
+	}
+	email = strings.TrimSpace(email)
+
+	if err := s.throttleMagicLink(ctx, "ip:"+client.IP, cfg.IPLimit, cfg.IPWindow); err != nil {
+		return err
+	}
+	if err := s.throttleMagicLink(ctx, "email:"+normalizeEmail(email), cfg.EmailLimit, cfg.EmailWindow); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	link := &model.MagicLink{Email: email, IP: client.IP, UserAgent: client.UserAgent}
+	nickname := email
+	switch {
+	case user != nil && user.Status == model.UserStatusSuspended:
+		return nil
+	case user != nil:
+		link.UserID, link.Email, nickname = user.ID, user.Email, user.Nickname
+	case !cfg.AutoRegister:
+		return nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if err := s.magicRepo.Save(ctx, utils.SHA256Hex(token), link, s.magicLinkTTL()); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      link.Email,
+		Subject: "登录链接",
+		Body: fmt.Sprintf("%s 您好：\n\n请在 %d 分钟内使用申请时的设备与浏览器打开以下链接完成登录，链接仅可使用一次：\n%s\n\n如非本人操作，请忽略此邮件。\n",
+			nickname, int(s.magicLinkTTL().Minutes()), cfg.LinkURL+"?token="+url.QueryEscape(token)),
+	})
+}
+
+// throttleMagicLink 固定窗口限频，limit <= 0 表示不限制
+func (s *UserService) throttleMagicLink(ctx context.Context, subject string, limit, windowSec int) error {
+	if limit <= 0 || windowSec <= 0 {
+		return nil
+	}
+	ok, retryAfter, err := s.magicRepo.Allow(ctx, subject, limit, time.Duration(windowSec)*time.Second)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// LoginWithMagicLink 使用登录链接中的 Token 登录，结果与密码登录相同（开启二次验证时仍需验证）
+// 链接能被打开即证明邮箱归属：待验证的账号同时完成邮箱验证，未注册的邮箱按配置自动注册
+func (s *UserService) LoginWithMagicLink(ctx context.Context, token string, client ClientInfo) (*LoginResult, error) {
+	cfg := s.cfg.MagicLink
+	if !cfg.Enable {
+		return nil, ErrMagicLinkDisabled
+	}
+
+	// 先核销再比对设备：链接一旦在其他设备上被打开即作废
+	link, err := s.magicRepo.Consume(ctx, utils.SHA256Hex(token))
+	if errors.Is(err, repository.ErrMagicLinkNotFound) {
+		return nil, ErrMagicLinkInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (cfg.BindIP && link.IP != client.IP) || (cfg.BindUserAgent && link.UserAgent != client.UserAgent) {
+		logger.Log.Warn("登录链接在其他设备上使用", zap.String("email", link.Email), zap.String("ip", client.IP))
+		return nil, ErrMagicLinkInvalid
+	}
+
+	user, err := s.magicLinkUser(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	return s.completeLogin(ctx, user, client)
+}
+
+// magicLinkUser 取出链接对应的用户，必要时完成邮箱验证或自动注册
+func (s *UserService) magicLinkUser(ctx context.Context, link *model.MagicLink) (*model.User, error) {
+	if link.UserID == 0 {
+		// 申请后、点击前可能已通过其他方式注册
+		user, err := s.repo.GetByEmail(ctx, link.Email)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			if !s.cfg.MagicLink.AutoRegister {
+				return nil, ErrMagicLinkInvalid
+			}
+			user, err = s.createPasswordlessUser(ctx, link.Email, "", "", model.UserStatusNormal)
+			if err != nil {
+				return nil, err
+			}
+			logger.Log.Info("通过登录链接自动注册", zap.Int("user_id", user.ID))
+			return user, nil
+		}
+		link.UserID = user.ID
+	}
+
+	user, err := s.repo.GetByID(ctx, link.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMagicLinkInvalid // 点击前账号已被删除
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == model.UserStatusPending {
+		if err := s.repo.UpdateStatus(ctx, user.ID, model.UserStatusNormal); err != nil {
+			return nil, err
+		}
+		_ = s.repo.DeleteCache(ctx, user.ID)
+		s.publishUserEvent(ctx, user.ID, model.UserEventStatusChanged)
+		user.Status = model.UserStatusNormal
+	}
+	return user, nil
+}
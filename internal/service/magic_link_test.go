@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+)
+
+func withMagicLink(autoRegister bool) func(*config.Config) {
+	return func(c *config.Config) {
+		c.MagicLink = config.MagicLinkConfig{
+			Enable:        true,
+			TokenTTL:      15,
+			LinkURL:       "http://localhost/magic",
+			BindIP:        true,
+			BindUserAgent: true,
+			AutoRegister:  autoRegister,
+			EmailLimit:    3,
+			EmailWindow:   600,
+		}
+	}
+}
+
+var magicClient = ClientInfo{IP: "203.0.113.1", UserAgent: "Mozilla/5.0"}
+
+func TestMagicLinkLoginOnce(t *testing.T) {
+	env := newTestEnv(t, withMagicLink(false))
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	if err := env.svc.RequestMagicLink(ctx, "alice@example.com", magicClient); err != nil {
+		t.Fatal(err)
+	}
+	token := env.mailToken(t, "alice@example.com")
+
+	res, err := env.svc.LoginWithMagicLink(ctx, token, magicClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := env.svc.ValidateToken(ctx, res.TokenPair.AccessToken)
+	if err != nil || claims.UserID() != u.ID {
+		t.Fatalf("claims = %+v, %v", claims, err)
+	}
+	if _, err := env.svc.LoginWithMagicLink(ctx, token, magicClient); !errors.Is(err, ErrMagicLinkInvalid) {
+		t.Fatalf("reused link: got %v", err)
+	}
+
+	// 重新申请后旧链接作废
+	_ = env.svc.RequestMagicLink(ctx, "alice@example.com", magicClient)
+	old := env.mailToken(t, "alice@example.com")
+	_ = env.svc.RequestMagicLink(ctx, "alice@example.com", magicClient)
+	if _, err := env.svc.LoginWithMagicLink(ctx, old, magicClient); !errors.Is(err, ErrMagicLinkInvalid) {
+		t.Fatalf("superseded link: got %v", err)
+	}
+
+	// 超过邮箱发送次数
+	if err := env.svc.RequestMagicLink(ctx, "alice@example.com", magicClient); !errors.Is(err, ErrLoginThrottled) {
+		t.Fatalf("over email limit: got %v", err)
+	}
+}
+
+func TestMagicLinkDeviceMismatch(t *testing.T) {
+	env := newTestEnv(t, withMagicLink(false))
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	others := map[string]ClientInfo{
+		"other ip":      {IP: "198.51.100.7", UserAgent: magicClient.UserAgent},
+		"other browser": {IP: magicClient.IP, UserAgent: "curl/8.0"},
+	}
+	for name, client := range others {
+		if err := env.svc.RequestMagicLink(ctx, "alice@example.com", magicClient); err != nil {
+			t.Fatal(err)
+		}
+		token := env.mailToken(t, "alice@example.com")
+		if _, err := env.svc.LoginWithMagicLink(ctx, token, client); !errors.Is(err, ErrMagicLinkInvalid) {
+			t.Fatalf("%s: got %v", name, err)
+		}
+		// 在其他设备上打开后链接即作废，原设备也不能再用
+		if _, err := env.svc.LoginWithMagicLink(ctx, token, magicClient); !errors.Is(err, ErrMagicLinkInvalid) {
+			t.Fatalf("%s: link still usable after mismatch: %v", name, err)
+		}
+	}
+}
+
+func TestMagicLinkUnknownEmail(t *testing.T) {
+	ctx := context.Background()
+
+	// 未开启自动注册：不发送邮件，也不暴露邮箱未注册
+	env := newTestEnv(t, withMagicLink(false))
+	if err := env.svc.RequestMagicLink(ctx, "new@example.com", magicClient); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(env.mail.Messages()); n != 0 {
+		t.Fatalf("sent %d mails to unknown email", n)
+	}
+
+	env = newTestEnv(t, withMagicLink(true))
+	if err := env.svc.RequestMagicLink(ctx, "new@example.com", magicClient); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.users.users) != 0 {
+		t.Fatal("user created before link was opened")
+	}
+	res, err := env.svc.LoginWithMagicLink(ctx, env.mailToken(t, "new@example.com"), magicClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, _ := env.svc.ValidateToken(ctx, res.TokenPair.AccessToken)
+	u := env.users.get(claims.UserID())
+	if u.Email != "new@example.com" || u.Status != model.UserStatusNormal || u.Password != unusablePassword {
+		t.Fatalf("auto registered user = %+v", u)
+	}
+}
+
+func TestMagicLinkVerifiesPendingEmail(t *testing.T) {
+	env := newTestEnv(t, withMagicLink(false))
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	if err := env.users.UpdateStatus(ctx, u.ID, model.UserStatusPending); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.svc.RequestMagicLink(ctx, "alice@example.com", magicClient); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.LoginWithMagicLink(ctx, env.mailToken(t, "alice@example.com"), magicClient); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.users.get(u.ID).Status; got != model.UserStatusNormal {
+		t.Fatalf("status = %d, want normal", got)
+	}
+
+	// 停用账号不发送链接
+	_ = env.users.UpdateStatus(ctx, u.ID, model.UserStatusSuspended)
+	_ = env.users.DeleteCache(ctx, u.ID)
+	sent := len(env.mail.Messages())
+	if err := env.svc.RequestMagicLink(ctx, "alice@example.com", magicClient); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.mail.Messages()) != sent {
+		t.Fatal("sent mail to suspended user")
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+const (
+	purposeMFALogin = "mfa_login"
+	purposeTOTPStep = "totp_step"
+
+	recoveryCodeCount  = 10
+	maxMFALoginAttempt = 5
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("已开启二次验证")
+	ErrMFANotEnabled     = errors.New("未开启二次验证")
+	ErrMFACodeInvalid    = errors.New("验证码错误")
+	// ErrMFATokenInvalid 二次验证凭证无效、已使用、已过期或输错次数过多
+	ErrMFATokenInvalid = errors.New("二次验证已失效，请重新登录")
+)
+
+// LoginResult 登录结果
+// 开启二次验证时只返回 MFAToken，需调用 CompleteMFALogin 换取 Token 对
+type LoginResult struct {
+	*utils.TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// MFAEnrollment 绑定信息，前端将 URI 渲染为二维码供验证器 App 扫描
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+func (s *UserService) mfaPendingTTL() time.Duration {
+	if s.cfg.MFA.PendingTTL <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(s.cfg.MFA.PendingTTL) * time.Second
+}
+
+// beginMFALogin 密码校验通过后签发短期 mfa_pending Token，该 Token 只能用于换取正式 Token 对
+func (s *UserService) beginMFALogin(ctx context.Context, userID int) (*LoginResult, error) {
+	claims := s.jwt.NewClaims(userID, utils.TokenTypeMFAPending, s.mfaPendingTTL())
+	token, err := s.jwt.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.otRepo.Save(ctx, purposeMFALogin, claims.ID, userID, s.mfaPendingTTL()); err != nil {
+		return nil, err
+	}
+	return &LoginResult{MFARequired: true, MFAToken: token}, nil
+}
+
+// CompleteMFALogin 校验 TOTP 验证码或恢复码，成功后签发正式 Token 对
+// 验证码错误与密码错误一样计入登录失败次数，防止以已泄露的密码反复穷举验证码
+func (s *UserService) CompleteMFALogin(ctx context.Context, mfaToken, code string, client ClientInfo) (*utils.TokenPair, error) {
+	claims, err := s.jwt.Parse(mfaToken, utils.TokenTypeMFAPending)
+	if err != nil {
+		return nil, ErrMFATokenInvalid
+	}
+	userID := claims.UserID()
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrMFATokenInvalid
+	}
+	if err := s.checkLoginAllowed(ctx, user.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	m, err := s.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || !m.Enabled {
+		return nil, ErrMFATokenInvalid
+	}
+
+	if err := s.verifySecondFactor(ctx, m, code, true); err != nil {
+		if !errors.Is(err, ErrMFACodeInvalid) {
+			return nil, err
+		}
+		s.recordLoginFailure(ctx, user.Email, client.IP)
+		// 输错次数过多则作废本次登录，防止在有效期内穷举验证码
+		n, aerr := s.otRepo.IncrAttempts(ctx, purposeMFALogin, claims.ID, s.mfaPendingTTL())
+		if aerr == nil && n >= maxMFALoginAttempt {
+			_, _ = s.otRepo.Consume(ctx, purposeMFALogin, claims.ID)
+			return nil, ErrMFATokenInvalid
+		}
+		return nil, err
+	}
+
+	// mfa_pending Token 单次有效
+	if _, err := s.otRepo.Consume(ctx, purposeMFALogin, claims.ID); err != nil {
+		if errors.Is(err, repository.ErrOneTimeTokenNotFound) {
+			return nil, ErrMFATokenInvalid
+		}
+		return nil, err
+	}
+	pair, err := s.issueTokenPair(ctx, userID, "", client)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLoginSuccess(ctx, user.Email)
+	return pair, nil
+}
+
+// EnrollMFA 发起绑定：生成新密钥（未确认前不生效），重复调用会覆盖未确认的密钥
+func (s *UserService) EnrollMFA(ctx context.Context, userID int) (*MFAEnrollment, error) {
+	if err := refuseImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	m, err := s.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil && m.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptString(s.cfg.MFA.SecretKey, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SavePending(ctx, userID, encrypted); err != nil {
+		return nil, err
+	}
+
+	return &MFAEnrollment{
+		Secret: secret,
+		URI:    utils.TOTPURI(s.cfg.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA 使用验证器 App 生成的验证码确认绑定，返回一次性恢复码（仅此一次明文返回）
+func (s *UserService) ConfirmMFA(ctx context.Context, userID int, code string) ([]string, error) {
+	if err := refuseImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	m, err := s.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if m.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.verifySecondFactor(ctx, m, code, false); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.Enable(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA 关闭二次验证，需提供当前验证码或恢复码
+func (s *UserService) DisableMFA(ctx context.Context, userID int, code string) error {
+	if err := refuseImpersonation(ctx); err != nil {
+		return err
+	}
+	m, err := s.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if m == nil || !m.Enabled {
+		return ErrMFANotEnabled
+	}
+	if err := s.verifySecondFactor(ctx, m, code, true); err != nil {
+		return err
+	}
+	return s.mfaRepo.Disable(ctx, userID)
+}
+
+// verifySecondFactor 校验 TOTP 验证码；allowRecovery 时也接受恢复码（核销后失效）
+func (s *UserService) verifySecondFactor(ctx context.Context, m *model.UserMFA, code string, allowRecovery bool) error {
+	code = strings.TrimSpace(code)
+
+	secret, err := utils.DecryptString(s.cfg.MFA.SecretKey, m.Secret)
+	if err != nil {
+		return err
+	}
+	if step, ok := utils.ValidateTOTP(secret, code, time.Now(), 1); ok {
+		// 同一时间步的验证码只能使用一次，防止被截获后重放
+		fresh, err := s.otRepo.AcquireCooldown(ctx, purposeTOTPStep, fmt.Sprintf("%d:%d", m.UserID, step), 2*time.Minute)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrMFACodeInvalid
+		}
+		return nil
+	}
+
+	if allowRecovery {
+		used, err := s.mfaRepo.UseRecoveryCode(ctx, m.UserID, utils.SHA256Hex(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+	return ErrMFACodeInvalid
+}
+
+// generateRecoveryCodes 生成恢复码，返回明文（展示给用户）与哈希（落库）
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := hex.EncodeToString(b)
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, utils.SHA256Hex(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格与分隔符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// totpCode 按 RFC 6238 计算 t 时刻的 6 位验证码（模拟验证器 App）
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+// enableMFA 完成绑定，返回密钥与恢复码；绑定使用了当前时间步的验证码
+func (e *testEnv) enableMFA(t *testing.T, userID int) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enroll, err := e.svc.EnrollMFA(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.svc.ConfirmMFA(ctx, userID, "000000"); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("confirm with wrong code: got %v", err)
+	}
+	codes, err := e.svc.ConfirmMFA(ctx, userID, totpCode(t, enroll.Secret, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enroll.Secret, codes
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	enroll, err := env.svc.EnrollMFA(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未确认前不影响登录，密钥加密落库
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+	if m, _ := env.mfa.Get(ctx, u.ID); m.Enabled || m.Secret == enroll.Secret {
+		t.Fatalf("pending enrollment stored as %+v", m)
+	}
+
+	codes, err := env.svc.ConfirmMFA(ctx, u.ID, totpCode(t, enroll.Secret, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes", len(codes))
+	}
+	// 恢复码只保存哈希
+	for _, c := range codes {
+		if _, ok := env.mfa.codes[u.ID][c]; ok {
+			t.Fatal("recovery code stored in plaintext")
+		}
+	}
+	if _, err := env.svc.EnrollMFA(ctx, u.ID); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Fatalf("enroll twice: got %v", err)
+	}
+}
+
+func TestMFALogin(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	secret, _ := env.enableMFA(t, u.ID)
+
+	res, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.MFARequired || res.MFAToken == "" || res.TokenPair != nil {
+		t.Fatalf("login with MFA returned %+v", res)
+	}
+	// mfa_pending Token 不能当作 Access Token 使用
+	if _, err := env.svc.ValidateToken(ctx, res.MFAToken); err == nil {
+		t.Fatal("mfa token accepted as access token")
+	}
+
+	// 绑定时已使用当前时间步，同一验证码不能重放；下一时间步在允许偏差内
+	if _, err := env.svc.CompleteMFALogin(ctx, res.MFAToken, totpCode(t, secret, time.Now()), ClientInfo{}); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("replayed code: got %v", err)
+	}
+	pair, err := env.svc.CompleteMFALogin(ctx, res.MFAToken, totpCode(t, secret, time.Now().Add(30*time.Second)), ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, pair.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	// mfa_pending Token 单次有效
+	if _, err := env.svc.CompleteMFALogin(ctx, res.MFAToken, totpCode(t, secret, time.Now().Add(-30*time.Second)), ClientInfo{}); !errors.Is(err, ErrMFATokenInvalid) {
+		t.Fatalf("reuse mfa token: got %v", err)
+	}
+}
+
+func TestMFALoginAttemptLimit(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	secret, _ := env.enableMFA(t, u.ID)
+
+	res, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < maxMFALoginAttempt; i++ {
+		if _, err := env.svc.CompleteMFALogin(ctx, res.MFAToken, "000000", ClientInfo{}); !errors.Is(err, ErrMFACodeInvalid) {
+			t.Fatalf("attempt %d: got %v", i, err)
+		}
+	}
+	if _, err := env.svc.CompleteMFALogin(ctx, res.MFAToken, "000000", ClientInfo{}); !errors.Is(err, ErrMFATokenInvalid) {
+		t.Fatalf("last attempt: got %v, want ErrMFATokenInvalid", err)
+	}
+	// 作废后正确的验证码也不再接受
+	if _, err := env.svc.CompleteMFALogin(ctx, res.MFAToken, totpCode(t, secret, time.Now().Add(30*time.Second)), ClientInfo{}); !errors.Is(err, ErrMFATokenInvalid) {
+		t.Fatalf("after lockout: got %v", err)
+	}
+}
+
+func TestMFARecoveryCodes(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	_, codes := env.enableMFA(t, u.ID)
+
+	loginWith := func(code string) error {
+		res, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", ClientInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = env.svc.CompleteMFALogin(ctx, res.MFAToken, code, ClientInfo{})
+		return err
+	}
+
+	// 恢复码忽略大小写与分隔符，核销后失效
+	if err := loginWith(" " + strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")) + " "); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := loginWith(codes[0]); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("reused recovery code: got %v", err)
+	}
+	if err := loginWith(codes[1]); err != nil {
+		t.Fatalf("second recovery code: %v", err)
+	}
+
+	// 关闭二次验证同样接受恢复码
+	if err := env.svc.DisableMFA(ctx, u.ID, "zzzzz-zzzzz"); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("disable with bad code: got %v", err)
+	}
+	if err := env.svc.DisableMFA(ctx, u.ID, codes[2]); err != nil {
+		t.Fatal(err)
+	}
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+}
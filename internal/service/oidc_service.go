@@ -0,0 +1,571 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// OIDC 标准 scope
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var oidcScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+var (
+	ErrOAuthClientNotFound = errors.New("OAuth 客户端不存在")
+	ErrOAuthClientInvalid  = errors.New("OAuth 客户端配置无效")
+)
+
+// OAuthError OAuth2 / OIDC 协议错误 (RFC 6749 §4.1.2.1, §5.2)
+// RedirectURI 非空时错误应通过重定向返回给客户端，否则直接响应给浏览器 / 调用方
+type OAuthError struct {
+	Code        string
+	Description string
+	RedirectURI string
+	State       string
+}
+
+func (e *OAuthError) Error() string { return e.Code + ": " + e.Description }
+
+func oauthError(code, desc string) *OAuthError {
+	return &OAuthError{Code: code, Description: desc}
+}
+
+// AuthorizeRequest 授权请求参数 (/oauth/authorize)
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	Nonce               string `json:"nonce"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// ClientCredentials Token 端点的客户端认证信息 (client_secret_basic / client_secret_post / none)
+type ClientCredentials struct {
+	ID     string
+	Secret string
+}
+
+// OAuthTokenResponse Token 端点响应 (RFC 6749 §5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OIDCService 本服务作为 OpenID Connect Provider：客户端注册、授权码 (PKCE)、client_credentials、UserInfo
+// 授权码换取的 Token 类型为 oauth_access / oauth_refresh，带 client_id 与 scope，只能访问 /oauth/userinfo 与 /oauth/token，
+// 不能调用用户 API；其会话同样出现在用户的设备列表中，随"退出所有设备"失效
+type OIDCService struct {
+	clients repository.OAuthClientRepository
+	codes   repository.OAuthCodeRepository
+	users   *UserService
+	jwt     *utils.JWTManager
+	cfg     *config.Config
+}
+
+func NewOIDCService(clients repository.OAuthClientRepository, codes repository.OAuthCodeRepository, users *UserService, jwtMgr *utils.JWTManager, cfg *config.Config) *OIDCService {
+	return &OIDCService{clients: clients, codes: codes, users: users, jwt: jwtMgr, cfg: cfg}
+}
+
+func (s *OIDCService) codeTTL() time.Duration {
+	if s.cfg.OIDC.CodeTTL <= 0 {
+		return time.Minute
+	}
+	return time.Duration(s.cfg.OIDC.CodeTTL) * time.Second
+}
+
+func (s *OIDCService) idTokenTTL() time.Duration {
+	if s.cfg.OIDC.IDTokenTTL <= 0 {
+		return time.Hour
+	}
+	return time.Duration(s.cfg.OIDC.IDTokenTTL) * time.Minute
+}
+
+func (s *OIDCService) clientTokenTTL() time.Duration {
+	if s.cfg.OIDC.ClientTokenTTL <= 0 {
+		return time.Hour
+	}
+	return time.Duration(s.cfg.OIDC.ClientTokenTTL) * time.Minute
+}
+
+// Discovery OpenID Provider 元数据 (/.well-known/openid-configuration)
+func (s *OIDCService) Discovery() map[string]interface{} {
+	issuer := strings.TrimRight(s.cfg.OIDC.Issuer, "/")
+	return map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"userinfo_endpoint":                     issuer + "/oauth/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{model.GrantAuthorizationCode, model.GrantClientCredentials, model.GrantRefreshToken},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{s.jwt.SigningAlgorithm()},
+		"scopes_supported":                      oidcScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "azp",
+			"name", "preferred_username", "picture", "email", "email_verified",
+		},
+	}
+}
+
+// --- 客户端注册 ---
+
+// RegisterClient 注册客户端，返回仅展示一次的 client_secret（公开客户端为空）
+func (s *OIDCService) RegisterClient(ctx context.Context, ownerID int, c *model.OAuthClient) (string, error) {
+	if strings.TrimSpace(c.Name) == "" {
+		return "", ErrOAuthClientInvalid
+	}
+	if len(c.GrantTypes) == 0 {
+		c.GrantTypes = []string{model.GrantAuthorizationCode, model.GrantRefreshToken}
+	}
+	for _, g := range c.GrantTypes {
+		switch g {
+		case model.GrantAuthorizationCode, model.GrantRefreshToken:
+		case model.GrantClientCredentials:
+			if c.Public {
+				return "", ErrOAuthClientInvalid // 公开客户端无法保管密钥
+			}
+		default:
+			return "", ErrOAuthClientInvalid
+		}
+	}
+	if slices.Contains(c.GrantTypes, model.GrantAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		return "", ErrOAuthClientInvalid
+	}
+	for _, uri := range c.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return "", ErrOAuthClientInvalid
+		}
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = oidcScopes
+	}
+
+	c.ID = utils.NewTokenID()
+	c.OwnerID = ownerID
+	c.CreatedAt = time.Now()
+
+	var secret string
+	if !c.Public {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		secret = base64.RawURLEncoding.EncodeToString(b)
+		c.SecretHash = utils.SHA256Hex(secret)
+	}
+
+	if err := s.clients.Create(ctx, c); err != nil {
+		return "", err
+	}
+	logger.Log.Info("OAuth 客户端已注册", zap.String("client_id", c.ID), zap.String("name", c.Name), zap.Int("owner_id", ownerID))
+	return secret, nil
+}
+
+func (s *OIDCService) ListClients(ctx context.Context) ([]model.OAuthClient, error) {
+	return s.clients.List(ctx)
+}
+
+func (s *OIDCService) DeleteClient(ctx context.Context, clientID string) error {
+	if err := s.clients.Delete(ctx, clientID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOAuthClientNotFound
+		}
+		return err
+	}
+	logger.Log.Info("OAuth 客户端已删除", zap.String("client_id", clientID))
+	return nil
+}
+
+// --- 授权端点 ---
+
+// ValidateAuthorizeRequest 校验授权请求；客户端与 redirect_uri 有效后的错误带 RedirectURI，应重定向回客户端
+func (s *OIDCService) ValidateAuthorizeRequest(ctx context.Context, req *AuthorizeRequest) (*model.OAuthClient, error) {
+	client, err := s.clients.Get(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, oauthError("invalid_client", "unknown client_id")
+	}
+
+	// redirect_uri 必须与注册值完全一致；客户端只注册了一个时可省略
+	if req.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		req.RedirectURI = client.RedirectURIs[0]
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, oauthError("invalid_request", "redirect_uri is not registered for this client")
+	}
+
+	fail := func(code, desc string) error {
+		return &OAuthError{Code: code, Description: desc, RedirectURI: req.RedirectURI, State: req.State}
+	}
+	if req.ResponseType != "code" {
+		return nil, fail("unsupported_response_type", "only response_type=code is supported")
+	}
+	if !slices.Contains(client.GrantTypes, model.GrantAuthorizationCode) {
+		return nil, fail("unauthorized_client", "client is not allowed to use authorization_code")
+	}
+	if req.Scope == "" {
+		req.Scope = ScopeOpenID
+	}
+	for _, sc := range strings.Fields(req.Scope) {
+		if !slices.Contains(client.Scopes, sc) {
+			return nil, fail("invalid_scope", "scope "+sc+" is not allowed for this client")
+		}
+	}
+	if req.CodeChallenge == "" {
+		if client.Public {
+			return nil, fail("invalid_request", "code_challenge is required for public clients")
+		}
+	} else if req.CodeChallengeMethod != "S256" {
+		return nil, fail("invalid_request", "code_challenge_method must be S256")
+	}
+	return client, nil
+}
+
+// Authorize 已登录用户同意授权，返回携带授权码的回调地址
+// 仅供内部应用接入，不单独展示授权确认页
+func (s *OIDCService) Authorize(ctx context.Context, claims *utils.Claims, req *AuthorizeRequest) (string, error) {
+	client, err := s.ValidateAuthorizeRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	// auth_time 取当前会话的登录时间
+	authTime := time.Now().Unix()
+	if claims.SessionID != "" {
+		if sess, err := s.users.tokenRepo.GetSession(ctx, claims.SessionID); err == nil && sess != nil {
+			authTime = sess.CreatedAt.Unix()
+		}
+	}
+
+	code := utils.NewTokenID() + utils.NewTokenID()
+	ac := &model.OAuthAuthCode{
+		ClientID:            client.ID,
+		UserID:              claims.UserID(),
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            authTime,
+	}
+	if err := s.codes.Save(ctx, code, ac, s.codeTTL()); err != nil {
+		return "", err
+	}
+
+	q := url.Values{"code": {code}}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	return appendQuery(req.RedirectURI, q), nil
+}
+
+// --- Token 端点 ---
+
+// ExchangeCode authorization_code：校验授权码、redirect_uri 与 PKCE，签发 Token 与 ID Token
+func (s *OIDCService) ExchangeCode(ctx context.Context, cred ClientCredentials, code, redirectURI, verifier string, info ClientInfo) (*OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, cred, model.GrantAuthorizationCode)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := s.codes.Consume(ctx, code)
+	if errors.Is(err, repository.ErrAuthCodeNotFound) {
+		return nil, oauthError("invalid_grant", "authorization code is invalid or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ac.ClientID != client.ID || ac.RedirectURI != redirectURI {
+		return nil, oauthError("invalid_grant", "authorization code was issued to another client or redirect_uri")
+	}
+	if ac.CodeChallenge != "" && !verifyPKCE(ac.CodeChallenge, verifier) {
+		return nil, oauthError("invalid_grant", "code_verifier does not match code_challenge")
+	}
+
+	user, err := s.users.repo.GetByID(ctx, ac.UserID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && user.Status == model.UserStatusSuspended) {
+		return nil, oauthError("invalid_grant", "user is not available")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info.Device = client.Name
+	info.OAuthClientID = client.ID
+	info.OAuthScope = ac.Scope
+	pair, err := s.users.issueTokenPair(ctx, user.ID, "", info)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &OAuthTokenResponse{
+		AccessToken: pair.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   pair.ExpiresIn,
+		Scope:       ac.Scope,
+	}
+	if slices.Contains(client.GrantTypes, model.GrantRefreshToken) {
+		resp.RefreshToken = pair.RefreshToken
+	}
+	if hasScope(ac.Scope, ScopeOpenID) {
+		resp.IDToken, err = s.signIDToken(user, client.ID, ac)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// RefreshGrant refresh_token：只能刷新本客户端授权码换取的会话，scope 保持不变
+func (s *OIDCService) RefreshGrant(ctx context.Context, cred ClientCredentials, refreshToken string) (*OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, cred, model.GrantRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := s.jwt.Parse(refreshToken, utils.TokenTypeOAuthRefresh)
+	if err != nil || c.SessionID == "" || c.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "refresh token is invalid")
+	}
+	sess, err := s.users.tokenRepo.GetSession(ctx, c.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil || sess.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "refresh token is invalid")
+	}
+
+	pair, err := s.users.rotateRefresh(ctx, c, ClientInfo{OAuthClientID: client.ID, OAuthScope: sess.Scope})
+	if err != nil {
+		if errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrRefreshReused) || errors.Is(err, utils.ErrInvalidToken) {
+			return nil, oauthError("invalid_grant", err.Error())
+		}
+		return nil, err
+	}
+	return &OAuthTokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    pair.ExpiresIn,
+		RefreshToken: pair.RefreshToken,
+		Scope:        sess.Scope,
+	}, nil
+}
+
+// ClientCredentialsGrant client_credentials：应用以自身身份调用，Token 的 sub 为 client_id
+func (s *OIDCService) ClientCredentialsGrant(ctx context.Context, cred ClientCredentials, scope string) (*OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, cred, model.GrantClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range strings.Fields(scope) {
+		if sc == ScopeOpenID || !slices.Contains(client.Scopes, sc) {
+			return nil, oauthError("invalid_scope", "scope "+sc+" is not allowed for this client")
+		}
+	}
+
+	claims := s.jwt.NewClientClaims(client.ID, scope, s.clientTokenTTL())
+	token, err := s.jwt.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.clientTokenTTL().Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// VerifyServiceToken 校验 client_credentials Token，返回 client_id
+// 内部应用以此作为服务身份调用 gRPC PolicyService 方法 (middleware.ServiceVerifiers)；Token 有效期见 oidc.client_token_ttl
+func (s *OIDCService) VerifyServiceToken(tokenString string) (string, error) {
+	c, err := s.jwt.ParseClientToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+	return c.Subject, nil
+}
+
+// authenticateClient 校验客户端身份以及是否允许使用该授权类型
+func (s *OIDCService) authenticateClient(ctx context.Context, cred ClientCredentials, grant string) (*model.OAuthClient, error) {
+	if cred.ID == "" {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	client, err := s.clients.Get(ctx, cred.ID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	if client.Public {
+		if cred.Secret != "" {
+			return nil, oauthError("invalid_client", "public clients must not send a client_secret")
+		}
+	} else if subtle.ConstantTimeCompare([]byte(utils.SHA256Hex(cred.Secret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	if !slices.Contains(client.GrantTypes, grant) {
+		return nil, oauthError("unauthorized_client", "client is not allowed to use "+grant)
+	}
+	return client, nil
+}
+
+// --- UserInfo / ID Token ---
+
+// ValidateToken 校验 OIDC 客户端持有的 Access Token（/oauth/userinfo 使用），吊销规则与用户 Token 相同
+// 只接受 oauth_access，直接登录签发的 Token 与 API Key 不能访问
+func (s *OIDCService) ValidateToken(ctx context.Context, tokenString string) (*utils.Claims, error) {
+	c, err := s.jwt.Parse(tokenString, utils.TokenTypeOAuthAccess)
+	if err != nil {
+		return nil, err
+	}
+	if c.ClientID == "" {
+		return nil, utils.ErrInvalidToken
+	}
+	if err := s.users.checkRevoked(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UserInfo 按 Access Token 的授权范围返回用户信息
+func (s *OIDCService) UserInfo(ctx context.Context, claims *utils.Claims) (map[string]interface{}, error) {
+	scope := claims.Scope
+	if claims.Type != utils.TokenTypeOAuthAccess || !hasScope(scope, ScopeOpenID) {
+		return nil, oauthError("insufficient_scope", "openid scope is required")
+	}
+
+	user, err := s.users.GetUser(ctx, claims.UserID())
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]interface{}{"sub": strconv.Itoa(user.ID)}
+	if hasScope(scope, ScopeProfile) {
+		info["name"] = displayName(user)
+		info["preferred_username"] = user.Name
+		if user.Avatar != "" {
+			info["picture"] = user.Avatar
+		}
+	}
+	if hasScope(scope, ScopeEmail) {
+		info["email"] = user.Email
+		info["email_verified"] = user.Status != model.UserStatusPending
+	}
+	return info, nil
+}
+
+// signIDToken 由用户模型生成 ID Token，profile / email claims 按授权范围填充
+func (s *OIDCService) signIDToken(user *model.User, clientID string, ac *model.OAuthAuthCode) (string, error) {
+	now := time.Now()
+	claims := &utils.IDTokenClaims{
+		Nonce:           ac.Nonce,
+		AuthTime:        jwt.NewNumericDate(time.Unix(ac.AuthTime, 0)),
+		AuthorizedParty: clientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    strings.TrimRight(s.cfg.OIDC.Issuer, "/"),
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.idTokenTTL())),
+		},
+	}
+	if hasScope(ac.Scope, ScopeProfile) {
+		claims.Name = displayName(user)
+		claims.PreferredUsername = user.Name
+		claims.Picture = user.Avatar
+	}
+	if hasScope(ac.Scope, ScopeEmail) {
+		verified := user.Status != model.UserStatusPending
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+	return s.jwt.SignIDToken(claims)
+}
+
+// verifyPKCE S256: BASE64URL(SHA256(code_verifier)) == code_challenge
+func verifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func hasScope(scope, want string) bool {
+	return slices.Contains(strings.Fields(scope), want)
+}
+
+func displayName(u *model.User) string {
+	if u.Nickname != "" {
+		return u.Nickname
+	}
+	return u.Name
+}
+
+// appendQuery 在回调地址上追加参数，保留其原有的查询参数
+func appendQuery(rawURL string, q url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	existing := u.Query()
+	for k, vs := range q {
+		for _, v := range vs {
+			existing.Add(k, v)
+		}
+	}
+	u.RawQuery = existing.Encode()
+	return u.String()
+}
+
+// RedirectURL 携带错误参数的回调地址 (RedirectURI 非空时有效)
+func (e *OAuthError) RedirectURL() string {
+	q := url.Values{"error": {e.Code}, "error_description": {e.Description}}
+	if e.State != "" {
+		q.Set("state", e.State)
+	}
+	return appendQuery(e.RedirectURI, q)
+}
+
+// LoginURL 前端登录授权页地址
+func (s *OIDCService) LoginURL() string {
+	return s.cfg.OIDC.LoginURL
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+const testRedirectURI = "https://app.example.com/callback"
+
+func newOIDCEnv(t *testing.T) (*testEnv, *OIDCService) {
+	t.Helper()
+	env := newTestEnv(t, func(c *config.Config) {
+		c.OIDC = config.OIDCConfig{Issuer: "https://account.example.com/", LoginURL: "https://account.example.com/login", CodeTTL: 60}
+	})
+	oidc := NewOIDCService(newFakeOAuthClientRepo(), repository.NewOAuthCodeRepository(env.rdb), env.svc, env.jwt, env.cfg)
+	return env, oidc
+}
+
+func registerClient(t *testing.T, oidc *OIDCService, c *model.OAuthClient) ClientCredentials {
+	t.Helper()
+	if c.Name == "" {
+		c.Name = "wiki"
+	}
+	secret, err := oidc.RegisterClient(context.Background(), 1, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ClientCredentials{ID: c.ID, Secret: secret}
+}
+
+// pkce 返回 code_verifier 与对应的 S256 code_challenge
+func pkce(seed string) (string, string) {
+	verifier := strings.Repeat(seed, 43/len(seed)+1)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authorizeCode 以用户的登录会话同意授权，返回回调地址中的授权码
+func authorizeCode(t *testing.T, env *testEnv, oidc *OIDCService, pair *utils.TokenPair, req *AuthorizeRequest) string {
+	t.Helper()
+	claims, err := env.svc.ValidateToken(context.Background(), pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect, err := oidc.Authorize(context.Background(), claims, req)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != req.RedirectURI {
+		t.Fatalf("redirected to %s, want %s", got, req.RedirectURI)
+	}
+	if u.Query().Get("state") != req.State {
+		t.Fatalf("state = %q, want %q", u.Query().Get("state"), req.State)
+	}
+	return u.Query().Get("code")
+}
+
+func oauthErrorCode(err error) string {
+	var oe *OAuthError
+	if errors.As(err, &oe) {
+		return oe.Code
+	}
+	return ""
+}
+
+func TestAuthorizationCodeWithPKCE(t *testing.T) {
+	env, oidc := newOIDCEnv(t)
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	session := env.login(t, "alice@example.com", "Correct-Horse-9")
+	cred := registerClient(t, oidc, &model.OAuthClient{Public: true, RedirectURIs: []string{testRedirectURI}})
+	if cred.Secret != "" {
+		t.Fatal("public client got a secret")
+	}
+
+	// 公开客户端必须使用 PKCE
+	_, err := oidc.ValidateAuthorizeRequest(ctx, &AuthorizeRequest{ResponseType: "code", ClientID: cred.ID, RedirectURI: testRedirectURI})
+	if oauthErrorCode(err) != "invalid_request" {
+		t.Fatalf("authorize without PKCE: got %v", err)
+	}
+	// 未注册的 redirect_uri 不重定向
+	_, err = oidc.ValidateAuthorizeRequest(ctx, &AuthorizeRequest{ResponseType: "code", ClientID: cred.ID, RedirectURI: "https://evil.example.com/cb"})
+	var oe *OAuthError
+	if !errors.As(err, &oe) || oe.RedirectURI != "" {
+		t.Fatalf("unregistered redirect_uri: got %v", err)
+	}
+
+	verifier, challenge := pkce("a1b2c3")
+	req := &AuthorizeRequest{
+		ResponseType: "code", ClientID: cred.ID, RedirectURI: testRedirectURI,
+		Scope: "openid email", State: "xyz", Nonce: "n-0S6", CodeChallenge: challenge, CodeChallengeMethod: "S256",
+	}
+
+	// verifier 错误时授权码同样作废
+	code := authorizeCode(t, env, oidc, session, req)
+	wrong, _ := pkce("zzzzzz")
+	if _, err := oidc.ExchangeCode(ctx, cred, code, testRedirectURI, wrong, ClientInfo{}); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("wrong verifier: got %v", err)
+	}
+	if _, err := oidc.ExchangeCode(ctx, cred, code, testRedirectURI, verifier, ClientInfo{}); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("reused code: got %v", err)
+	}
+
+	code = authorizeCode(t, env, oidc, session, req)
+	if _, err := oidc.ExchangeCode(ctx, cred, code, "https://app.example.com/other", verifier, ClientInfo{}); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("mismatched redirect_uri: got %v", err)
+	}
+
+	code = authorizeCode(t, env, oidc, session, req)
+	resp, err := oidc.ExchangeCode(ctx, cred, code, testRedirectURI, verifier, ClientInfo{IP: "203.0.113.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Scope != "openid email" || resp.RefreshToken == "" || resp.IDToken == "" {
+		t.Fatalf("token response = %+v", resp)
+	}
+
+	access, err := env.jwt.Parse(resp.AccessToken, utils.TokenTypeOAuthAccess)
+	if err != nil {
+		t.Fatalf("access token type: %v", err)
+	}
+	if access.ClientID != cred.ID || access.Scope != "openid email" {
+		t.Fatalf("access claims client=%q scope=%q", access.ClientID, access.Scope)
+	}
+
+	var id utils.IDTokenClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(resp.IDToken, &id); err != nil {
+		t.Fatal(err)
+	}
+	if id.Nonce != "n-0S6" || id.Issuer != "https://account.example.com" || len(id.Audience) != 1 || id.Audience[0] != cred.ID {
+		t.Fatalf("id token claims = %+v", id)
+	}
+	if id.Email != "alice@example.com" || id.Name != "" {
+		t.Fatalf("id token email=%q name=%q, want only email claims", id.Email, id.Name)
+	}
+}
+
+func TestOAuthTokensRejectedByUserAPI(t *testing.T) {
+	env, oidc := newOIDCEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	session := env.login(t, "alice@example.com", "Correct-Horse-9")
+	cred := registerClient(t, oidc, &model.OAuthClient{RedirectURIs: []string{testRedirectURI}})
+
+	code := authorizeCode(t, env, oidc, session, &AuthorizeRequest{ResponseType: "code", ClientID: cred.ID, RedirectURI: testRedirectURI, Scope: "openid"})
+	resp, err := oidc.ExchangeCode(ctx, cred, code, testRedirectURI, "", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 用户 API (HTTP / gRPC 鉴权)、Token 内省与 /api/v1/refresh 均不接受客户端 Token
+	if _, err := env.svc.ValidateToken(ctx, resp.AccessToken); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("ValidateToken(oauth access): got %v", err)
+	}
+	if _, err := env.svc.RefreshToken(ctx, resp.RefreshToken); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("RefreshToken(oauth refresh): got %v", err)
+	}
+	if res, _ := env.svc.IntrospectToken(ctx, resp.AccessToken); res.Active {
+		t.Fatal("oauth access token introspected as active user token")
+	}
+
+	// /oauth/userinfo 只接受客户端 Token
+	if _, err := oidc.ValidateToken(ctx, resp.AccessToken); err != nil {
+		t.Fatalf("oidc ValidateToken: %v", err)
+	}
+	if _, err := oidc.ValidateToken(ctx, session.AccessToken); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("oidc ValidateToken(first-party): got %v", err)
+	}
+	if _, err := oidc.RefreshGrant(ctx, cred, session.RefreshToken); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("RefreshGrant(first-party refresh): got %v", err)
+	}
+
+	// 退出所有设备同样作废客户端 Token
+	if err := env.svc.LogoutAll(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oidc.ValidateToken(ctx, resp.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("after logout-all: got %v", err)
+	}
+}
+
+func TestUserInfoScope(t *testing.T) {
+	env, oidc := newOIDCEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	if err := env.users.UpdateProfile(ctx, u.ID, "Alice", 30, "https://cdn.example.com/a.png"); err != nil {
+		t.Fatal(err)
+	}
+	session := env.login(t, "alice@example.com", "Correct-Horse-9")
+	cred := registerClient(t, oidc, &model.OAuthClient{RedirectURIs: []string{testRedirectURI}})
+	other := registerClient(t, oidc, &model.OAuthClient{Name: "crm", RedirectURIs: []string{testRedirectURI}})
+
+	exchange := func(scope string) *OAuthTokenResponse {
+		t.Helper()
+		code := authorizeCode(t, env, oidc, session, &AuthorizeRequest{ResponseType: "code", ClientID: cred.ID, RedirectURI: testRedirectURI, Scope: scope})
+		resp, err := oidc.ExchangeCode(ctx, cred, code, testRedirectURI, "", ClientInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	userInfo := func(token string) (map[string]interface{}, error) {
+		t.Helper()
+		claims, err := oidc.ValidateToken(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return oidc.UserInfo(ctx, claims)
+	}
+
+	info, err := userInfo(exchange("openid profile").AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["sub"] != "1" || info["name"] != "Alice" || info["picture"] != "https://cdn.example.com/a.png" {
+		t.Fatalf("profile userinfo = %v", info)
+	}
+	if _, ok := info["email"]; ok {
+		t.Fatalf("email returned without email scope: %v", info)
+	}
+
+	if _, err := userInfo(exchange("profile").AccessToken); oauthErrorCode(err) != "insufficient_scope" {
+		t.Fatalf("userinfo without openid: got %v", err)
+	}
+
+	// 刷新后 scope 不变；其他客户端不能使用该 Refresh Token
+	resp := exchange("openid email")
+	if _, err := oidc.RefreshGrant(ctx, other, resp.RefreshToken); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("refresh by other client: got %v", err)
+	}
+	refreshed, err := oidc.RefreshGrant(ctx, cred, resp.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Scope != "openid email" {
+		t.Fatalf("refreshed scope = %q", refreshed.Scope)
+	}
+	if info, err = userInfo(refreshed.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if info["email"] != "alice@example.com" || info["name"] != nil {
+		t.Fatalf("email userinfo = %v", info)
+	}
+	if _, err := oidc.RefreshGrant(ctx, cred, resp.RefreshToken); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("replayed refresh token: got %v", err)
+	}
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	env, oidc := newOIDCEnv(t)
+	ctx := context.Background()
+
+	if _, err := oidc.RegisterClient(ctx, 1, &model.OAuthClient{Name: "spa", Public: true, GrantTypes: []string{model.GrantClientCredentials}}); !errors.Is(err, ErrOAuthClientInvalid) {
+		t.Fatalf("public client_credentials client: got %v", err)
+	}
+	cred := registerClient(t, oidc, &model.OAuthClient{GrantTypes: []string{model.GrantClientCredentials}, Scopes: []string{"profile", "email"}})
+	codeOnly := registerClient(t, oidc, &model.OAuthClient{Name: "web", RedirectURIs: []string{testRedirectURI}})
+
+	if _, err := oidc.ClientCredentialsGrant(ctx, ClientCredentials{ID: cred.ID, Secret: "wrong"}, "profile"); oauthErrorCode(err) != "invalid_client" {
+		t.Fatalf("wrong secret: got %v", err)
+	}
+	if _, err := oidc.ClientCredentialsGrant(ctx, codeOnly, "profile"); oauthErrorCode(err) != "unauthorized_client" {
+		t.Fatalf("grant not allowed: got %v", err)
+	}
+	for _, scope := range []string{"openid", "profile admin"} {
+		if _, err := oidc.ClientCredentialsGrant(ctx, cred, scope); oauthErrorCode(err) != "invalid_scope" {
+			t.Fatalf("scope %q: got %v", scope, err)
+		}
+	}
+
+	resp, err := oidc.ClientCredentialsGrant(ctx, cred, "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RefreshToken != "" || resp.IDToken != "" || resp.Scope != "email" {
+		t.Fatalf("token response = %+v", resp)
+	}
+	var claims utils.Claims
+	if _, _, err := jwt.NewParser().ParseUnverified(resp.AccessToken, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Type != utils.TokenTypeClient || claims.Subject != cred.ID || claims.Scope != "email" {
+		t.Fatalf("client token claims = %+v", claims)
+	}
+
+	// 应用身份的 Token 不代表任何用户
+	if _, err := env.svc.ValidateToken(ctx, resp.AccessToken); err == nil {
+		t.Fatal("client token accepted as user token")
+	}
+	if _, err := oidc.ValidateToken(ctx, resp.AccessToken); err == nil {
+		t.Fatal("client token accepted by userinfo")
+	}
+
+	// 作为服务身份调用 gRPC 服务间接口，用户 Token 不能冒充
+	if name, err := oidc.VerifyServiceToken(resp.AccessToken); err != nil || name != cred.ID {
+		t.Fatalf("VerifyServiceToken = %q, %v", name, err)
+	}
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	session := env.login(t, "alice@example.com", "Correct-Horse-9")
+	if _, err := oidc.VerifyServiceToken(session.AccessToken); err == nil {
+		t.Fatal("user token accepted as client token")
+	}
+}
@@ -2,19 +2,29 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/netkey/golang-user-mysql-redis/pkg/discovery"
 	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
 	"github.com/netkey/golang-user-mysql-redis/pkg/pb"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type OrderService struct {
 	userClient pb.UserServiceClient // gRPC 客户端
 }
 
-func NewOrderService(etcdEndpoints []string) (*OrderService, error) {
+// NewOrderService creds 为服务间凭证 (utils.ServiceTokenSource)，每次调用自动携带服务 Token
+// tlsCfg 连接 User Service 的 TLS 配置；携带本服务客户端证书且被列入 grpc_auth.cert_services 时，creds 可为 nil
+func NewOrderService(etcdEndpoints []string, etcdTLS, tlsCfg *tls.Config, creds credentials.PerRPCCredentials) (*OrderService, error) {
+	var opts []grpc.DialOption
+	if creds != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(creds))
+	}
 	// 1. 通过 Etcd 发现并建立连接
-	conn, err := discovery.GetGRPCClient(etcdEndpoints, "user-service")
+	conn, err := discovery.GetGRPCClient(etcdEndpoints, etcdTLS, "user-service", tlsCfg, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +35,18 @@ func NewOrderService(etcdEndpoints []string) (*OrderService, error) {
 	}, nil
 }
 
+// AuthenticateUser 通过 User Service 内省终端用户的 Token，返回用户 ID
+func (s *OrderService) AuthenticateUser(ctx context.Context, token string) (int32, error) {
+	resp, err := s.userClient.IntrospectToken(ctx, &pb.IntrospectTokenRequest{Token: token})
+	if err != nil {
+		return 0, fmt.Errorf("failed to introspect token: %v", err)
+	}
+	if !resp.Active {
+		return 0, utils.ErrInvalidToken
+	}
+	return resp.UserId, nil
+}
+
 func (s *OrderService) CreateOrder(ctx context.Context, userID int32) error {
 	// 3. 跨服务调用 User Service 的 gRPC 接口
 	user, err := s.userClient.GetUserByID(ctx, &pb.GetUserRequest{Id: userID})
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/mailer"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const purposePasswordReset = "password_reset"
+
+var (
+	// ErrResetLinkInvalid 重置链接无效、已使用或已过期
+	ErrResetLinkInvalid = errors.New("重置链接无效或已过期")
+	// ErrWrongPassword 修改密码时原密码错误
+	ErrWrongPassword = errors.New("原密码错误")
+)
+
+// rehashPassword 登录成功后按当前配置的算法与参数重新哈希（不影响已签发的 Token）
+// 失败只记录日志，下次登录会再次尝试
+func (s *UserService) rehashPassword(ctx context.Context, userID int, password string) {
+	hashed, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repo.UpdatePassword(ctx, userID, hashed)
+	}
+	if err != nil {
+		logger.Log.Warn("密码哈希升级失败", zap.Int("user_id", userID), zap.Error(err))
+		return
+	}
+	logger.Log.Info("密码哈希已升级", zap.Int("user_id", userID))
+}
+
+func (s *UserService) resetTokenTTL() time.Duration {
+	if s.cfg.PasswordReset.TokenTTL <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(s.cfg.PasswordReset.TokenTTL) * time.Minute
+}
+
+// ForgotPassword 申请重置密码，向注册邮箱发送单次有效的重置链接
+// 冷却按邮箱计算且先于查询用户：邮箱是否存在的返回完全一致，避免被用于探测注册邮箱
+func (s *UserService) ForgotPassword(ctx context.Context, email string) error {
+	interval := time.Duration(s.cfg.PasswordReset.ResendInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ok, err := s.otRepo.AcquireCooldown(ctx, purposePasswordReset, normalizeEmail(email), interval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrResendTooFrequent
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	// Token 为带签名的 JWT (typ=password_reset)，jti 存入 Redis 保证单次有效
+	claims := s.jwt.NewClaims(user.ID, utils.TokenTypePasswordReset, s.resetTokenTTL())
+	token, err := s.jwt.Sign(claims)
+	if err != nil {
+		return err
+	}
+	if err := s.otRepo.Save(ctx, purposePasswordReset, claims.ID, user.ID, s.resetTokenTTL()); err != nil {
+		return err
+	}
+
+	link := s.cfg.PasswordReset.LinkURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "重置您的密码",
+		Body: fmt.Sprintf("%s 您好：\n\n请在 %d 分钟内点击以下链接重置密码，链接仅可使用一次：\n%s\n\n如非本人操作，请忽略此邮件，您的密码不会被修改。\n",
+			user.Nickname, int(s.resetTokenTTL().Minutes()), link),
+	})
+}
+
+// ResetPassword 使用重置链接中的 Token 设置新密码，并使该用户所有已签发的 Token 失效
+func (s *UserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	claims, err := s.jwt.Parse(token, utils.TokenTypePasswordReset)
+	if err != nil {
+		return ErrResetLinkInvalid
+	}
+
+	// 先校验密码策略再核销链接，新密码不合格时用户可以换个密码重试
+	user, err := s.repo.GetByID(ctx, claims.UserID())
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrResetLinkInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.checkPasswordPolicy(user, newPassword); err != nil {
+		return err
+	}
+
+	userID, err := s.otRepo.Consume(ctx, purposePasswordReset, claims.ID)
+	if errors.Is(err, repository.ErrOneTimeTokenNotFound) || (err == nil && userID != user.ID) {
+		return ErrResetLinkInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, userID, newPassword); err != nil {
+		return err
+	}
+	logger.Log.Info("密码已重置", zap.Int("user_id", userID))
+	return nil
+}
+
+// ChangePassword 已登录用户修改密码：校验原密码，成功后其他设备全部下线，当前设备返回新的 Token 对
+func (s *UserService) ChangePassword(ctx context.Context, userID int, oldPassword, newPassword string, client ClientInfo) (*utils.TokenPair, error) {
+	if err := refuseImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// 未设置过密码的第三方账号用户需通过找回密码流程设置
+	if user.Password == unusablePassword {
+		return nil, ErrWrongPassword
+	}
+	ok, _, err := s.hasher.Verify(oldPassword, user.Password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrWrongPassword
+	}
+	if err := s.checkPasswordPolicy(user, newPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.setPassword(ctx, userID, newPassword); err != nil {
+		return nil, err
+	}
+	return s.issueTokenPair(ctx, userID, "", client)
+}
+
+// checkPasswordPolicy 新密码的强度校验，并禁止与该用户的邮箱、账号名、昵称相似
+func (s *UserService) checkPasswordPolicy(user *model.User, password string) error {
+	return s.policy.Check(password, user.Email, user.Name, user.Nickname)
+}
+
+// setPassword 更新密码哈希，并使之前签发的 Token 与所有会话全部失效
+func (s *UserService) setPassword(ctx context.Context, userID int, newPassword string) error {
+	hashed, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, hashed); err != nil {
+		return err
+	}
+	_ = s.repo.DeleteCache(ctx, userID)
+
+	return s.LogoutAll(ctx, userID)
+}
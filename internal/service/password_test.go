@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func withPasswordReset(c *config.Config) {
+	c.PasswordReset = config.PasswordResetConfig{TokenTTL: 30, ResendInterval: 60, LinkURL: "http://localhost/reset"}
+}
+
+func TestForgotAndResetPassword(t *testing.T) {
+	env := newTestEnv(t, withPasswordReset)
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	before := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	if err := env.svc.ForgotPassword(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := env.mailToken(t, "alice@example.com")
+
+	// 新密码不符合策略时不核销链接
+	if err := env.svc.ResetPassword(ctx, token, "short"); err == nil {
+		t.Fatal("weak password accepted")
+	}
+	if err := env.svc.ResetPassword(ctx, token, "Battery-Staple-7"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 链接单次有效，重置前签发的 Token 全部失效
+	if err := env.svc.ResetPassword(ctx, token, "Another-Secret-8"); !errors.Is(err, ErrResetLinkInvalid) {
+		t.Fatalf("reuse link: got %v, want ErrResetLinkInvalid", err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, before.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("old access token: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := env.svc.RefreshToken(ctx, before.RefreshToken); err == nil {
+		t.Fatal("old refresh token still works")
+	}
+
+	if _, err := env.svc.Login(ctx, "alice@example.com", "Correct-Horse-9", ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("login with old password: got %v", err)
+	}
+	env.login(t, "alice@example.com", "Battery-Staple-7")
+}
+
+func TestForgotPasswordUnknownEmailAndCooldown(t *testing.T) {
+	env := newTestEnv(t, withPasswordReset)
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	// 冷却期内的响应与邮箱是否注册无关，且不区分大小写
+	for _, email := range []string{"nobody@example.com", "alice@example.com"} {
+		if err := env.svc.ForgotPassword(ctx, email); err != nil {
+			t.Fatalf("%s: %v", email, err)
+		}
+		if err := env.svc.ForgotPassword(ctx, email); !errors.Is(err, ErrResendTooFrequent) {
+			t.Fatalf("%s: second request within cooldown: got %v", email, err)
+		}
+	}
+	if err := env.svc.ForgotPassword(ctx, "Alice@Example.com"); !errors.Is(err, ErrResendTooFrequent) {
+		t.Fatalf("case variant within cooldown: got %v", err)
+	}
+
+	// 只向已注册的邮箱发送
+	if msgs := env.mail.Messages(); len(msgs) != 1 || msgs[0].To != "alice@example.com" {
+		t.Fatalf("sent mails = %+v", msgs)
+	}
+}
+
+func TestResetPasswordRejectsOtherTokens(t *testing.T) {
+	env := newTestEnv(t, withPasswordReset)
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	pair := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	if err := env.svc.ResetPassword(context.Background(), pair.AccessToken, "Battery-Staple-7"); !errors.Is(err, ErrResetLinkInvalid) {
+		t.Fatalf("access token as reset link: got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	other := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	if _, err := env.svc.ChangePassword(ctx, u.ID, "wrong-password", "Battery-Staple-7", ClientInfo{}); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong old password: got %v", err)
+	}
+	pair, err := env.svc.ChangePassword(ctx, u.ID, "Correct-Horse-9", "Battery-Staple-7", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他设备下线，当前设备使用返回的新 Token
+	if _, err := env.svc.ValidateToken(ctx, other.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("other device: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, pair.AccessToken); err != nil {
+		t.Fatalf("new token: %v", err)
+	}
+	env.login(t, "alice@example.com", "Battery-Staple-7")
+}
+
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	env := newTestEnv(t)
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	// 模拟迁移前的 bcrypt 哈希
+	legacy, err := bcrypt.GenerateFromPassword([]byte("Correct-Horse-9"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.users.UpdatePassword(context.Background(), u.ID, string(legacy)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 密码错误时不升级
+	if _, err := env.svc.Login(context.Background(), "alice@example.com", "wrong-password", ClientInfo{IP: "203.0.113.1"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: got %v", err)
+	}
+	if got := env.users.get(u.ID).Password; got != string(legacy) {
+		t.Fatalf("hash changed after failed login: %s", got)
+	}
+
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+	upgraded := env.users.get(u.ID).Password
+	if !strings.HasPrefix(upgraded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("hash after login = %s, want argon2id", upgraded)
+	}
+
+	// 参数已是最新时不再重写
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+	if got := env.users.get(u.ID).Password; got != upgraded {
+		t.Fatal("up-to-date hash rewritten on login")
+	}
+}
+
+func TestPasswordPolicyOnRegisterAndChange(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.svc.Register(ctx, "bob", "Bob", "bob@example.com", "short"); !errors.Is(err, utils.ErrWeakPassword) {
+		t.Fatalf("register: got %v, want ErrWeakPassword", err)
+	}
+	if u, _ := env.users.GetByEmail(ctx, "bob@example.com"); u != nil {
+		t.Fatal("user created with weak password")
+	}
+
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	if _, err := env.svc.ChangePassword(ctx, u.ID, "Correct-Horse-9", "short", ClientInfo{}); !errors.Is(err, utils.ErrWeakPassword) {
+		t.Fatalf("change: got %v, want ErrWeakPassword", err)
+	}
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+)
+
+// sessionTouchInterval 最后活跃时间的最小更新间隔，避免每个请求都写 Redis
+const sessionTouchInterval = time.Minute
+
+// maxDeviceNameLen 设备名最大长度（按字符计）
+const maxDeviceNameLen = 64
+
+// ErrSessionNotFound 会话不存在或不属于当前用户
+var ErrSessionNotFound = errors.New("会话不存在")
+
+// deviceName 优先使用客户端自报的设备名，否则根据 User-Agent 粗略推断
+func (c ClientInfo) deviceName() string {
+	if d := strings.TrimSpace(c.Device); d != "" {
+		if r := []rune(d); len(r) > maxDeviceNameLen {
+			d = string(r[:maxDeviceNameLen])
+		}
+		return d
+	}
+	return deviceFromUserAgent(c.UserAgent)
+}
+
+// deviceFromUserAgent 从 User-Agent 中识别浏览器与操作系统，如 "Chrome on Windows"
+func deviceFromUserAgent(ua string) string {
+	if ua == "" {
+		return "未知设备"
+	}
+
+	var os string
+	switch {
+	case strings.Contains(ua, "iPhone"):
+		os = "iPhone"
+	case strings.Contains(ua, "iPad"):
+		os = "iPad"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	}
+
+	var browser string
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+	// 非浏览器客户端（如 curl/8.0、App 自定义 UA）取第一个 token
+	name, _, _ := strings.Cut(ua, " ")
+	return name
+}
+
+// ListSessions 列出用户当前有效的登录会话，按最后活跃时间倒序，currentID 对应的会话标记为 Current
+func (s *UserService) ListSessions(ctx context.Context, userID int, currentID string) ([]model.Session, error) {
+	sessions, err := s.tokenRepo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
+
+// RevokeSession 移除指定会话（下线单个设备），该会话的 Access / Refresh Token 随即失效
+func (s *UserService) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	if err := refuseImpersonation(ctx); err != nil {
+		return err
+	}
+	sess, err := s.tokenRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// 不属于当前用户的会话与不存在的会话返回相同错误，避免探测他人会话 ID
+	if sess == nil || sess.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return s.tokenRepo.DeleteSession(ctx, userID, sessionID)
+}
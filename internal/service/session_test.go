@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListSessionsMarksCurrent(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	env.login(t, "alice@example.com", "Correct-Horse-9")
+	phone := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	claims, err := env.svc.ValidateToken(ctx, phone.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := env.svc.ListSessions(ctx, u.ID, claims.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+	for _, s := range sessions {
+		if s.Current != (s.ID == claims.SessionID) {
+			t.Errorf("session %s: Current = %v", s.ID, s.Current)
+		}
+		if s.IP != "203.0.113.1" {
+			t.Errorf("session %s: IP = %q", s.ID, s.IP)
+		}
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	alice := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	bob := env.createUser(t, "bob@example.com", "Correct-Horse-9")
+	pair := env.login(t, "alice@example.com", "Correct-Horse-9")
+	claims, err := env.svc.ValidateToken(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 他人的会话与不存在的会话返回相同错误
+	if err := env.svc.RevokeSession(ctx, bob.ID, claims.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoke other user's session: got %v, want ErrSessionNotFound", err)
+	}
+	if err := env.svc.RevokeSession(ctx, alice.ID, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoke missing session: got %v, want ErrSessionNotFound", err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, pair.AccessToken); err != nil {
+		t.Fatalf("token revoked by failed attempt: %v", err)
+	}
+
+	if err := env.svc.RevokeSession(ctx, alice.ID, claims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("access after revoke: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := env.svc.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("refresh after revoke: got %v, want ErrTokenRevoked", err)
+	}
+	sessions, err := env.svc.ListSessions(ctx, alice.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Fatalf("sessions after revoke = %+v", sessions)
+	}
+}
+
+func TestRefreshKeepsSessionListed(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	pair := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	// 持续刷新的会话在超过首次登录的 Refresh 有效期后仍出现在会话列表中
+	ttl := env.svc.refreshTTL()
+	for i := 0; i < 3; i++ {
+		env.mr.FastForward(ttl / 2)
+		var err error
+		if pair, err = env.svc.RefreshToken(ctx, pair.RefreshToken); err != nil {
+			t.Fatalf("refresh %d: %v", i, err)
+		}
+	}
+	sessions, err := env.svc.ListSessions(ctx, u.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("got %d sessions after %v, want 1", len(sessions), 3*ttl/2)
+	}
+	if got := env.mr.TTL("token:sessions:1"); got < ttl-time.Minute {
+		t.Fatalf("index ttl = %v, want about %v", got, ttl)
+	}
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/idp"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrProviderNotFound = errors.New("不支持的第三方登录方式")
+	// ErrSocialStateInvalid state 不存在、已使用、已过期或与提供方不匹配
+	ErrSocialStateInvalid = errors.New("第三方登录已失效，请重新发起")
+	ErrSocialAuthFailed   = errors.New("第三方账号验证失败")
+	// ErrIdentityLinked 该第三方账号已绑定其他用户
+	ErrIdentityLinked = errors.New("该第三方账号已绑定其他用户")
+	// ErrProviderLinked 当前用户已绑定同一提供方的其他账号
+	ErrProviderLinked   = errors.New("已绑定该平台的其他账号，请先解绑")
+	ErrIdentityNotFound = errors.New("未绑定该第三方账号")
+	// ErrSocialEmailInUse 邮箱已被本地账号使用但提供方未验证该邮箱，不能自动关联
+	ErrSocialEmailInUse = errors.New("该邮箱已注册，请使用密码登录后在账号设置中绑定")
+	ErrSocialNoEmail    = errors.New("第三方账号未提供邮箱，无法自动注册")
+	// ErrLastLoginMethod 未设置密码时不能解绑最后一个第三方账号，否则将无法登录
+	ErrLastLoginMethod = errors.New("这是唯一的登录方式，请先设置密码再解绑")
+)
+
+// SocialProvider 登录页展示的第三方登录方式
+type SocialProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// SocialService 第三方账号登录与绑定
+// 流程：Start 生成 state / nonce / PKCE 并返回提供方授权地址 → 提供方回跳前端 → 前端携带 code 与 state 调用 Callback
+type SocialService struct {
+	users      *UserService
+	identities repository.IdentityRepository
+	providers  map[string]idp.Provider
+	cfg        config.SocialLoginConfig
+}
+
+func NewSocialService(users *UserService, identities repository.IdentityRepository, providers map[string]idp.Provider, cfg config.SocialLoginConfig) *SocialService {
+	return &SocialService{
+		users:      users,
+		identities: identities,
+		providers:  providers,
+		cfg:        cfg,
+	}
+}
+
+func (s *SocialService) stateTTL() time.Duration {
+	if s.cfg.StateTTL <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(s.cfg.StateTTL) * time.Second
+}
+
+// Providers 已配置的第三方登录方式，按标识排序
+func (s *SocialService) Providers() []SocialProvider {
+	list := make([]SocialProvider, 0, len(s.providers))
+	for name := range s.providers {
+		display := s.cfg.Providers[name].DisplayName
+		if display == "" {
+			display = name
+		}
+		list = append(list, SocialProvider{Name: name, DisplayName: display})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Start 发起第三方登录，返回提供方授权地址；linkUserID 非 0 时为绑定流程，回调需走 LinkCallback
+func (s *SocialService) Start(ctx context.Context, provider string, linkUserID int) (string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", ErrProviderNotFound
+	}
+	if linkUserID != 0 {
+		if err := refuseImpersonation(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	state := &model.SocialLoginState{
+		Provider:     provider,
+		Nonce:        utils.NewTokenID(),
+		CodeVerifier: oauth2.GenerateVerifier(),
+		LinkUserID:   linkUserID,
+	}
+	stateID := utils.NewTokenID()
+	if err := s.identities.SaveState(ctx, stateID, state, s.stateTTL()); err != nil {
+		return "", err
+	}
+	return p.AuthURL(stateID, state.Nonce, state.CodeVerifier), nil
+}
+
+// Callback 登录回调：返回 Token 对或二次验证凭证
+// 绑定流程发起的 state 只能由发起绑定的用户通过 LinkCallback 使用
+func (s *SocialService) Callback(ctx context.Context, provider, code, stateID string, client ClientInfo) (*LoginResult, error) {
+	ident, err := s.exchange(ctx, provider, code, stateID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return s.login(ctx, provider, ident, client)
+}
+
+// LinkCallback 绑定回调，调用方必须是发起绑定的已登录用户
+// 防止攻击者发起绑定后诱导他人完成授权，把他人的第三方账号绑定到攻击者名下
+func (s *SocialService) LinkCallback(ctx context.Context, userID int, provider, code, stateID string) error {
+	if err := refuseImpersonation(ctx); err != nil {
+		return err
+	}
+	ident, err := s.exchange(ctx, provider, code, stateID, userID)
+	if err != nil {
+		return err
+	}
+	return s.link(ctx, userID, provider, ident)
+}
+
+// exchange 核销 state 并向提供方换取身份；state 必须属于该提供方且由 linkUserID 发起（0 为登录流程）
+func (s *SocialService) exchange(ctx context.Context, provider, code, stateID string, linkUserID int) (*idp.Identity, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+
+	// state 单次有效，防止 CSRF 与回调重放
+	state, err := s.identities.ConsumeState(ctx, stateID)
+	if errors.Is(err, repository.ErrSocialStateNotFound) || (err == nil && (state.Provider != provider || state.LinkUserID != linkUserID)) {
+		return nil, ErrSocialStateInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ident, err := p.Exchange(ctx, code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		logger.Log.Warn("第三方账号验证失败", zap.String("provider", provider), zap.Error(err))
+		return nil, ErrSocialAuthFailed
+	}
+	return ident, nil
+}
+
+// login 已绑定则直接登录；未绑定时按邮箱关联已有账号或自动注册
+func (s *SocialService) login(ctx context.Context, provider string, ident *idp.Identity, client ClientInfo) (*LoginResult, error) {
+	li, err := s.identities.Find(ctx, provider, ident.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var userID int
+	if li != nil {
+		userID = li.UserID
+		_ = s.identities.TouchLogin(ctx, li.ID)
+	} else {
+		userID, err = s.resolveUser(ctx, provider, ident)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.users.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.users.completeLogin(ctx, user, client)
+}
+
+// resolveUser 首次使用第三方账号登录：关联同邮箱的已有账号或创建新账号，并记录绑定关系
+func (s *SocialService) resolveUser(ctx context.Context, provider string, ident *idp.Identity) (int, error) {
+	email := strings.TrimSpace(ident.Email)
+	if email == "" {
+		return 0, ErrSocialNoEmail
+	}
+	// 提供方声明已验证且配置信任时，才认为邮箱归属可靠
+	verified := ident.EmailVerified && s.cfg.Providers[provider].TrustEmail
+
+	user, err := s.users.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return 0, err
+	}
+	if user != nil {
+		// 未验证的邮箱可能被他人在提供方注册，自动关联会导致账号被接管
+		if !verified {
+			return 0, ErrSocialEmailInUse
+		}
+	} else {
+		user, err = s.createUser(ctx, email, verified, ident)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	li := &model.LinkedIdentity{UserID: user.ID, Provider: provider, Subject: ident.Subject, Email: email}
+	if err := s.identities.Create(ctx, li); err != nil {
+		if errors.Is(err, repository.ErrIdentityExists) {
+			return 0, ErrProviderLinked
+		}
+		return 0, err
+	}
+	_ = s.identities.TouchLogin(ctx, li.ID)
+	logger.Log.Info("第三方账号已关联", zap.String("provider", provider), zap.Int("user_id", user.ID))
+	return user.ID, nil
+}
+
+// createUser 第三方账号自动注册；提供方未验证邮箱时按配置发送验证邮件
+func (s *SocialService) createUser(ctx context.Context, email string, verified bool, ident *idp.Identity) (*model.User, error) {
+	status := model.UserStatusNormal
+	if !verified && s.users.cfg.EmailVerify.Enable {
+		status = model.UserStatusPending
+	}
+	user, err := s.users.createPasswordlessUser(ctx, email, ident.Name, ident.Picture, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == model.UserStatusPending {
+		if err := s.users.sendVerificationEmail(ctx, user); err != nil {
+			logger.Log.Warn("验证邮件发送失败", zap.Int("user_id", user.ID), zap.Error(err))
+		}
+	}
+	return user, nil
+}
+
+// link 将第三方账号绑定到当前用户
+func (s *SocialService) link(ctx context.Context, userID int, provider string, ident *idp.Identity) error {
+	li, err := s.identities.Find(ctx, provider, ident.Subject)
+	if err != nil {
+		return err
+	}
+	if li != nil {
+		if li.UserID == userID {
+			return nil // 重复绑定视为成功
+		}
+		return ErrIdentityLinked
+	}
+
+	err = s.identities.Create(ctx, &model.LinkedIdentity{UserID: userID, Provider: provider, Subject: ident.Subject, Email: ident.Email})
+	if errors.Is(err, repository.ErrIdentityExists) {
+		return ErrProviderLinked
+	}
+	return err
+}
+
+// ListIdentities 当前用户已绑定的第三方账号
+func (s *SocialService) ListIdentities(ctx context.Context, userID int) ([]model.LinkedIdentity, error) {
+	list, err := s.identities.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []model.LinkedIdentity{}
+	}
+	return list, nil
+}
+
+// Unlink 解绑第三方账号；未设置密码的用户不能解绑最后一个
+func (s *SocialService) Unlink(ctx context.Context, userID int, provider string) error {
+	if err := refuseImpersonation(ctx); err != nil {
+		return err
+	}
+	user, err := s.users.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Password == unusablePassword {
+		list, err := s.identities.ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(list) <= 1 {
+			return ErrLastLoginMethod
+		}
+	}
+
+	if err := s.identities.Delete(ctx, userID, provider); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrIdentityNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/pkg/idp"
+)
+
+// stubProvider 测试用的桩提供方配置
+func stubProvider(subject, email string, verified, trust bool) config.SocialProviderConfig {
+	return config.SocialProviderConfig{
+		Type:        "stub",
+		RedirectURL: "http://localhost:3000/oauth/social/callback",
+		TrustEmail:  trust,
+		Stub:        config.StubIdentityConfig{Subject: subject, Email: email, EmailVerified: verified, Name: "Stub User"},
+	}
+}
+
+func newSocialEnv(t *testing.T, providers map[string]config.SocialProviderConfig) (*testEnv, *SocialService, *fakeIdentityRepo) {
+	t.Helper()
+	env := newTestEnv(t)
+	loaded, err := idp.Load(providers, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities := newFakeIdentityRepo(env.rdb)
+	social := NewSocialService(env.svc, identities, loaded, config.SocialLoginConfig{StateTTL: 600, Providers: providers, AllowStub: true})
+	return env, social, identities
+}
+
+// startSocial 发起登录或绑定，返回提供方回跳携带的 code 与 state
+func startSocial(t *testing.T, social *SocialService, provider string, linkUserID int) (code, state string) {
+	t.Helper()
+	authURL, err := social.Start(context.Background(), provider, linkUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query().Get("code"), u.Query().Get("state")
+}
+
+// socialCallback 走完一次 Start → 提供方回跳 → Callback；linkUserID 非 0 时以该用户身份完成绑定
+func socialCallback(t *testing.T, social *SocialService, provider string, linkUserID int) (*LoginResult, error) {
+	t.Helper()
+	code, state := startSocial(t, social, provider, linkUserID)
+	if linkUserID != 0 {
+		return nil, social.LinkCallback(context.Background(), linkUserID, provider, code, state)
+	}
+	return social.Callback(context.Background(), provider, code, state, ClientInfo{IP: "203.0.113.1"})
+}
+
+func TestSocialLoginAutoRegister(t *testing.T) {
+	env, social, identities := newSocialEnv(t, map[string]config.SocialProviderConfig{
+		"stub": stubProvider("stub-user-1", "new@example.com", true, true),
+	})
+	ctx := context.Background()
+
+	res, err := socialCallback(t, social, "stub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := env.svc.ValidateToken(ctx, res.TokenPair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := env.users.get(claims.UserID())
+	if u.Email != "new@example.com" || u.Password != unusablePassword || u.Status != model.UserStatusNormal {
+		t.Fatalf("registered user = %+v", u)
+	}
+	if list, _ := identities.ListByUser(ctx, u.ID); len(list) != 1 || list[0].Subject != "stub-user-1" {
+		t.Fatalf("identities = %+v", list)
+	}
+
+	// 再次登录使用已绑定的身份，不重复注册
+	res, err = socialCallback(t, social, "stub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := env.svc.ValidateToken(ctx, res.TokenPair.AccessToken)
+	if again.UserID() != u.ID || len(env.users.users) != 1 {
+		t.Fatalf("second login user %d, users %d", again.UserID(), len(env.users.users))
+	}
+	// 无密码账号不能用密码登录
+	if _, err := env.svc.Login(ctx, "new@example.com", unusablePassword, ClientInfo{IP: "203.0.113.1"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("password login on passwordless account: got %v", err)
+	}
+}
+
+func TestSocialLoginLinksVerifiedEmail(t *testing.T) {
+	env, social, identities := newSocialEnv(t, map[string]config.SocialProviderConfig{
+		"stub": stubProvider("stub-user-1", "alice@example.com", true, true),
+	})
+	alice := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	res, err := socialCallback(t, social, "stub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := env.svc.ValidateToken(context.Background(), res.TokenPair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID() != alice.ID {
+		t.Fatalf("logged in as %d, want existing user %d", claims.UserID(), alice.ID)
+	}
+	if list, _ := identities.ListByUser(context.Background(), alice.ID); len(list) != 1 {
+		t.Fatalf("identities = %+v", list)
+	}
+}
+
+func TestSocialLoginRefusesUnverifiedEmail(t *testing.T) {
+	env, social, identities := newSocialEnv(t, map[string]config.SocialProviderConfig{
+		"unverified": stubProvider("stub-user-1", "alice@example.com", false, true),
+		"untrusted":  stubProvider("stub-user-2", "alice@example.com", true, false),
+	})
+	alice := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	// 提供方未验证邮箱，或配置不信任该提供方的邮箱：都不能接管同邮箱的已有账号
+	for _, provider := range []string{"unverified", "untrusted"} {
+		if _, err := socialCallback(t, social, provider, 0); !errors.Is(err, ErrSocialEmailInUse) {
+			t.Fatalf("%s: got %v, want ErrSocialEmailInUse", provider, err)
+		}
+	}
+	if list, _ := identities.ListByUser(context.Background(), alice.ID); len(list) != 0 {
+		t.Fatalf("identity linked without verified email: %+v", list)
+	}
+
+	// 已登录用户主动绑定不依赖邮箱
+	if res, err := socialCallback(t, social, "unverified", alice.ID); err != nil || res != nil {
+		t.Fatalf("link: %v, %v", res, err)
+	}
+	if list, _ := identities.ListByUser(context.Background(), alice.ID); len(list) != 1 {
+		t.Fatalf("identities after link = %+v", list)
+	}
+}
+
+func TestSocialCallbackState(t *testing.T) {
+	_, social, _ := newSocialEnv(t, map[string]config.SocialProviderConfig{
+		"stub":  stubProvider("stub-user-1", "new@example.com", true, true),
+		"other": stubProvider("stub-user-2", "other@example.com", true, true),
+	})
+	ctx := context.Background()
+
+	authURL, err := social.Start(ctx, "stub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	code, state := u.Query().Get("code"), u.Query().Get("state")
+
+	// state 属于其他提供方：拒绝并作废
+	if _, err := social.Callback(ctx, "other", code, state, ClientInfo{}); !errors.Is(err, ErrSocialStateInvalid) {
+		t.Fatalf("state for another provider: got %v", err)
+	}
+	if _, err := social.Callback(ctx, "stub", code, state, ClientInfo{}); !errors.Is(err, ErrSocialStateInvalid) {
+		t.Fatalf("replayed state: got %v", err)
+	}
+
+	authURL, _ = social.Start(ctx, "stub", 0)
+	u, _ = url.Parse(authURL)
+	if _, err := social.Callback(ctx, "stub", "forged", u.Query().Get("state"), ClientInfo{}); !errors.Is(err, ErrSocialAuthFailed) {
+		t.Fatalf("forged code: got %v", err)
+	}
+	if _, err := social.Start(ctx, "missing", 0); !errors.Is(err, ErrProviderNotFound) {
+		t.Fatalf("unknown provider: got %v", err)
+	}
+}
+
+func TestSocialLinkBoundToInitiator(t *testing.T) {
+	env, social, identities := newSocialEnv(t, map[string]config.SocialProviderConfig{
+		"stub": stubProvider("stub-user-1", "mallory@example.com", true, true),
+	})
+	ctx := context.Background()
+	alice := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	mallory := env.createUser(t, "mallory@example.com", "Correct-Horse-9")
+
+	// Mallory 发起的绑定：由 Alice 完成回调，或当作登录回调使用，都被拒绝
+	code, state := startSocial(t, social, "stub", mallory.ID)
+	if err := social.LinkCallback(ctx, alice.ID, "stub", code, state); !errors.Is(err, ErrSocialStateInvalid) {
+		t.Fatalf("link by another user: got %v", err)
+	}
+	code, state = startSocial(t, social, "stub", mallory.ID)
+	if _, err := social.Callback(ctx, "stub", code, state, ClientInfo{}); !errors.Is(err, ErrSocialStateInvalid) {
+		t.Fatalf("link state used for login: got %v", err)
+	}
+	// 登录流程的 state 也不能用于绑定
+	code, state = startSocial(t, social, "stub", 0)
+	if err := social.LinkCallback(ctx, alice.ID, "stub", code, state); !errors.Is(err, ErrSocialStateInvalid) {
+		t.Fatalf("login state used for link: got %v", err)
+	}
+	for _, u := range []*model.User{alice, mallory} {
+		if list, _ := identities.ListByUser(ctx, u.ID); len(list) != 0 {
+			t.Fatalf("user %d identities = %+v", u.ID, list)
+		}
+	}
+
+	code, state = startSocial(t, social, "stub", mallory.ID)
+	if err := social.LinkCallback(ctx, mallory.ID, "stub", code, state); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnlinkLastLoginMethod(t *testing.T) {
+	env, social, identities := newSocialEnv(t, map[string]config.SocialProviderConfig{
+		"stub":  stubProvider("stub-user-1", "new@example.com", true, true),
+		"other": stubProvider("stub-user-2", "other@example.com", true, true),
+	})
+	ctx := context.Background()
+
+	res, err := socialCallback(t, social, "stub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, _ := env.svc.ValidateToken(ctx, res.TokenPair.AccessToken)
+	uid := claims.UserID()
+
+	if err := social.Unlink(ctx, uid, "stub"); !errors.Is(err, ErrLastLoginMethod) {
+		t.Fatalf("unlink only identity: got %v, want ErrLastLoginMethod", err)
+	}
+
+	// 绑定第二个身份后可以解绑其中一个，但不能解绑剩下的最后一个
+	if _, err := socialCallback(t, social, "other", uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := social.Unlink(ctx, uid, "stub"); err != nil {
+		t.Fatal(err)
+	}
+	if err := social.Unlink(ctx, uid, "other"); !errors.Is(err, ErrLastLoginMethod) {
+		t.Fatalf("unlink last identity: got %v", err)
+	}
+
+	// 设置密码的账号可以解绑全部身份
+	if err := env.users.UpdatePassword(ctx, uid, env.svc.dummyHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := social.Unlink(ctx, uid, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := identities.ListByUser(ctx, uid); len(list) != 0 {
+		t.Fatalf("identities = %+v", list)
+	}
+	if err := social.Unlink(ctx, uid, "other"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Fatalf("unlink missing identity: got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+var (
+	// ErrTokenRevoked Token 已退出登录或已被"退出所有设备"作废
+	ErrTokenRevoked = errors.New("Token 已失效，请重新登录")
+	// ErrRefreshReused Refresh Token 被重放，所属会话已整体吊销
+	ErrRefreshReused = errors.New("Refresh Token 已被使用，请重新登录")
+)
+
+// accessTTL / refreshTTL 读取配置中的有效期（小时），未配置时使用默认值
+func (s *UserService) accessTTL() time.Duration {
+	if s.cfg.JWT.Expire <= 0 {
+		return time.Hour
+	}
+	return time.Duration(s.cfg.JWT.Expire) * time.Hour
+}
+
+func (s *UserService) refreshTTL() time.Duration {
+	if s.cfg.JWT.RefreshExpire <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(s.cfg.JWT.RefreshExpire) * time.Hour
+}
+
+// KeyRetention 旧签名密钥的保留期：本服务签发的 Token 中最长的有效期
+// 与签发时使用同一组带默认值的有效期，保证轮换前签发的 Token 在过期前都能通过校验
+func KeyRetention(cfg *config.Config) time.Duration {
+	s, o := &UserService{cfg: cfg}, &OIDCService{cfg: cfg}
+	return max(s.accessTTL(), s.refreshTTL(), s.verifyTokenTTL(), s.resetTokenTTL(), s.mfaPendingTTL(),
+		s.impersonationTTL(), o.idTokenTTL(), o.clientTokenTTL())
+}
+
+// issueTokenPair 签发 Token 对；sessionID 为空时以 client 信息创建新会话，否则沿用已有会话（刷新）
+// client.OAuthClientID 非空时签发 OIDC 客户端 Token (oauth_access / oauth_refresh)
+func (s *UserService) issueTokenPair(ctx context.Context, userID int, sessionID string, client ClientInfo) (*utils.TokenPair, error) {
+	gen, err := s.tokenRepo.GetGeneration(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	newSession := sessionID == ""
+	if newSession {
+		sessionID = utils.NewTokenID()
+	}
+
+	// OIDC 客户端的 Token 使用单独的类型，不能调用用户 API
+	var pair *utils.TokenPair
+	if client.OAuthClientID != "" {
+		pair, err = s.jwt.GenerateOAuthTokenPair(userID, gen, sessionID, client.OAuthClientID, client.OAuthScope, s.accessTTL(), s.refreshTTL())
+	} else {
+		pair, err = s.jwt.GenerateTokenPair(userID, gen, sessionID, s.accessTTL(), s.refreshTTL())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if newSession {
+		now := time.Now()
+		sess := &model.Session{
+			ID:        sessionID,
+			UserID:    userID,
+			Device:    client.deviceName(),
+			UserAgent: client.UserAgent,
+			IP:        client.IP,
+			ClientID:  client.OAuthClientID,
+			Scope:     client.OAuthScope,
+			CreatedAt: now,
+			LastSeen:  now,
+		}
+		if err := s.tokenRepo.CreateSession(ctx, sess, pair.RefreshID, s.refreshTTL()); err != nil {
+			return nil, err
+		}
+	}
+	return pair, nil
+}
+
+// checkRevoked 校验 Token 是否已被吊销（单个吊销 / 退出所有设备 / 会话被移除），同时刷新会话最后活跃时间
+func (s *UserService) checkRevoked(ctx context.Context, c *utils.Claims) error {
+	// 1. 单个 Token 是否已退出登录
+	revoked, err := s.tokenRepo.IsRevoked(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	// 2. 是否已被"退出所有设备"作废
+	current, err := s.tokenRepo.GetGeneration(ctx, c.UserID())
+	if err != nil {
+		return err
+	}
+	if c.Generation < current {
+		return ErrTokenRevoked
+	}
+
+	// 3. 所属会话是否仍然有效（被用户在设备列表中移除后立即失效）
+	if c.SessionID != "" {
+		ok, err := s.tokenRepo.TouchSession(ctx, c.SessionID, sessionTouchInterval)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+// ValidateToken 校验 Access Token 签名、iss/aud、有效期及吊销状态，返回 Claims
+// 也接受个人 API Key（uk_ 前缀），返回 typ 为 api_key 的 Claims
+func (s *UserService) ValidateToken(ctx context.Context, tokenString string) (*utils.Claims, error) {
+	if utils.IsAPIKey(tokenString) {
+		return s.validateAPIKey(ctx, tokenString)
+	}
+	c, err := s.jwt.Parse(tokenString, utils.TokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRevoked(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// RefreshToken 使用 Refresh Token 换取新的 Token 对
+// Refresh Token 一次性有效：旧 Token 被重放时吊销整个会话；OIDC 客户端的 Refresh Token 只能在 /oauth/token 使用
+func (s *UserService) RefreshToken(ctx context.Context, refreshToken string) (*utils.TokenPair, error) {
+	c, err := s.jwt.Parse(refreshToken, utils.TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	return s.rotateRefresh(ctx, c, ClientInfo{})
+}
+
+// rotateRefresh 在 Refresh Token 所属会话内签发新的 Token 对并作废旧 Refresh Token
+func (s *UserService) rotateRefresh(ctx context.Context, c *utils.Claims, client ClientInfo) (*utils.TokenPair, error) {
+	if c.SessionID == "" {
+		return nil, utils.ErrInvalidToken
+	}
+	if err := s.checkRevoked(ctx, c); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.issueTokenPair(ctx, c.UserID(), c.SessionID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// 原子轮换：只有会话内当前的 Refresh Token 才能换新
+	err = s.tokenRepo.RotateSession(ctx, c.UserID(), c.SessionID, c.ID, pair.RefreshID, s.refreshTTL())
+	switch {
+	case errors.Is(err, repository.ErrRefreshReused):
+		return nil, ErrRefreshReused
+	case errors.Is(err, repository.ErrSessionNotFound):
+		return nil, ErrTokenRevoked
+	case err != nil:
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Logout 退出登录：吊销当前 Access Token 并移除其所属会话
+func (s *UserService) Logout(ctx context.Context, c *utils.Claims) error {
+	if c.SessionID != "" {
+		if err := s.tokenRepo.DeleteSession(ctx, c.UserID(), c.SessionID); err != nil {
+			return err
+		}
+	}
+	// 过期时间为 Token 剩余有效期
+	return s.tokenRepo.Revoke(ctx, c.ID, time.Until(c.ExpiresAt.Time))
+}
+
+// LogoutAll 退出所有设备：自增用户 Token 代数，此前签发的 Token 全部失效，并清空会话列表
+func (s *UserService) LogoutAll(ctx context.Context, userID int) error {
+	if _, err := s.tokenRepo.IncrGeneration(ctx, userID); err != nil {
+		return err
+	}
+	return s.tokenRepo.DeleteAllSessions(ctx, userID)
+}
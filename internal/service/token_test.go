@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/pkg/utils"
+)
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	pair := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	claims, err := env.svc.ValidateToken(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatalf("validate before logout: %v", err)
+	}
+	if err := env.svc.Logout(ctx, claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("validate after logout: got %v, want ErrTokenRevoked", err)
+	}
+
+	// 吊销记录的过期时间为 Token 剩余有效期
+	ttl := env.mr.TTL("token:revoked:" + claims.ID)
+	if ttl <= 0 || ttl > env.svc.accessTTL() {
+		t.Fatalf("revocation ttl = %v, want (0, %v]", ttl, env.svc.accessTTL())
+	}
+}
+
+func TestLogoutAllRevokesEveryDevice(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	laptop := env.login(t, "alice@example.com", "Correct-Horse-9")
+	phone := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	if err := env.svc.LogoutAll(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"laptop": laptop.AccessToken, "phone": phone.AccessToken} {
+		if _, err := env.svc.ValidateToken(ctx, token); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("%s: got %v, want ErrTokenRevoked", name, err)
+		}
+	}
+
+	// 之后重新登录签发的 Token 不受影响
+	fresh := env.login(t, "alice@example.com", "Correct-Horse-9")
+	if _, err := env.svc.ValidateToken(ctx, fresh.AccessToken); err != nil {
+		t.Fatalf("token issued after logout-all: %v", err)
+	}
+}
+
+func TestValidateTokenRejectsOtherTypes(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	pair := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	if _, err := env.svc.ValidateToken(context.Background(), pair.RefreshToken); err == nil {
+		t.Fatal("refresh token accepted as access token")
+	}
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	first := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	second, err := env.svc.RefreshToken(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatal("refresh returned the same tokens")
+	}
+
+	// 新旧 Token 属于同一会话
+	oldClaims, _ := env.jwt.Parse(first.AccessToken, utils.TokenTypeAccess)
+	newClaims, err := env.svc.ValidateToken(ctx, second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newClaims.SessionID != oldClaims.SessionID {
+		t.Fatalf("session changed on refresh: %s -> %s", oldClaims.SessionID, newClaims.SessionID)
+	}
+
+	if _, err := env.svc.RefreshToken(ctx, second.RefreshToken); err != nil {
+		t.Fatalf("second rotation: %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	stolen := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	legit, err := env.svc.RefreshToken(ctx, stolen.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重放已轮换的 Refresh Token：整个会话被吊销
+	if _, err := env.svc.RefreshToken(ctx, stolen.RefreshToken); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("replay: got %v, want ErrRefreshReused", err)
+	}
+	if _, err := env.svc.RefreshToken(ctx, legit.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("refresh after reuse: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := env.svc.ValidateToken(ctx, legit.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("access after reuse: got %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestRefreshTokenAfterLogoutAll(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+	pair := env.login(t, "alice@example.com", "Correct-Horse-9")
+
+	if err := env.svc.LogoutAll(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.RefreshToken(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("got %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestKeyRetentionCoversLongestToken(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  config.Config
+		want time.Duration
+	}{
+		// 未配置时与签发使用的默认有效期一致
+		{"defaults", config.Config{}, 7 * 24 * time.Hour},
+		{"refresh", config.Config{JWT: config.JWTConfig{Expire: 1, RefreshExpire: 720}}, 720 * time.Hour},
+		{"email verify", config.Config{EmailVerify: config.EmailVerifyConfig{TokenTTL: 240}}, 240 * time.Hour},
+		{"password reset", config.Config{PasswordReset: config.PasswordResetConfig{TokenTTL: 14 * 24 * 60}}, 14 * 24 * time.Hour},
+		{"oidc client token", config.Config{OIDC: config.OIDCConfig{ClientTokenTTL: 30 * 24 * 60}}, 30 * 24 * time.Hour},
+		{"impersonation", config.Config{Impersonation: config.ImpersonationConfig{TokenTTL: 10 * 24 * 60}}, 10 * 24 * time.Hour},
+	}
+	for _, tc := range cases {
+		if got := KeyRetention(&tc.cfg); got != tc.want {
+			t.Errorf("%s: KeyRetention = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
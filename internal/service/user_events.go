@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// maxWatchUsers 单个订阅可指定的用户 ID 数量上限
+const maxWatchUsers = 1000
+
+var (
+	// ErrSlowConsumer 订阅者缓冲区已满，连接被断开；客户端应从最后收到的事件 ID 重新订阅
+	ErrSlowConsumer      = errors.New("事件消费过慢，请从最后收到的事件重新订阅")
+	ErrEventIDInvalid    = errors.New("无效的事件 ID")
+	ErrWatchTooManyUsers = fmt.Errorf("单个订阅最多指定 %d 个用户", maxWatchUsers)
+	// ErrEventsExpired 续传位置早于事件流中保留的最早事件，中间的事件可能已被裁剪
+	ErrEventsExpired = errors.New("续传位置已过期，请全量同步后重新订阅")
+	// ErrEventHubClosed 服务正在关闭，客户端应重连到其他节点
+	ErrEventHubClosed = errors.New("服务正在关闭，请重新订阅")
+)
+
+// publishUserEvent 发布用户变更事件；失败只记录日志，不影响主流程
+func (s *UserService) publishUserEvent(ctx context.Context, userID int, typ string) {
+	e := &model.UserEvent{UserID: userID, Type: typ, OccurredAt: time.Now()}
+	if err := s.eventRepo.Publish(context.WithoutCancel(ctx), e); err != nil {
+		logger.Log.Warn("用户变更事件发布失败", zap.Int("user_id", userID), zap.String("type", typ), zap.Error(err))
+	}
+}
+
+// UserEventHub 从事件流读取用户变更事件并分发给本节点的所有订阅者
+// 每个节点只有一个读取协程，订阅者通过带缓冲的 channel 接收；缓冲写满的订阅者被断开，由客户端续传
+type UserEventHub struct {
+	repo   repository.UserEventRepository
+	buffer int
+
+	mu   sync.Mutex
+	subs map[*eventSub]struct{}
+	done chan struct{}
+}
+
+type eventSub struct {
+	ids      map[int]bool // nil 表示订阅全部用户
+	ch       chan model.UserEvent
+	overflow chan struct{}
+}
+
+func NewUserEventHub(repo repository.UserEventRepository, cfg config.UserEventsConfig) *UserEventHub {
+	buffer := cfg.Buffer
+	if buffer <= 0 {
+		buffer = 256
+	}
+	return &UserEventHub{
+		repo:   repo,
+		buffer: buffer,
+		subs:   make(map[*eventSub]struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Run 持续读取事件流并分发，ctx 取消后退出并结束所有订阅
+func (h *UserEventHub) Run(ctx context.Context) {
+	// 阻塞读取不一定能被 ctx 立即打断，订阅者不等待读取协程退出
+	go func() {
+		<-ctx.Done()
+		close(h.done)
+	}()
+
+	lastID := ""
+	for ctx.Err() == nil {
+		if lastID == "" {
+			id, err := h.repo.LastID(ctx)
+			if err != nil {
+				logger.Log.Warn("读取用户事件流失败", zap.Error(err))
+				sleepCtx(ctx, time.Second)
+				continue
+			}
+			lastID = id
+			if lastID == "" {
+				lastID = "0-0"
+			}
+		}
+
+		events, err := h.repo.Read(ctx, lastID, 100, 5*time.Second)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Log.Warn("读取用户事件流失败", zap.Error(err))
+				sleepCtx(ctx, time.Second)
+			}
+			continue
+		}
+		for _, e := range events {
+			h.dispatch(e)
+			lastID = e.ID
+		}
+	}
+}
+
+// dispatch 非阻塞投递；订阅者缓冲已满时标记溢出并移除，不拖慢其他订阅者
+func (h *UserEventHub) dispatch(e model.UserEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if sub.ids != nil && !sub.ids[e.UserID] {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			close(sub.overflow)
+			delete(h.subs, sub)
+		}
+	}
+}
+
+func (h *UserEventHub) subscribe(ids []int) *eventSub {
+	sub := &eventSub{
+		ch:       make(chan model.UserEvent, h.buffer),
+		overflow: make(chan struct{}),
+	}
+	if len(ids) > 0 {
+		sub.ids = make(map[int]bool, len(ids))
+		for _, id := range ids {
+			sub.ids[id] = true
+		}
+	}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *UserEventHub) unsubscribe(sub *eventSub) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+}
+
+// Watch 订阅指定用户（ids 为空表示全部用户）的变更事件，直到 ctx 取消或发送失败
+// lastID 非空时先补发该事件之后的历史事件，再切换到实时事件；两者重叠的部分按事件 ID 去重
+func (h *UserEventHub) Watch(ctx context.Context, ids []int, lastID string, send func(model.UserEvent) error) error {
+	if len(ids) > maxWatchUsers {
+		return ErrWatchTooManyUsers
+	}
+	if lastID != "" && !repository.ValidEventID(lastID) {
+		return ErrEventIDInvalid
+	}
+
+	// 先注册再补发，补发期间产生的实时事件缓存在 channel 中
+	sub := h.subscribe(ids)
+	defer h.unsubscribe(sub)
+
+	sent := lastID
+	if lastID != "" {
+		first, err := h.repo.FirstID(ctx)
+		if err != nil {
+			return err
+		}
+		if first != "" && repository.CompareEventID(lastID, first) < 0 {
+			return ErrEventsExpired
+		}
+		for {
+			events, err := h.repo.Range(ctx, sent, 100)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				if sub.ids == nil || sub.ids[e.UserID] {
+					if err := send(e); err != nil {
+						return err
+					}
+				}
+				sent = e.ID
+			}
+			if len(events) < 100 {
+				break
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.done:
+			return ErrEventHubClosed
+		case <-sub.overflow:
+			return ErrSlowConsumer
+		case e := <-sub.ch:
+			if sent != "" && repository.CompareEventID(e.ID, sent) <= 0 {
+				continue
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+			sent = e.ID
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
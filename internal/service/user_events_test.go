@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"github.com/netkey/golang-user-mysql-redis/internal/model"
+	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+)
+
+// readingRepo 在 Hub 第一次读取事件流时通知测试，此后发布的事件一定能被实时分发
+type readingRepo struct {
+	repository.UserEventRepository
+	once    sync.Once
+	reading chan struct{}
+}
+
+func (r *readingRepo) Read(ctx context.Context, afterID string, count int64, block time.Duration) ([]model.UserEvent, error) {
+	r.once.Do(func() { close(r.reading) })
+	return r.UserEventRepository.Read(ctx, afterID, count, block)
+}
+
+type eventsEnv struct {
+	repo repository.UserEventRepository
+	hub  *UserEventHub
+	stop context.CancelFunc
+}
+
+func newEventsEnv(t *testing.T, buffer int) *eventsEnv {
+	t.Helper()
+	env := newTestEnv(t)
+	repo := &readingRepo{UserEventRepository: repository.NewUserEventRepository(env.rdb, 1000), reading: make(chan struct{})}
+	hub := NewUserEventHub(repo, config.UserEventsConfig{Buffer: buffer})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.Run(ctx)
+	select {
+	case <-repo.reading:
+	case <-time.After(2 * time.Second):
+		t.Fatal("event hub did not start reading")
+	}
+	return &eventsEnv{repo: repo, hub: hub, stop: cancel}
+}
+
+func (e *eventsEnv) publish(t *testing.T, userID int) string {
+	t.Helper()
+	ev := &model.UserEvent{UserID: userID, Type: model.UserEventUpdated, OccurredAt: time.Now()}
+	if err := e.repo.Publish(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	return ev.ID
+}
+
+// watch 在后台订阅，收到的事件写入返回的 channel，Watch 结束时写入 errc
+func (e *eventsEnv) watch(ctx context.Context, ids []int, lastID string, send func(model.UserEvent) error) (<-chan model.UserEvent, <-chan error) {
+	events := make(chan model.UserEvent, 16)
+	errc := make(chan error, 1)
+	if send == nil {
+		send = func(ev model.UserEvent) error { events <- ev; return nil }
+	}
+	go func() { errc <- e.hub.Watch(ctx, ids, lastID, send) }()
+	return events, errc
+}
+
+func expectEvent(t *testing.T, events <-chan model.UserEvent, id string) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		if ev.ID != id {
+			t.Fatalf("got event %s (user %d), want %s", ev.ID, ev.UserID, id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("event %s not received", id)
+	}
+}
+
+func TestWatchResumesFromLastEvent(t *testing.T) {
+	env := newEventsEnv(t, 16)
+	first := env.publish(t, 1)
+	env.publish(t, 2)
+	missed := env.publish(t, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errc := env.watch(ctx, []int{1}, first, nil)
+
+	// 先补发 first 之后的历史事件，再接收实时事件；其他用户的事件不推送，补发与实时重叠部分不重复
+	expectEvent(t, events, missed)
+	env.publish(t, 2)
+	live := env.publish(t, 1)
+	expectEvent(t, events, live)
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("Watch after cancel: %v", err)
+	}
+}
+
+func TestWatchRejectsInvalidResume(t *testing.T) {
+	env := newEventsEnv(t, 16)
+	ctx := context.Background()
+	old := env.publish(t, 1)
+	env.publish(t, 1)
+	env.publish(t, 1)
+
+	send := func(model.UserEvent) error { return nil }
+	if err := env.hub.Watch(ctx, nil, "not-an-id", send); !errors.Is(err, ErrEventIDInvalid) {
+		t.Fatalf("invalid id: got %v", err)
+	}
+	if err := env.hub.Watch(ctx, make([]int, maxWatchUsers+1), "", send); !errors.Is(err, ErrWatchTooManyUsers) {
+		t.Fatalf("too many users: got %v", err)
+	}
+
+	// 续传位置之前的事件已被裁剪
+	trimmed := newTestEnv(t)
+	repo := repository.NewUserEventRepository(trimmed.rdb, 1000)
+	for i := 0; i < 3; i++ {
+		_ = repo.Publish(ctx, &model.UserEvent{UserID: 1, Type: model.UserEventUpdated, OccurredAt: time.Now()})
+	}
+	if err := trimmed.rdb.XTrimMaxLen(ctx, "user:events", 1).Err(); err != nil {
+		t.Fatal(err)
+	}
+	hub := NewUserEventHub(repo, config.UserEventsConfig{})
+	if err := hub.Watch(ctx, nil, old, send); !errors.Is(err, ErrEventsExpired) {
+		t.Fatalf("trimmed history: got %v", err)
+	}
+}
+
+func TestWatchDisconnectsSlowConsumer(t *testing.T) {
+	env := newEventsEnv(t, 1)
+	release := make(chan struct{})
+
+	// 订阅者阻塞在第一次发送上，缓冲写满后被断开
+	_, errc := env.watch(context.Background(), nil, "", func(model.UserEvent) error {
+		<-release
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		env.publish(t, 1)
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrSlowConsumer) {
+			t.Fatalf("got %v, want ErrSlowConsumer", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow consumer not disconnected")
+	}
+}
+
+func TestWatchEndsWhenHubStops(t *testing.T) {
+	env := newEventsEnv(t, 16)
+	_, errc := env.watch(context.Background(), nil, "", nil)
+	env.stop()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrEventHubClosed) {
+			t.Fatalf("got %v, want ErrEventHubClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch did not end after hub stopped")
+	}
+}
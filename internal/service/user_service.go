@@ -4,23 +4,94 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/netkey/golang-user-mysql-redis/internal/config"
 	"github.com/netkey/golang-user-mysql-redis/internal/model"
 	"github.com/netkey/golang-user-mysql-redis/internal/repository"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"github.com/netkey/golang-user-mysql-redis/pkg/mailer"
 	"github.com/netkey/golang-user-mysql-redis/pkg/utils" // 确保有 JWT 工具类
-	"golang.org/x/crypto/bcrypt"
+	"go.uber.org/zap"
 	"golang.org/x/sync/singleflight"
 )
 
+var (
+	// ErrInvalidCredentials 登录失败的统一提示，不区分账号不存在与密码错误
+	ErrInvalidCredentials = errors.New("用户不存在或密码错误")
+	ErrEmailRegistered    = errors.New("该邮箱已被注册")
+	ErrAddSelf            = errors.New("不能添加自己")
+	ErrTooManyIDs         = fmt.Errorf("单次最多查询 %d 个用户", maxBatchGetUsers)
+)
+
+// maxBatchGetUsers 批量查询用户的 ID 数量上限
+const maxBatchGetUsers = 100
+
+// unusablePassword 不可用于登录的密码占位（不是合法哈希），用于仅通过第三方账号注册的用户
+const unusablePassword = "!"
+
+// Repositories UserService 依赖的存储层
+type Repositories struct {
+	User         repository.UserRepository
+	Token        repository.TokenRepository
+	OneTime      repository.OneTimeTokenRepository
+	MFA          repository.MFARepository
+	LoginAttempt repository.LoginAttemptRepository
+	RBAC         repository.RBACRepository
+	APIKey       repository.APIKeyRepository
+	MagicLink    repository.MagicLinkRepository
+	Audit        repository.AuditRepository
+	Introspect   repository.IntrospectionRepository
+	UserEvent    repository.UserEventRepository
+}
+
 type UserService struct {
-	repo repository.UserRepository
-	sf   singleflight.Group
-	cfg  *config.Config
+	repo        repository.UserRepository
+	tokenRepo   repository.TokenRepository
+	otRepo      repository.OneTimeTokenRepository
+	mfaRepo     repository.MFARepository
+	attemptRepo repository.LoginAttemptRepository
+	rbacRepo    repository.RBACRepository
+	apiKeyRepo  repository.APIKeyRepository
+	magicRepo   repository.MagicLinkRepository
+	auditRepo   repository.AuditRepository
+	introRepo   repository.IntrospectionRepository
+	eventRepo   repository.UserEventRepository
+	jwt         *utils.JWTManager
+	hasher      *utils.PasswordHasher
+	policy      *utils.PasswordPolicy
+	dummyHash   string // 账号不存在时也执行一次哈希校验，避免通过响应耗时判断账号是否存在
+	mailer      mailer.Sender
+	sf          singleflight.Group
+	cfg         *config.Config
 }
 
-func NewUserService(repo repository.UserRepository, cfg *config.Config) *UserService {
-	return &UserService{repo: repo, cfg: cfg}
+func NewUserService(repos Repositories, jwtMgr *utils.JWTManager, hasher *utils.PasswordHasher, policy *utils.PasswordPolicy, mail mailer.Sender, cfg *config.Config) *UserService {
+	dummyHash, _ := hasher.Hash("dummy-password")
+	return &UserService{
+		repo:        repos.User,
+		tokenRepo:   repos.Token,
+		otRepo:      repos.OneTime,
+		mfaRepo:     repos.MFA,
+		attemptRepo: repos.LoginAttempt,
+		rbacRepo:    repos.RBAC,
+		apiKeyRepo:  repos.APIKey,
+		magicRepo:   repos.MagicLink,
+		auditRepo:   repos.Audit,
+		introRepo:   repos.Introspect,
+		eventRepo:   repos.UserEvent,
+		jwt:         jwtMgr,
+		hasher:      hasher,
+		policy:      policy,
+		dummyHash:   dummyHash,
+		mailer:      mail,
+		cfg:         cfg,
+	}
+}
+
+// normalizeEmail 按邮箱计数或限流时统一小写，防止通过大小写变化绕过
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // Register 用户注册
@@ -28,42 +99,122 @@ func (s *UserService) Register(ctx context.Context, name, nickname, email, passw
 	// 1. 检查邮箱是否已占用
 	exists, err := s.repo.GetByEmail(ctx, email)
 	if err == nil && exists != nil {
-		return errors.New("该邮箱已被注册")
+		return ErrEmailRegistered
 	}
 
-	// 2. 密码哈希加密
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	// 2. 密码策略校验 + 哈希加密
+	if err := s.policy.Check(password, email, name, nickname); err != nil {
+		return err
+	}
+	hashedPassword, err := s.hasher.Hash(password)
 	if err != nil {
 		return err
 	}
 
-	// 3. 构建模型
+	// 3. 构建模型（开启邮箱验证时，账号在验证前处于待验证状态）
+	status := model.UserStatusNormal
+	if s.cfg.EmailVerify.Enable {
+		status = model.UserStatusPending
+	}
 	user := &model.User{
 		Name:     name,
 		Nickname: nickname,
 		Email:    email,
-		Password: string(hashedPassword),
-		Status:   1, // 1-正常
+		Password: hashedPassword,
+		Status:   status,
+	}
+
+	if err := s.repo.Create(ctx, user); err != nil {
+		return err
 	}
 
-	return s.repo.Create(ctx, user)
+	// 4. 发送验证邮件；发送失败不影响注册结果，用户可通过重发接口再次获取
+	if status == model.UserStatusPending {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			logger.Log.Warn("验证邮件发送失败", zap.Int("user_id", user.ID), zap.Error(err))
+		}
+	}
+	return nil
 }
 
-// Login 用户登录并返回 Token
-func (s *UserService) Login(ctx context.Context, email, password string) (string, error) {
+// createPasswordlessUser 通过第三方账号或邮件链接自动注册，密码置为不可用，之后可通过找回密码设置
+func (s *UserService) createPasswordlessUser(ctx context.Context, email, name, avatar string, status int) (*model.User, error) {
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	user := &model.User{
+		Name:     name,
+		Nickname: name,
+		Email:    email,
+		Password: unusablePassword,
+		Avatar:   avatar,
+		Status:   status,
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login 用户登录并返回 Access/Refresh Token
+// 开启二次验证的账号只返回 mfa_pending Token，需再调用 CompleteMFALogin
+func (s *UserService) Login(ctx context.Context, email, password string, client ClientInfo) (*LoginResult, error) {
+	// 0. 防暴力破解：邮箱或 IP 处于退避 / 锁定期内直接拒绝
+	if err := s.checkLoginAllowed(ctx, email, client.IP); err != nil {
+		return nil, err
+	}
+
 	// 1. 根据 Email 获取用户（此处由于是登录，不强制走 Singleflight，直接查库）
+	// 仅通过第三方账号注册、从未设置密码的账号同样按密码错误处理
 	user, err := s.repo.GetByEmail(ctx, email)
-	if err != nil || user == nil {
-		return "", errors.New("用户不存在或密码错误")
+	if err != nil || user == nil || user.Password == unusablePassword {
+		_, _, _ = s.hasher.Verify(password, s.dummyHash)
+		s.recordLoginFailure(ctx, email, client.IP)
+		return nil, ErrInvalidCredentials
+	}
+
+	// 2. 验证密码（按存储哈希自身的算法与参数）
+	ok, needsRehash, err := s.hasher.Verify(password, user.Password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.recordLoginFailure(ctx, email, client.IP)
+		return nil, ErrInvalidCredentials
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, user.ID, password)
+	}
+
+	return s.completeLogin(ctx, user, client)
+}
+
+// completeLogin 第一因素（密码或第三方账号）验证通过后的公共流程：状态检查、二次验证、签发 Token 对
+func (s *UserService) completeLogin(ctx context.Context, user *model.User, client ClientInfo) (*LoginResult, error) {
+	// 3. 已停用的账号禁止登录；按配置拦截未验证邮箱的账号
+	if user.Status == model.UserStatusSuspended {
+		return nil, ErrAccountSuspended
+	}
+	if s.cfg.EmailVerify.BlockLogin && user.Status == model.UserStatusPending {
+		return nil, ErrEmailNotVerified
 	}
 
-	// 2. 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("用户不存在或密码错误")
+	// 4. 已开启二次验证：先签发 mfa_pending Token
+	m, err := s.mfaRepo.Get(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil && m.Enabled {
+		return s.beginMFALogin(ctx, user.ID)
 	}
 
-	// 3. 签发 Token
-	return utils.GenerateToken(user.ID, s.cfg.JWT.Secret, s.cfg.JWT.Expire)
+	// 5. 签发 Token 对（开启新的 Token 族）；开启二次验证的账号在 CompleteMFALogin 通过后才清零失败计数
+	pair, err := s.issueTokenPair(ctx, user.ID, "", client)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLoginSuccess(ctx, user.Email)
+	return &LoginResult{TokenPair: pair}, nil
 }
 
 // GetUser 获取用户信息（带缓存 + Singleflight 防击穿）
@@ -93,6 +244,73 @@ func (s *UserService) GetUser(ctx context.Context, id int) (*model.User, error)
 	return v.(*model.User), nil
 }
 
+// BatchGetUsers 批量获取用户信息，按 ids 顺序返回（重复 ID 只返回一次），不存在的 ID 跳过
+// 缓存通过 MGET 一次读取，未命中的用户合并为一条 IN 查询并回写缓存
+func (s *UserService) BatchGetUsers(ctx context.Context, ids []int) ([]model.User, error) {
+	if len(ids) > maxBatchGetUsers {
+		return nil, ErrTooManyIDs
+	}
+	uniq := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			uniq = append(uniq, id)
+		}
+	}
+	if len(uniq) == 0 {
+		return []model.User{}, nil
+	}
+
+	// 1. 批量读缓存；Redis 故障时全部回源
+	found, err := s.repo.GetCacheMulti(ctx, uniq)
+	if err != nil {
+		logger.Log.Warn("批量读取用户缓存失败", zap.Error(err))
+	}
+	if found == nil {
+		found = make(map[int]*model.User, len(uniq))
+	}
+
+	// 2. 未命中的 ID 一次查库并回写缓存
+	var misses []int
+	for _, id := range uniq {
+		if found[id] == nil {
+			misses = append(misses, id)
+		}
+	}
+	if len(misses) > 0 {
+		loaded, err := s.repo.GetByIDs(ctx, misses)
+		if err != nil {
+			return nil, err
+		}
+		for i := range loaded {
+			found[loaded[i].ID] = &loaded[i]
+		}
+		_ = s.repo.SetCacheMulti(ctx, loaded)
+	}
+
+	// 3. 按输入顺序组装结果
+	users := make([]model.User, 0, len(uniq))
+	for _, id := range uniq {
+		if u := found[id]; u != nil {
+			users = append(users, *u)
+		}
+	}
+	return users, nil
+}
+
+// GetUserByEmail 按邮箱获取用户信息（不走缓存）
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	user, err := s.repo.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
 // UpdateMyProfile 用户修改自己的资料
 func (s *UserService) UpdateMyProfile(ctx context.Context, userID int, nickname string, age int, avatar string) error {
 	// 1. 调用仓库层原生 SQL 更新
@@ -102,13 +320,16 @@ func (s *UserService) UpdateMyProfile(ctx context.Context, userID int, nickname
 
 	// 2. 删除缓存（Cache Aside 策略：先更新库，再删缓存）
 	_ = s.repo.DeleteCache(ctx, userID)
+
+	// 3. 通知订阅了该用户的下游服务 (WatchUser)
+	s.publishUserEvent(ctx, userID, model.UserEventUpdated)
 	return nil
 }
 
 // AddFriend 添加好友
 func (s *UserService) AddFriend(ctx context.Context, userID, friendID int) error {
 	if userID == friendID {
-		return errors.New("不能添加自己")
+		return ErrAddSelf
 	}
 	// 执行仓库层的事务添加逻辑
 	return s.repo.AddFriend(ctx, userID, friendID)
@@ -118,15 +339,3 @@ func (s *UserService) AddFriend(ctx context.Context, userID, friendID int) error
 func (s *UserService) ListFriends(ctx context.Context, userID int) ([]model.User, error) {
 	return s.repo.GetFriends(ctx, userID)
 }
-
-// Logout 退出登录
-func (s *UserService) Logout(ctx context.Context, token string) error {
-	// 将 Token 加入 Redis 黑名单，过期时间建议设置为 JWT 剩余有效期
-	// 这里假设你通过 Redis 直接操作或扩展 Repo 接口
-	return nil
-}
-
-// GetJWTSecret 暴露配置中的密钥给 Handler 使用
-func (s *UserService) GetJWTSecret() string {
-	return s.cfg.JWT.Secret
-}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegisterDuplicateEmail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	if err := env.svc.Register(ctx, "alice", "Alice", "alice@example.com", "Correct-Horse-9"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.svc.Register(ctx, "alice2", "Alice", "alice@example.com", "Correct-Horse-9"); !errors.Is(err, ErrEmailRegistered) {
+		t.Fatalf("duplicate email: got %v", err)
+	}
+
+	u, err := env.svc.GetUserByEmail(ctx, " alice@example.com ")
+	if err != nil || u.Name != "alice" {
+		t.Fatalf("GetUserByEmail = %+v, %v", u, err)
+	}
+	if _, err := env.svc.GetUserByEmail(ctx, "bob@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("unknown email: got %v", err)
+	}
+}
+
+func TestUpdateMyProfile(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u := env.createUser(t, "alice@example.com", "Correct-Horse-9")
+
+	// 先读一次写入缓存，更新后应读到新资料
+	if _, err := env.svc.GetUser(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.svc.UpdateMyProfile(ctx, u.ID, "Ally", 31, "https://cdn.example.com/a.png"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := env.svc.GetUser(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Nickname != "Ally" || got.Age != 31 {
+		t.Fatalf("profile after update = %+v", got)
+	}
+
+	// 通知 WatchUser 订阅方
+	if n, _ := env.rdb.XLen(ctx, "user:events").Result(); n != 1 {
+		t.Fatalf("published %d events, want 1", n)
+	}
+
+	if err := env.svc.AddFriend(ctx, u.ID, u.ID); !errors.Is(err, ErrAddSelf) {
+		t.Fatalf("add self: got %v", err)
+	}
+}
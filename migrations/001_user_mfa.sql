@@ -0,0 +1,18 @@
+-- TOTP 二次验证与恢复码
+-- secret 为使用 mfa.secret_key 加密后的密钥；恢复码只保存 SHA-256
+
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id    INT PRIMARY KEY,
+    secret     VARCHAR(255) NOT NULL,
+    enabled    TINYINT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id        INT AUTO_INCREMENT PRIMARY KEY,
+    user_id   INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at   DATETIME NULL,
+    UNIQUE KEY uk_user_code (user_id, code_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
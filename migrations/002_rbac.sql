@@ -0,0 +1,27 @@
+-- 角色与权限
+-- 用户权限缓存在 Redis (rbac:perms:<user_id>)，直接修改这些表后需清除对应缓存或等待 5 分钟
+
+CREATE TABLE IF NOT EXISTS roles (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    name        VARCHAR(32) NOT NULL UNIQUE,
+    description VARCHAR(255) NOT NULL DEFAULT ''
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id    INT NOT NULL,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role_id, permission)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id    INT NOT NULL,
+    role_id    INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, role_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 初始角色
+INSERT IGNORE INTO roles (id, name, description) VALUES (1, 'admin', '管理员'), (2, 'support', '客服');
+INSERT IGNORE INTO role_permissions (role_id, permission) VALUES
+    (1, 'user:read'), (1, 'user:suspend'), (1, 'user:delete'), (1, 'role:assign'), (1, 'login:unlock'),
+    (2, 'user:read'), (2, 'login:unlock');
@@ -0,0 +1,4 @@
+-- 密码哈希改为自描述格式 (PHC)：Argon2id 编码长度约 100 字符，超过原 bcrypt 的 60 字符
+-- 已有的 bcrypt 哈希无需处理，用户下次登录时自动升级
+
+ALTER TABLE users MODIFY password VARCHAR(128) NOT NULL;
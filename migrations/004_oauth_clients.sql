@@ -0,0 +1,17 @@
+-- OIDC Provider 注册的客户端；授权码与会话保存在 Redis，不需要建表
+-- secret_hash 为 client_secret 的 SHA-256，公开客户端为空
+
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    client_id     VARCHAR(64) PRIMARY KEY,
+    secret_hash   CHAR(64) NOT NULL DEFAULT '',
+    name          VARCHAR(128) NOT NULL,
+    redirect_uris TEXT NOT NULL,
+    grant_types   VARCHAR(255) NOT NULL,
+    scopes        VARCHAR(255) NOT NULL,
+    public        TINYINT NOT NULL DEFAULT 0,
+    owner_id      INT NOT NULL,
+    created_at    DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 管理员可注册、管理客户端
+INSERT IGNORE INTO role_permissions (role_id, permission) VALUES (1, 'oauth:client');
@@ -0,0 +1,14 @@
+-- 第三方账号登录：外部身份与本地账号的关联
+-- 每个提供方的身份只能关联一个账号，每个账号在同一提供方只能关联一个身份
+
+CREATE TABLE IF NOT EXISTS linked_identities (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    user_id       INT NOT NULL,
+    provider      VARCHAR(32) NOT NULL,
+    subject       VARCHAR(255) NOT NULL,
+    email         VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    DATETIME NOT NULL,
+    last_login_at DATETIME NULL,
+    UNIQUE KEY uk_provider_subject (provider, subject),
+    UNIQUE KEY uk_user_provider (user_id, provider)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
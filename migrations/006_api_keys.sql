@@ -0,0 +1,16 @@
+-- 个人 API Key：只保存 SHA-256 哈希，明文仅在创建时返回一次
+-- scopes 为 JSON 数组 (read / write 或权限点)
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    user_id      INT NOT NULL,
+    name         VARCHAR(64) NOT NULL,
+    prefix       VARCHAR(16) NOT NULL UNIQUE,
+    key_hash     CHAR(64) NOT NULL,
+    scopes       VARCHAR(512) NOT NULL,
+    rate_limit   INT NOT NULL DEFAULT 0,
+    expires_at   DATETIME NULL,
+    last_used_at DATETIME NULL,
+    created_at   DATETIME NOT NULL,
+    INDEX idx_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,20 @@
+-- 审计日志：管理员模拟登录的签发及模拟期间的每个请求，只追加不修改
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    actor_id   INT NOT NULL,
+    user_id    INT NOT NULL,
+    action     VARCHAR(32) NOT NULL,
+    target     VARCHAR(255) NOT NULL,
+    status     INT NOT NULL DEFAULT 0,
+    detail     VARCHAR(512) NOT NULL DEFAULT '',
+    ip         VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    INDEX idx_actor (actor_id, id),
+    INDEX idx_user (user_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 管理员可模拟登录并查看审计日志，客服可模拟登录
+INSERT IGNORE INTO role_permissions (role_id, permission) VALUES
+    (1, 'user:impersonate'), (1, 'audit:read'), (2, 'user:impersonate');
@@ -0,0 +1,186 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ErrNoCertificate 作为服务端使用但未配置证书
+var ErrNoCertificate = errors.New("未配置 TLS 证书")
+
+// Reloader 持有当前证书与 CA，文件变更后自动重新加载
+// 已建立的连接不受影响，之后的握手使用新证书；加载失败时保留旧证书并记录日志
+type Reloader struct {
+	certFile, keyFile, caFile string
+	watcher                   *fsnotify.Watcher
+
+	mu   sync.RWMutex
+	raw  [][]byte // 上次加载的文件内容，未变化时跳过
+	cert *tls.Certificate
+	pool *x509.CertPool
+}
+
+// NewReloader certFile / keyFile 为本端证书（客户端可不配置），caFile 为校验对端证书的 CA（为空时服务端不校验客户端证书、客户端使用系统 CA）
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// 监听所在目录而不是文件本身：证书通常以改名或切换符号链接的方式替换 (如 Kubernetes Secret)，文件本身的监听会失效
+	dirs := make(map[string]bool)
+	for _, f := range []string{certFile, keyFile, caFile} {
+		if f != "" {
+			dirs[filepath.Dir(f)] = true
+		}
+	}
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	r.watcher = w
+	go r.watch()
+	return r, nil
+}
+
+// watch 目录内任意变更后稍作等待再加载，避免文件尚未写完时读到不完整的内容
+func (r *Reloader) watch() {
+	const debounce = 200 * time.Millisecond
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case _, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			timer.Reset(debounce)
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Log.Warn("证书目录监听出错", zap.Error(err))
+		case <-timer.C:
+			changed, err := r.load()
+			if err != nil {
+				logger.Log.Error("证书重新加载失败，继续使用旧证书", zap.String("cert", r.certFile), zap.Error(err))
+			} else if changed {
+				logger.Log.Info("证书已重新加载", zap.String("cert", r.certFile), zap.String("ca", r.caFile))
+			}
+		}
+	}
+}
+
+func (r *Reloader) load() (bool, error) {
+	raw := make([][]byte, 3)
+	for i, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f == "" {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return false, err
+		}
+		raw[i] = data
+	}
+
+	r.mu.RLock()
+	unchanged := r.raw != nil && bytes.Equal(raw[0], r.raw[0]) && bytes.Equal(raw[1], r.raw[1]) && bytes.Equal(raw[2], r.raw[2])
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		c, err := tls.X509KeyPair(raw[0], raw[1])
+		if err != nil {
+			return false, fmt.Errorf("load certificate %s: %w", r.certFile, err)
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(raw[2]) {
+			return false, fmt.Errorf("no certificate found in %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.raw, r.cert, r.pool = raw, cert, pool
+	r.mu.Unlock()
+	return true, nil
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+// ServerConfig 服务端配置，证书与客户端 CA 均实时生效
+// 配置了 CA 时校验客户端证书 (mTLS)：requireClientCert 为 true 时拒绝不带证书的连接，否则只校验带证书的连接
+func (r *Reloader) ServerConfig(requireClientCert bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			if cert == nil {
+				return nil, ErrNoCertificate
+			}
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+				if requireClientCert {
+					cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig 客户端配置：配置了证书时在服务端要求时出示 (mTLS)，证书实时生效
+// 校验服务端使用创建时的 CA，CA 变更后需重建客户端；serverName 为空时使用连接地址
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	_, pool := r.current()
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		RootCAs:    pool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			if cert == nil {
+				return &tls.Certificate{}, nil // 不出示证书，由服务端决定是否拒绝
+			}
+			return cert, nil
+		},
+	}
+}
+
+// Close 停止监听证书文件
+func (r *Reloader) Close() error {
+	return r.watcher.Close()
+}
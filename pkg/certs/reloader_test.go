@@ -0,0 +1,236 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+// testCA 测试用 CA，签发服务端与客户端证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发 CN 为 cn 的证书，返回 PEM 编码的证书与私钥
+func (ca *testCA) issue(t *testing.T, cn string, serial int64) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile 先写临时文件再改名，与证书轮换工具的替换方式一致
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type certFiles struct{ cert, key, ca string }
+
+func writeCerts(t *testing.T, dir string, ca *testCA, cn string, serial int64) certFiles {
+	t.Helper()
+	f := certFiles{filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")}
+	certPEM, keyPEM := ca.issue(t, cn, serial)
+	writeFile(t, f.key, keyPEM)
+	writeFile(t, f.cert, certPEM)
+	writeFile(t, f.ca, ca.pem)
+	return f
+}
+
+func leafSerial(t *testing.T, r *Reloader) int64 {
+	t.Helper()
+	cert, _ := r.current()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestReloaderPicksUpRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	f := writeCerts(t, dir, ca, "user-service", 10)
+	r, err := NewReloader(f.cert, f.key, f.ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got := leafSerial(t, r); got != 10 {
+		t.Fatalf("serial = %d, want 10", got)
+	}
+
+	writeCerts(t, dir, ca, "user-service", 11)
+	deadline := time.Now().Add(5 * time.Second)
+	for leafSerial(t, r) != 11 {
+		if time.Now().After(deadline) {
+			t.Fatal("rotated certificate not loaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// 写入无效内容时继续使用旧证书
+	writeFile(t, f.cert, []byte("not a certificate"))
+	time.Sleep(500 * time.Millisecond)
+	if got := leafSerial(t, r); got != 11 {
+		t.Fatalf("serial after broken write = %d, want 11", got)
+	}
+}
+
+func TestReloaderRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	f := writeCerts(t, dir, ca, "user-service", 10)
+
+	if _, err := NewReloader(filepath.Join(dir, "missing.crt"), f.key, f.ca); err == nil {
+		t.Error("missing certificate accepted")
+	}
+	bad := filepath.Join(dir, "bad-ca.crt")
+	writeFile(t, bad, []byte("garbage"))
+	if _, err := NewReloader(f.cert, f.key, bad); err == nil {
+		t.Error("invalid CA accepted")
+	}
+}
+
+// handshake 在内存连接上完成一次 TLS 握手，返回服务端看到的客户端证书链
+func handshake(serverCfg, clientCfg *tls.Config) ([][]*x509.Certificate, error) {
+	sc, cc := net.Pipe()
+	defer sc.Close()
+	defer cc.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		client := tls.Client(cc, clientCfg)
+		err := client.Handshake()
+		if err == nil {
+			// TLS 1.3 下客户端证书在服务端读取时才校验，读一次等待服务端结果
+			_, err = client.Read(make([]byte, 1))
+		}
+		errc <- err
+	}()
+	server := tls.Server(sc, serverCfg)
+	if err := server.Handshake(); err != nil {
+		return nil, err
+	}
+	chains := server.ConnectionState().VerifiedChains
+	server.Write([]byte{0})
+	return chains, <-errc
+}
+
+// newTestReloader 在独立目录写入 ca 签发给 cn 的证书并加载；cn 为空时只加载 CA
+func newTestReloader(t *testing.T, ca *testCA, cn string) *Reloader {
+	t.Helper()
+	f := writeCerts(t, t.TempDir(), ca, "placeholder", 20)
+	if cn == "" {
+		f.cert, f.key = "", ""
+	} else {
+		certPEM, keyPEM := ca.issue(t, cn, 21)
+		writeFile(t, f.key, keyPEM)
+		writeFile(t, f.cert, certPEM)
+	}
+	r, err := NewReloader(f.cert, f.key, f.ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTestReloader(t, ca, "user-service")
+	client := newTestReloader(t, ca, "order-service")
+
+	chains, err := handshake(server.ServerConfig(true), client.ClientConfig("user-service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) == 0 || chains[0][0].Subject.CommonName != "order-service" {
+		t.Fatalf("verified chains = %v", chains)
+	}
+
+	// 不带客户端证书：要求证书时拒绝，否则允许但没有已校验的证书
+	anon := newTestReloader(t, ca, "")
+	if _, err := handshake(server.ServerConfig(true), anon.ClientConfig("user-service")); err == nil {
+		t.Fatal("client without certificate accepted")
+	}
+	chains, err = handshake(server.ServerConfig(false), anon.ClientConfig("user-service"))
+	if err != nil || len(chains) != 0 {
+		t.Fatalf("optional client cert: chains %v, err %v", chains, err)
+	}
+
+	// 其他 CA 签发的客户端证书（信任服务端 CA）
+	other := newTestReloader(t, newTestCA(t), "order-service")
+	otherCfg := other.ClientConfig("user-service")
+	otherCfg.RootCAs = anon.ClientConfig("").RootCAs
+	if _, err := handshake(server.ServerConfig(false), otherCfg); err == nil {
+		t.Fatal("certificate from unknown CA accepted")
+	}
+}
@@ -2,63 +2,159 @@ package discovery
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync"
 	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+	"go.uber.org/zap"
 )
 
+// Register 服务节点注册
+// 租约维持节点存活，节点地址只在健康时发布：SetServing(false) 撤下地址但保留租约，恢复后重新发布
 type Register struct {
 	etcdClient *clientv3.Client
-	leaseID    clientv3.LeaseID
 	closeChan  chan struct{}
+
+	mu      sync.Mutex
+	em      endpoints.Manager
+	name    string
+	key     string
+	addr    string
+	ttl     int64
+	leaseID clientv3.LeaseID
+	serving bool
+	stopped bool
 }
 
-func NewRegister(endpoints []string) (*Register, error) {
+// NewRegister tlsCfg 为连接 etcd 的 TLS 配置，nil 表示明文
+func NewRegister(endpoints []string, tlsCfg *tls.Config) (*Register, error) {
 	cli, err := clientv3.New(clientv3.Config{
 		Endpoints:   endpoints,
 		DialTimeout: 5 * time.Second,
+		TLS:         tlsCfg,
 	})
 	return &Register{etcdClient: cli, closeChan: make(chan struct{})}, err
 }
 
-// RegisterService 注册服务并绑定租约（心跳）
+// RegisterService 创建租约并自动续租；节点地址在 SetServing(true) 后才发布
+// 值为 endpoints.Endpoint 的 JSON，与 GetGRPCClient 使用的 etcd 命名解析器一致
 func (r *Register) RegisterService(ctx context.Context, serviceName, addr string, ttl int64) error {
-	// 1. 创建租约
-	lease, err := r.etcdClient.Grant(ctx, ttl)
+	target := fmt.Sprintf("/services/%s", serviceName)
+	em, err := endpoints.NewManager(r.etcdClient, target)
 	if err != nil {
 		return err
 	}
-	r.leaseID = lease.ID
 
-	// 2. 注册服务节点
-	key := fmt.Sprintf("/services/%s/%d", serviceName, r.leaseID)
-	if _, err := r.etcdClient.Put(ctx, key, addr, clientv3.WithLease(r.leaseID)); err != nil {
+	r.mu.Lock()
+	r.em, r.name, r.addr, r.ttl = em, serviceName, addr, ttl
+	r.mu.Unlock()
+
+	kaChan, err := r.grantLease(ctx)
+	if err != nil {
 		return err
 	}
+	go r.keepAlive(kaChan)
+	return nil
+}
 
-	// 3. 自动续租
-	kaChan, err := r.etcdClient.KeepAlive(ctx, r.leaseID)
+// grantLease 创建新租约；节点处于健康状态时用新租约重新发布地址
+func (r *Register) grantLease(ctx context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	lease, err := r.etcdClient.Grant(ctx, r.ttl)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	kaChan, err := r.etcdClient.KeepAlive(context.Background(), lease.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := fmt.Sprintf("/services/%s/%d", r.name, lease.ID)
+	if r.serving && !r.stopped {
+		err := r.em.AddEndpoint(ctx, key, endpoints.Endpoint{Addr: r.addr}, clientv3.WithLease(lease.ID))
+		if err != nil {
+			r.etcdClient.Revoke(context.Background(), lease.ID)
+			return nil, err
+		}
 	}
+	r.leaseID, r.key = lease.ID, key
+	return kaChan, nil
+}
 
-	go func() {
-		for {
-			select {
-			case <-r.closeChan:
+// keepAlive 维持租约；etcd 重启或网络中断导致租约失效时重新申请
+func (r *Register) keepAlive(kaChan <-chan *clientv3.LeaseKeepAliveResponse) {
+	for {
+		select {
+		case <-r.closeChan:
+			return
+		case _, ok := <-kaChan:
+			if ok {
+				continue // 心跳维持
+			}
+			logger.Log.Warn("etcd 租约已失效，重新注册")
+			if kaChan = r.regrant(); kaChan == nil {
 				return
-			case <-kaChan:
-				// 心跳维持
 			}
 		}
-	}()
-	return nil
+	}
+}
+
+// regrant 每秒重试申请租约，直到成功或 Stop
+func (r *Register) regrant() <-chan *clientv3.LeaseKeepAliveResponse {
+	for {
+		select {
+		case <-r.closeChan:
+			return nil
+		case <-time.After(time.Second):
+		}
+		kaChan, err := r.grantLease(context.Background())
+		if err == nil {
+			return kaChan
+		}
+		logger.Log.Warn("etcd 重新注册失败", zap.Error(err))
+	}
+}
+
+// SetServing 按健康状态发布或撤下节点地址
+func (r *Register) SetServing(ctx context.Context, serving bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped || r.serving == serving {
+		return nil
+	}
+	r.serving = serving
+	if r.em == nil || r.leaseID == 0 {
+		return nil // 租约尚未建立，建立后按当前状态发布
+	}
+	if serving {
+		return r.publishLocked(ctx)
+	}
+	return r.em.DeleteEndpoint(ctx, r.key)
 }
 
+func (r *Register) publishLocked(ctx context.Context) error {
+	return r.em.AddEndpoint(ctx, r.key, endpoints.Endpoint{Addr: r.addr}, clientv3.WithLease(r.leaseID))
+}
+
+// Stop 撤下节点并释放租约，之后的 SetServing 调用被忽略
 func (r *Register) Stop() {
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return
+	}
+	r.stopped = true
+	leaseID := r.leaseID
+	r.mu.Unlock()
+
 	close(r.closeChan)
-	if r.leaseID != 0 {
-		r.etcdClient.Revoke(context.Background(), r.leaseID)
+	if leaseID != 0 {
+		r.etcdClient.Revoke(context.Background(), leaseID)
 	}
 	r.etcdClient.Close()
 }
@@ -1,16 +1,23 @@
 package discovery
 
 import (
+	"crypto/tls"
 	"fmt"
 	"go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/naming/resolver"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 // GetGRPCClient 通过服务名获取一个具备自动发现能力的连接
-func GetGRPCClient(etcdEndpoints []string, serviceName string) (*grpc.ClientConn, error) {
+// etcdTLS 用于连接 etcd，tlsCfg 用于连接目标服务 (可由 certs.Reloader.ClientConfig 生成，含 mTLS 客户端证书)；
+// 为 nil 时使用明文，仅限本地开发。服务地址来自 etcd，tlsCfg 需设置 ServerName 为目标服务证书中的名称
+// opts 用于追加调用方选项，如 grpc.WithPerRPCCredentials 携带服务间 Token
+func GetGRPCClient(etcdEndpoints []string, etcdTLS *tls.Config, serviceName string, tlsCfg *tls.Config, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	cli, err := clientv3.New(clientv3.Config{
 		Endpoints: etcdEndpoints,
+		TLS:       etcdTLS,
 	})
 	if err != nil {
 		return nil, err
@@ -25,10 +32,15 @@ func GetGRPCClient(etcdEndpoints []string, serviceName string) (*grpc.ClientConn
 	// 目标地址格式：etcd:///services/user-service
 	target := fmt.Sprintf("etcd:///services/%s", serviceName)
 
-	return grpc.Dial(
-		target,
+	creds := insecure.NewCredentials()
+	if tlsCfg != nil {
+		creds = credentials.NewTLS(tlsCfg)
+	}
+	dialOpts := append([]grpc.DialOption{
 		grpc.WithResolvers(etcdResolver),
-		grpc.WithInsecure(),                                                    // 演示使用，生产应使用 TLS
+		grpc.WithTransportCredentials(creds),
 		grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy":"round_robin"}`), // 轮询负载均衡
-	)
+	}, opts...)
+
+	return grpc.Dial(target, dialOpts...)
 }
@@ -0,0 +1,138 @@
+package healthcheck
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Check 单项依赖检查，如 db.PingContext
+type Check func(ctx context.Context) error
+
+// Checker 定期检查依赖，将结果同步到 grpc.health.v1 服务
+// 所有检查通过为 SERVING，任一失败为 NOT_SERVING；状态变化时回调 OnChange（如发布 / 撤下 etcd 注册）
+type Checker struct {
+	srv      *health.Server
+	services []string
+	checks   map[string]Check
+	interval time.Duration
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	serving  bool
+	known    bool // 是否已完成首次检查
+	shutdown bool
+	onChange []func(serving bool)
+}
+
+// New services 为需要同步状态的 gRPC 服务名，"" 表示整个服务器
+func New(srv *health.Server, interval time.Duration, checks map[string]Check, services ...string) *Checker {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	c := &Checker{
+		srv:      srv,
+		services: append([]string{""}, services...),
+		checks:   checks,
+		interval: interval,
+		timeout:  min(interval, 3*time.Second),
+	}
+	// 首次检查完成前不对外提供服务
+	c.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// OnChange 注册状态变化回调；回调在检查协程中同步执行
+func (c *Checker) OnChange(fn func(serving bool)) {
+	c.mu.Lock()
+	c.onChange = append(c.onChange, fn)
+	c.mu.Unlock()
+}
+
+// Run 立即检查一次，之后按间隔检查，直到 ctx 取消
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.checkOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) checkOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	serving := true
+	for name, check := range c.checks {
+		if err := check(ctx); err != nil {
+			serving = false
+			logger.Log.Warn("依赖检查失败", zap.String("dependency", name), zap.Error(err))
+		}
+	}
+	c.update(serving)
+}
+
+func (c *Checker) update(serving bool) {
+	c.mu.Lock()
+	if c.shutdown || (c.known && c.serving == serving) {
+		c.mu.Unlock()
+		return
+	}
+	c.known, c.serving = true, serving
+	callbacks := c.onChange
+	c.mu.Unlock()
+
+	if serving {
+		c.setStatus(healthpb.HealthCheckResponse_SERVING)
+		logger.Log.Info("服务状态: SERVING")
+	} else {
+		c.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+		logger.Log.Warn("服务状态: NOT_SERVING")
+	}
+	for _, fn := range callbacks {
+		fn(serving)
+	}
+}
+
+func (c *Checker) setStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, svc := range c.services {
+		c.srv.SetServingStatus(svc, status)
+	}
+}
+
+// Serving 最近一次检查的结果
+func (c *Checker) Serving() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.serving && !c.shutdown
+}
+
+// Shutdown 停机前调用：所有服务置为 NOT_SERVING 且不再随检查结果恢复，并回调 OnChange(false)
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	if c.shutdown {
+		c.mu.Unlock()
+		return
+	}
+	c.shutdown = true
+	wasServing := c.serving
+	callbacks := c.onChange
+	c.mu.Unlock()
+
+	c.srv.Shutdown()
+	if wasServing {
+		for _, fn := range callbacks {
+			fn(false)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+func status(t *testing.T, srv *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := srv.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Status
+}
+
+func TestCheckerFollowsDependencies(t *testing.T) {
+	srv := health.NewServer()
+	dbErr := errors.New("connection refused")
+	c := New(srv, time.Second, map[string]Check{
+		"db":    func(context.Context) error { return dbErr },
+		"redis": func(context.Context) error { return nil },
+	}, "user.UserService")
+
+	var changes []bool
+	c.OnChange(func(serving bool) { changes = append(changes, serving) })
+
+	// 首次检查前不对外提供服务
+	if got := status(t, srv, "user.UserService"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("initial status = %s", got)
+	}
+
+	c.checkOnce(context.Background())
+	c.checkOnce(context.Background())
+	dbErr = nil
+	c.checkOnce(context.Background())
+	c.checkOnce(context.Background())
+
+	for _, svc := range []string{"", "user.UserService"} {
+		if got := status(t, srv, svc); got != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("status(%q) = %s, want SERVING", svc, got)
+		}
+	}
+	// 只在状态变化时回调：首次检查的结果也算一次变化
+	if len(changes) != 2 || changes[0] || !changes[1] || !c.Serving() {
+		t.Fatalf("changes = %v, serving = %v", changes, c.Serving())
+	}
+}
+
+func TestCheckerShutdown(t *testing.T) {
+	srv := health.NewServer()
+	c := New(srv, time.Second, map[string]Check{"db": func(context.Context) error { return nil }}, "user.UserService")
+	var changes []bool
+	c.OnChange(func(serving bool) { changes = append(changes, serving) })
+
+	c.checkOnce(context.Background())
+	c.Shutdown()
+	c.Shutdown()
+	// 停机后检查通过也不再恢复
+	c.checkOnce(context.Background())
+
+	if got := status(t, srv, "user.UserService"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status after shutdown = %s", got)
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] || c.Serving() {
+		t.Fatalf("changes = %v, serving = %v", changes, c.Serving())
+	}
+}
+
+func TestCheckerTimesOutSlowChecks(t *testing.T) {
+	srv := health.NewServer()
+	c := New(srv, 50*time.Millisecond, map[string]Check{
+		"db": func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	start := time.Now()
+	c.checkOnce(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("check took %v, want bounded by interval", elapsed)
+	}
+	if c.Serving() {
+		t.Fatal("serving after check timed out")
+	}
+}
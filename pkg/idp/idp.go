@@ -0,0 +1,63 @@
+// Package idp 外部身份提供方（第三方账号登录）
+package idp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+)
+
+// ErrExchangeFailed 授权码换取身份失败（code 无效、过期或 ID Token 校验失败）
+var ErrExchangeFailed = errors.New("idp: exchange failed")
+
+// ErrStubNotAllowed 配置了桩提供方但未开启 social_login.allow_stub
+var ErrStubNotAllowed = errors.New("idp: stub provider requires social_login.allow_stub (development only)")
+
+// Identity 外部提供方返回的用户身份
+type Identity struct {
+	Subject       string // 提供方内的唯一用户标识
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// Provider 外部身份提供方，业务层只依赖该接口，便于替换为 OIDC / OAuth2 / 本地桩实现
+type Provider interface {
+	// AuthURL 构造跳转到提供方的授权地址；verifier 为 PKCE code_verifier，nonce 仅 OIDC 使用
+	AuthURL(state, nonce, verifier string) string
+	// Exchange 用回调中的 code 换取用户身份
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// New 按配置中的 type 创建提供方
+func New(cfg config.SocialProviderConfig) (Provider, error) {
+	switch cfg.Type {
+	case "oidc":
+		return NewOIDCProvider(cfg)
+	case "oauth2":
+		return NewOAuth2Provider(cfg)
+	case "stub":
+		return NewStubProvider(cfg.RedirectURL, cfg.Stub), nil
+	default:
+		return nil, fmt.Errorf("unknown identity provider type %q", cfg.Type)
+	}
+}
+
+// Load 创建配置中的全部提供方；allowStub 为 false 时拒绝 type=stub，避免桩提供方被带到生产环境
+func Load(cfgs map[string]config.SocialProviderConfig, allowStub bool) (map[string]Provider, error) {
+	providers := make(map[string]Provider, len(cfgs))
+	for name, c := range cfgs {
+		if c.Type == "stub" && !allowStub {
+			return nil, fmt.Errorf("identity provider %s: %w", name, ErrStubNotAllowed)
+		}
+		p, err := New(c)
+		if err != nil {
+			return nil, fmt.Errorf("identity provider %s: %w", name, err)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
@@ -0,0 +1,60 @@
+package idp
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+)
+
+func stubConfig() config.SocialProviderConfig {
+	return config.SocialProviderConfig{
+		Type:        "stub",
+		RedirectURL: "http://localhost:3000/oauth/social/stub/callback?from=login",
+		Stub:        config.StubIdentityConfig{Subject: "stub-user-1", Email: "stub@example.com", EmailVerified: true, Name: "Stub"},
+	}
+}
+
+func TestLoadRequiresAllowStub(t *testing.T) {
+	cfgs := map[string]config.SocialProviderConfig{"stub": stubConfig()}
+	if _, err := Load(cfgs, false); !errors.Is(err, ErrStubNotAllowed) {
+		t.Fatalf("got %v, want ErrStubNotAllowed", err)
+	}
+	providers, err := Load(cfgs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := providers["stub"].(*StubProvider); !ok {
+		t.Fatalf("providers = %v", providers)
+	}
+
+	if _, err := Load(map[string]config.SocialProviderConfig{"x": {Type: "saml"}}, true); err == nil {
+		t.Fatal("unknown provider type accepted")
+	}
+}
+
+func TestStubProvider(t *testing.T) {
+	p := NewStubProvider(stubConfig().RedirectURL, stubConfig().Stub)
+
+	u, err := url.Parse(p.AuthURL("state-1", "nonce", "verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/oauth/social/stub/callback" || q.Get("state") != "state-1" || q.Get("from") != "login" {
+		t.Fatalf("AuthURL = %s", u)
+	}
+
+	id, err := p.Exchange(context.Background(), q.Get("code"), "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "stub-user-1" || id.Email != "stub@example.com" || !id.EmailVerified {
+		t.Fatalf("identity = %+v", id)
+	}
+	if _, err := p.Exchange(context.Background(), "forged", "verifier", "nonce"); !errors.Is(err, ErrExchangeFailed) {
+		t.Fatalf("forged code: got %v", err)
+	}
+}
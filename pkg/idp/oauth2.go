@@ -0,0 +1,92 @@
+package idp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+	"golang.org/x/oauth2"
+)
+
+// OAuth2Provider 不支持 OIDC 的 OAuth2 提供方（如 GitHub）：授权后调用 UserInfoURL 获取身份
+// 兼容常见字段：sub/id、email、email_verified、name/login、picture/avatar_url
+type OAuth2Provider struct {
+	conf        *oauth2.Config
+	userInfoURL string
+}
+
+func NewOAuth2Provider(cfg config.SocialProviderConfig) (*OAuth2Provider, error) {
+	if cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "" || cfg.ClientID == "" {
+		return nil, errors.New("auth_url, token_url, user_info_url and client_id are required")
+	}
+	return &OAuth2Provider{
+		conf: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     oauth2.Endpoint{AuthURL: cfg.AuthURL, TokenURL: cfg.TokenURL},
+			Scopes:       cfg.Scopes,
+		},
+		userInfoURL: cfg.UserInfoURL,
+	}, nil
+}
+
+func (p *OAuth2Provider) AuthURL(state, _ string, verifier string) string {
+	return p.conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+func (p *OAuth2Provider) Exchange(ctx context.Context, code, verifier, _ string) (*Identity, error) {
+	token, err := p.conf.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.userInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.conf.Client(ctx, token).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: userinfo: %v", ErrExchangeFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: userinfo status %d", ErrExchangeFailed, resp.StatusCode)
+	}
+
+	var info map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("%w: userinfo: %v", ErrExchangeFailed, err)
+	}
+
+	id := &Identity{
+		Subject: firstString(info, "sub", "id"),
+		Email:   firstString(info, "email"),
+		Name:    firstString(info, "name", "login"),
+		Picture: firstString(info, "picture", "avatar_url"),
+	}
+	id.EmailVerified, _ = info["email_verified"].(bool)
+	if id.Subject == "" {
+		return nil, fmt.Errorf("%w: userinfo without subject", ErrExchangeFailed)
+	}
+	return id, nil
+}
+
+// firstString 取第一个非空字段；数字 ID（如 GitHub）按整数格式化
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+	}
+	return ""
+}
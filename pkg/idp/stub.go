@@ -0,0 +1,50 @@
+package idp
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+)
+
+// stubCode 桩提供方签发的固定授权码
+const stubCode = "stub-code"
+
+// StubProvider 本地桩提供方：授权地址直接回跳 RedirectURL，Exchange 返回配置的身份
+// 用于本地开发与联调，不访问外部网络；只有开启 social_login.allow_stub 时 Load 才会创建
+type StubProvider struct {
+	redirectURL string
+	identity    Identity
+}
+
+func NewStubProvider(redirectURL string, id config.StubIdentityConfig) *StubProvider {
+	return &StubProvider{
+		redirectURL: redirectURL,
+		identity: Identity{
+			Subject:       id.Subject,
+			Email:         id.Email,
+			EmailVerified: id.EmailVerified,
+			Name:          id.Name,
+		},
+	}
+}
+
+func (p *StubProvider) AuthURL(state, _, _ string) string {
+	u, err := url.Parse(p.redirectURL)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	q.Set("code", stubCode)
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (p *StubProvider) Exchange(_ context.Context, code, _, _ string) (*Identity, error) {
+	if code != stubCode || p.identity.Subject == "" {
+		return nil, ErrExchangeFailed
+	}
+	id := p.identity
+	return &id, nil
+}
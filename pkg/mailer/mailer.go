@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/netkey/golang-user-mysql-redis/internal/config"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender 邮件发送接口，业务层只依赖该接口，便于替换为 SMTP / 文件 / 内存实现
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New 按配置中的 driver 创建发送器
+func New(cfg config.MailConfig) (Sender, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPSender(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From), nil
+	case "file", "":
+		return NewFileSender(cfg.FileDir, cfg.From)
+	case "memory":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileSender 将邮件写入目录下的 .eml 文件，用于本地开发与联调
+type FileSender struct {
+	dir  string
+	from string
+	seq  atomic.Int64 // 同一纳秒内多封邮件时避免文件名冲突
+}
+
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSender{dir: dir, from: from}, nil
+}
+
+// Send 文件名为 <时间戳>-<序号>-<收件人>.eml，收件人来自用户输入，只保留安全字符
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	name := fmt.Sprintf("%d-%d-%s.eml", time.Now().UnixNano(), s.seq.Add(1), safeFileName(msg.To))
+	return os.WriteFile(filepath.Join(s.dir, name), buildMIME(s.from, msg), 0o644)
+}
+
+// maxRecipientNameLen 文件名中收件人部分的最大长度
+const maxRecipientNameLen = 64
+
+// safeFileName 将收件人地址转换为不含路径分隔符的文件名片段，"@" 转为 "_at_"，其他非字母数字字符转为 "_"
+func safeFileName(to string) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(to, "@", "_at_") {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+		if b.Len() >= maxRecipientNameLen {
+			break
+		}
+	}
+	return b.String()
+}
+
+// MemorySender 将邮件保存在内存中，用于测试断言
+type MemorySender struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+// Messages 返回已发送邮件的副本
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.msgs...)
+}
+
+// Last 返回发给指定地址的最后一封邮件
+func (s *MemorySender) Last(to string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.msgs) - 1; i >= 0; i-- {
+		if s.msgs[i].To == to {
+			return s.msgs[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemorySender(t *testing.T) {
+	s := NewMemorySender()
+	ctx := context.Background()
+	s.Send(ctx, Message{To: "a@example.com", Subject: "first"})
+	s.Send(ctx, Message{To: "b@example.com", Subject: "other"})
+	s.Send(ctx, Message{To: "a@example.com", Subject: "second"})
+
+	if got := len(s.Messages()); got != 3 {
+		t.Fatalf("Messages() = %d, want 3", got)
+	}
+	if m, ok := s.Last("a@example.com"); !ok || m.Subject != "second" {
+		t.Fatalf("Last = %+v, %v", m, ok)
+	}
+	if _, ok := s.Last("c@example.com"); ok {
+		t.Fatal("Last found mail for unknown recipient")
+	}
+}
+
+func TestFileSenderWritesMIME(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSender(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), Message{To: "alice@example.com", Subject: "请验证您的邮箱", Body: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("got %d files", len(files))
+	}
+	if !strings.HasSuffix(files[0], "-alice_at_example_com.eml") {
+		t.Fatalf("unexpected file name %s", filepath.Base(files[0]))
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, want := range []string{"From: no-reply@example.com\r\n", "To: alice@example.com\r\n", "Subject: =?UTF-8?B?", "\r\n\r\nhello"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("mail missing %q", want)
+		}
+	}
+}
+
+// TestFileSenderStaysInDir 收件人地址中的路径字符不能让文件写到目录之外
+func TestFileSenderStaysInDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "mail")
+	s, err := NewFileSender(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, to := range []string{"../../escape@example.com", `..\..\escape@example.com`, "a/b@example.com", "/etc/passwd", strings.Repeat("x", 300) + "@example.com"} {
+		if err := s.Send(context.Background(), Message{To: to}); err != nil {
+			t.Fatalf("%q: %v", to, err)
+		}
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "mail" {
+		t.Fatalf("files written outside mail dir: %v", entries)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 5 {
+		t.Fatalf("got %d files, want 5", len(files))
+	}
+	for _, f := range files {
+		if f.IsDir() || len(f.Name()) > 128 {
+			t.Errorf("unexpected entry %q", f.Name())
+		}
+	}
+}
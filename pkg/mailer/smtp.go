@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPSender 通过 SMTP (PLAIN 认证) 发送邮件
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPSender(host string, port int, username, password, from string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPSender{addr: fmt.Sprintf("%s:%d", host, port), auth: auth, from: from}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, buildMIME(s.from, msg))
+}
+
+// buildMIME 构造 UTF-8 纯文本邮件，主题使用 RFC 2047 编码以支持中文
+func buildMIME(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: =?UTF-8?B?%s?=\r\n", base64Std(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
+
+func base64Std(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
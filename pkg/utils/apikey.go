@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// 个人 API Key 格式：uk_<8 位十六进制前缀>_<43 位随机串>
+// 前缀明文入库用于查找与展示，完整 Key 只保存 SHA-256 哈希
+const (
+	APIKeyPrefix    = "uk_"
+	TokenTypeAPIKey = "api_key" // API Key 鉴权通过后构造的 Claims 类型
+)
+
+// IsAPIKey 判断 Bearer 凭证是 API Key 还是 JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// GenerateAPIKey 生成新的 API Key，返回完整 Key 与其前缀
+func GenerateAPIKey() (key, prefix string, err error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// APIKeyPrefixOf 提取 Key 的前缀，格式不正确时返回 false
+func APIKeyPrefixOf(key string) (string, bool) {
+	if !IsAPIKey(key) {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	if !ok || len(prefix) != 8 || secret == "" {
+		return "", false
+	}
+	return APIKeyPrefix + prefix, true
+}
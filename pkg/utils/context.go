@@ -0,0 +1,41 @@
+package utils
+
+import "context"
+
+// claimsKey 使用私有类型作为 Context Key，避免与其他包冲突
+type claimsKey struct{}
+
+// ContextWithClaims 将已校验的 Claims 注入 Context (HTTP 中间件 / gRPC 拦截器使用)
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext 读取当前请求的 Claims
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// UserIDFromContext 读取当前请求的用户 ID (Handler 使用)
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	id := claims.UserID()
+	return id, id > 0
+}
+
+// serviceKey 服务间调用的调用方服务名
+type serviceKey struct{}
+
+// ContextWithService 注入已认证的调用方服务名 (gRPC 服务间鉴权使用)
+func ContextWithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceKey{}, service)
+}
+
+// ServiceFromContext 读取调用方服务名，终端用户请求返回 false
+func ServiceFromContext(ctx context.Context) (string, bool) {
+	service, ok := ctx.Value(serviceKey{}).(string)
+	return service, ok && service != ""
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"context"
+	"strconv"
+)
+
+// Actor 实际操作者 (RFC 8693 act claim)
+// 管理员模拟用户时，sub 为被模拟的用户，act.sub 为管理员 ID
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// ActorID 模拟登录的管理员 ID，普通 Token 返回 0
+func (c *Claims) ActorID() int {
+	if c.Actor == nil {
+		return 0
+	}
+	id, err := strconv.Atoi(c.Actor.Subject)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// Impersonated 是否为模拟登录 Token
+func (c *Claims) Impersonated() bool {
+	return c.ActorID() > 0
+}
+
+// ActorIDFromContext 读取模拟登录的管理员 ID，非模拟登录返回 false
+// 此时 UserIDFromContext 返回的是被模拟的用户
+func ActorIDFromContext(ctx context.Context) (int, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	id := claims.ActorID()
+	return id, id > 0
+}
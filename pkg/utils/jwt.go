@@ -1,32 +1,179 @@
 package utils
 
 import (
-	"github.com/golang-jwt/jwt/v5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 const (
-	TokenTypeAccess  = "access"
-	TokenTypeRefresh = "refresh"
+	TokenTypeAccess        = "access"
+	TokenTypeRefresh       = "refresh"
+	TokenTypeEmailVerify   = "email_verify"
+	TokenTypePasswordReset = "password_reset"
+	TokenTypeMFAPending    = "mfa_pending" // 已通过密码校验、等待二次验证
 )
 
+// ErrInvalidToken Token 签名、格式、类型或有效期校验失败
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims 全服务统一的 JWT Claims
+// sub 为用户 ID，iss/aud 用于拒绝其他系统签发或面向其他系统的 Token
+type Claims struct {
+	Type       string `json:"typ"`                 // access / refresh
+	Generation int64  `json:"gen"`                 // 用户 Token 代数，"退出所有设备"时自增
+	SessionID  string `json:"sid,omitempty"`       // 会话 ID，同一次登录派生出的 Token 共享
+	Scope      string `json:"scope,omitempty"`     // OAuth2 授权范围（client_credentials 与 OIDC 客户端 Token 使用）
+	ClientID   string `json:"client_id,omitempty"` // 持有 Token 的 OIDC 客户端（oauth_access / oauth_refresh）
+	Actor      *Actor `json:"act,omitempty"`       // 管理员模拟登录时的实际操作者
+	jwt.RegisteredClaims
+}
+
+// UserID 从 sub 中解析用户 ID，解析失败返回 0
+func (c *Claims) UserID() int {
+	id, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// TokenPair 一对 Access/Refresh Token
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Access Token 有效期（秒）
+	RefreshID    string `json:"-"`          // Refresh Token 的 jti，服务端轮换校验使用
+}
+
+// JWTManager 负责 Token 的签发与校验，签发方与校验方共用同一份配置
+// 使用非对称密钥 (RS256/EdDSA) 签名，其他服务可通过 JWKS 公钥离线校验
+type JWTManager struct {
+	keys     *Keyring
+	issuer   string
+	audience string
+}
+
+func NewJWTManager(keys *Keyring, issuer, audience string) *JWTManager {
+	return &JWTManager{keys: keys, issuer: issuer, audience: audience}
+}
+
+// NewTokenID 生成随机的 Token ID (jti)，用于吊销列表
+func NewTokenID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ExtractBearer 从 "Bearer <token>" 格式中提取 Token
+func ExtractBearer(header string) (string, bool) {
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// NewClaims 构建标准 Claims，自动填充 iss/aud/sub/jti/iat/exp
+func (m *JWTManager) NewClaims(userID int, tokenType string, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		Type: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{m.audience},
+			ID:        NewTokenID(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// Sign 使用当前密钥签名 Claims，并在 Header 中写入 kid
+func (m *JWTManager) Sign(claims *Claims) (string, error) {
+	return m.sign(claims)
+}
+
+func (m *JWTManager) sign(claims jwt.Claims) (string, error) {
+	key := m.keys.Active()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Parse 校验签名、有效期、iss/aud 以及 Token 类型，sub 必须为用户 ID
+func (m *JWTManager) Parse(tokenString, tokenType string) (*Claims, error) {
+	claims, err := m.parse(tokenString, tokenType)
+	if err != nil || claims.UserID() <= 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (m *JWTManager) parse(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		// 按 kid 选择公钥，且签名算法必须与密钥类型一致，防止算法混淆攻击
+		kid, _ := t.Header["kid"].(string)
+		key, ok := m.keys.Lookup(kid)
+		if !ok || t.Method.Alg() != key.Algorithm {
+			return nil, ErrInvalidToken
+		}
+		return key.Public, nil
+	},
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(m.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Type != tokenType || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
 // GenerateTokenPair 生成一对 Token
-func GenerateTokenPair(userID int, secret string) (string, string, error) {
-	// Access Token (1 小时)
-	atClaims := jwt.MapClaims{
-		"userID": userID,
-		"type":   TokenTypeAccess,
-		"exp":    time.Now().Add(time.Hour * 1).Unix(),
+// sessionID 标识同一次登录派生出的所有 Token，Refresh Token 被重放时整个会话吊销
+func (m *JWTManager) GenerateTokenPair(userID int, generation int64, sessionID string, accessTTL, refreshTTL time.Duration) (*TokenPair, error) {
+	atClaims := m.NewClaims(userID, TokenTypeAccess, accessTTL)
+	rtClaims := m.NewClaims(userID, TokenTypeRefresh, refreshTTL)
+	for _, c := range []*Claims{atClaims, rtClaims} {
+		c.Generation = generation
+		c.SessionID = sessionID
 	}
-	at, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims).SignedString([]byte(secret))
+	return m.signPair(atClaims, rtClaims, accessTTL)
+}
 
-	// Refresh Token (7 天)
-	rtClaims := jwt.MapClaims{
-		"userID": userID,
-		"type":   TokenTypeRefresh,
-		"exp":    time.Now().Add(time.Hour * 24 * 7).Unix(),
+// signPair 签名 Access / Refresh Token
+func (m *JWTManager) signPair(atClaims, rtClaims *Claims, accessTTL time.Duration) (*TokenPair, error) {
+	at, err := m.Sign(atClaims)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := m.Sign(rtClaims)
+	if err != nil {
+		return nil, err
 	}
-	rt, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims).SignedString([]byte(secret))
 
-	return at, rt, nil
+	return &TokenPair{
+		AccessToken:  at,
+		RefreshToken: rt,
+		ExpiresIn:    int64(accessTTL.Seconds()),
+		RefreshID:    rtClaims.ID,
+	}, nil
 }
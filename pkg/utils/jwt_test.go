@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	keys, err := LoadKeyring(t.TempDir(), AlgEdDSA, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestParseAcceptsOwnToken(t *testing.T) {
+	m := NewJWTManager(newTestKeyring(t), "user-service", "user-service-api")
+	pair, err := m.GenerateTokenPair(42, 3, "sess-1", time.Hour, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := m.Parse(pair.AccessToken, TokenTypeAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.UserID() != 42 || c.Generation != 3 || c.SessionID != "sess-1" || c.ID == "" {
+		t.Fatalf("unexpected claims: %+v", c)
+	}
+	if c.Issuer != "user-service" || len(c.Audience) != 1 || c.Audience[0] != "user-service-api" {
+		t.Fatalf("unexpected iss/aud: %s %v", c.Issuer, c.Audience)
+	}
+}
+
+func TestParseRejectsForeignTokens(t *testing.T) {
+	keys := newTestKeyring(t)
+	m := NewJWTManager(keys, "user-service", "user-service-api")
+	pair, err := m.GenerateTokenPair(42, 0, "sess-1", time.Hour, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(m *JWTManager, c *Claims) string {
+		s, err := m.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	otherIss := NewJWTManager(keys, "other-service", "user-service-api")
+	otherAud := NewJWTManager(keys, "user-service", "other-api")
+	otherKey := NewJWTManager(newTestKeyring(t), "user-service", "user-service-api")
+	expired := m.NewClaims(42, TokenTypeAccess, time.Hour)
+	expired.ExpiresAt.Time = time.Now().Add(-time.Hour)
+	noSubject := m.NewClaims(0, TokenTypeAccess, time.Hour)
+
+	cases := map[string]string{
+		"refresh as access": pair.RefreshToken,
+		"wrong issuer":      sign(otherIss, otherIss.NewClaims(42, TokenTypeAccess, time.Hour)),
+		"wrong audience":    sign(otherAud, otherAud.NewClaims(42, TokenTypeAccess, time.Hour)),
+		"unknown key":       sign(otherKey, otherKey.NewClaims(42, TokenTypeAccess, time.Hour)),
+		"expired":           sign(m, expired),
+		"no subject":        sign(m, noSubject),
+		"garbage":           "not-a-jwt",
+	}
+	for name, token := range cases {
+		if _, err := m.Parse(token, TokenTypeAccess); err != ErrInvalidToken {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestClaimsContext(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := UserIDFromContext(ctx); ok {
+		t.Fatal("empty context has a user")
+	}
+
+	ctx = ContextWithClaims(ctx, &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "7"}})
+	if id, ok := UserIDFromContext(ctx); !ok || id != 7 {
+		t.Fatalf("UserIDFromContext = %d, %v", id, ok)
+	}
+	if _, ok := ServiceFromContext(ctx); ok {
+		t.Fatal("user request reported as service")
+	}
+
+	// sub 不是数字时不视为用户
+	ctx = ContextWithClaims(context.Background(), &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "my-app"}})
+	if _, ok := UserIDFromContext(ctx); ok {
+		t.Fatal("non-numeric subject reported as user")
+	}
+}
@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/netkey/golang-user-mysql-redis/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// ErrNoSigningKey 密钥目录中没有可用的签名密钥
+var ErrNoSigningKey = errors.New("no signing key available")
+
+// SigningKey 单个签名密钥，kid 对应 JWT Header 中的 "kid"
+type SigningKey struct {
+	ID        string
+	Algorithm string // RS256 / EdDSA，由密钥类型决定
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// Keyring 签名密钥环
+// 目录中的每个 <kid>.pem 都是一把私钥，kid 字典序最大的一把用于签发，其余只用于校验。
+// 轮换方式：放入新的私钥文件并调用 Reload；被替换的旧密钥在 retain（Token 最长有效期）内继续用于校验，
+// 之后从校验集合与 JWKS 中移除，届时可安全删除文件。
+// 旧密钥的替换时间取接替它的密钥文件的修改时间，进程重启后保留期不会重新计算。
+type Keyring struct {
+	dir    string
+	retain time.Duration
+
+	mu        sync.RWMutex
+	active    *SigningKey
+	keys      map[string]*SigningKey
+	retiredAt map[string]time.Time // kid -> 被替换的时间
+}
+
+// LoadKeyring 从目录加载密钥；目录为空时按 alg 生成一把新密钥并写入目录
+func LoadKeyring(dir, alg string, retain time.Duration) (*Keyring, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{dir: dir, retain: retain}
+	if err := k.Reload(); errors.Is(err, ErrNoSigningKey) {
+		kid := time.Now().UTC().Format("20060102150405")
+		if err := GenerateKeyFile(dir, kid, alg); err != nil {
+			return nil, err
+		}
+		return k, k.Reload()
+	} else if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload 重新扫描密钥目录（密钥轮换后调用）
+func (k *Keyring) Reload() error {
+	files, err := filepath.Glob(filepath.Join(k.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return ErrNoSigningKey
+	}
+	sort.Strings(files)
+
+	keys := make(map[string]*SigningKey, len(files))
+	retiredAt := make(map[string]time.Time, len(files)-1)
+	var prev string
+	for _, f := range files {
+		key, err := LoadKeyFile(f)
+		if err != nil {
+			return fmt.Errorf("load key %s: %w", f, err)
+		}
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		// 上一把密钥在本文件写入时被替换
+		if prev != "" {
+			retiredAt[prev] = info.ModTime()
+		}
+		keys[key.ID] = key
+		prev = key.ID
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active = keys[prev]
+	k.keys = keys
+	k.retiredAt = retiredAt
+	return nil
+}
+
+// Watch 监听密钥目录，新增/删除私钥文件时自动 Reload，实现不停机轮换
+func (k *Keyring) Watch() (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(k.dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !strings.HasSuffix(ev.Name, ".pem") {
+					continue
+				}
+				if err := k.Reload(); err != nil {
+					logger.Log.Error("JWT 密钥重新加载失败", zap.Error(err))
+					continue
+				}
+				logger.Log.Info("JWT 密钥已重新加载", zap.String("active_kid", k.Active().ID))
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Log.Error("JWT 密钥目录监听异常", zap.Error(err))
+			}
+		}
+	}()
+	return func() { watcher.Close() }, nil
+}
+
+// Active 当前用于签发的密钥
+func (k *Keyring) Active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup 按 kid 查找可用于校验的密钥，已超过保留期的旧密钥视为不存在
+func (k *Keyring) Lookup(kid string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[kid]
+	if !ok || !k.verifiable(kid) {
+		return nil, false
+	}
+	return key, true
+}
+
+func (k *Keyring) verifiable(kid string) bool {
+	retired, ok := k.retiredAt[kid]
+	return !ok || time.Since(retired) <= k.retain
+}
+
+// JWK 公钥的 JSON Web Key 表示 (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet /.well-known/jwks.json 的返回结构
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 导出当前所有可用于校验的公钥
+func (k *Keyring) JWKS() JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(k.keys))}
+	for kid, key := range k.keys {
+		if !k.verifiable(kid) {
+			continue
+		}
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.Algorithm}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// LoadKeyFile 读取 PEM 私钥 (PKCS#8，RSA 兼容 PKCS#1)，kid 取文件名
+func LoadKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	var priv interface{}
+	if priv, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if priv, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: kid, Algorithm: AlgRS256, Private: p, Public: &p.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: kid, Algorithm: AlgEdDSA, Private: p, Public: p.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", priv)
+	}
+}
+
+// GenerateKeyFile 生成新的私钥并以 PKCS#8 PEM 写入 <dir>/<kid>.pem
+func GenerateKeyFile(dir, kid, alg string) error {
+	var priv interface{}
+	var err error
+	switch alg {
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA, "":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600)
+}
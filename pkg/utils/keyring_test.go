@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoadKeyringGeneratesKey(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := LoadKeyring(dir, AlgRS256, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active := keys.Active()
+	if active == nil || active.Algorithm != AlgRS256 {
+		t.Fatalf("active key = %+v", active)
+	}
+	if _, err := os.Stat(filepath.Join(dir, active.ID+".pem")); err != nil {
+		t.Fatalf("generated key not written: %v", err)
+	}
+
+	// 再次加载沿用已有密钥
+	again, err := LoadKeyring(dir, AlgEdDSA, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Active().ID != active.ID {
+		t.Fatalf("reload picked %s, want %s", again.Active().ID, active.ID)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateKeyFile(dir, "2024-01", AlgEdDSA); err != nil {
+		t.Fatal(err)
+	}
+	const retain = 200 * time.Millisecond
+	keys, err := LoadKeyring(dir, AlgEdDSA, retain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewJWTManager(keys, "user-service", "user-service-api")
+	old, err := m.Sign(m.NewClaims(1, TokenTypeAccess, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 放入字典序更大的新密钥：新 Token 使用新 kid，旧 Token 在保留期内仍可校验
+	if err := GenerateKeyFile(dir, "2024-02", AlgRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if keys.Active().ID != "2024-02" {
+		t.Fatalf("active kid = %s, want 2024-02", keys.Active().ID)
+	}
+	fresh, err := m.Sign(m.NewClaims(1, TokenTypeAccess, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := headerKid(t, fresh); kid != "2024-02" {
+		t.Fatalf("new token kid = %s", kid)
+	}
+	if _, err := m.Parse(old, TokenTypeAccess); err != nil {
+		t.Fatalf("old token rejected within retention: %v", err)
+	}
+	if got := kids(keys.JWKS()); len(got) != 2 || got[0] != "2024-01" || got[1] != "2024-02" {
+		t.Fatalf("JWKS kids = %v", got)
+	}
+
+	// 超过保留期后旧密钥不再用于校验，也从 JWKS 中移除
+	time.Sleep(retain + 50*time.Millisecond)
+	if _, err := m.Parse(old, TokenTypeAccess); err != ErrInvalidToken {
+		t.Fatalf("old token after retention: got %v", err)
+	}
+	if _, err := m.Parse(fresh, TokenTypeAccess); err != nil {
+		t.Fatalf("new token: %v", err)
+	}
+	if got := kids(keys.JWKS()); len(got) != 1 || got[0] != "2024-02" {
+		t.Fatalf("JWKS kids after retention = %v", got)
+	}
+}
+
+// TestKeyringRetentionSurvivesRestart 替换时间取自新密钥文件，重新加载不会延长旧密钥的保留期
+func TestKeyringRetentionSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	for _, kid := range []string{"2024-01", "2024-02"} {
+		if err := GenerateKeyFile(dir, kid, AlgEdDSA); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 新密钥两小时前放入
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "2024-02.pem"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	for retain, want := range map[time.Duration][]string{
+		time.Hour:     {"2024-02"},
+		3 * time.Hour: {"2024-01", "2024-02"},
+	} {
+		keys, err := LoadKeyring(dir, AlgEdDSA, retain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := kids(keys.JWKS()); !slices.Equal(got, want) {
+			t.Errorf("retain %v: JWKS kids = %v, want %v", retain, got, want)
+		}
+	}
+}
+
+// TestJWKSVerifiesOffline 其他服务只凭 JWKS 中的公钥即可校验 Token
+func TestJWKSVerifiesOffline(t *testing.T) {
+	for _, alg := range []string{AlgEdDSA, AlgRS256} {
+		keys, err := LoadKeyring(t.TempDir(), alg, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := NewJWTManager(keys, "user-service", "user-service-api")
+		token, err := m.Sign(m.NewClaims(1, TokenTypeAccess, time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		set := keys.JWKS()
+		if len(set.Keys) != 1 {
+			t.Fatalf("%s: JWKS has %d keys", alg, len(set.Keys))
+		}
+		jwk := set.Keys[0]
+		if jwk.Alg != alg || jwk.Use != "sig" || jwk.Kid != keys.Active().ID {
+			t.Fatalf("%s: unexpected JWK %+v", alg, jwk)
+		}
+		pub := jwkPublicKey(t, jwk)
+		_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return pub, nil }, jwt.WithValidMethods([]string{alg}))
+		if err != nil {
+			t.Fatalf("%s: verify with JWKS key: %v", alg, err)
+		}
+	}
+}
+
+func headerKid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func kids(set JWKSet) []string {
+	out := make([]string, len(set.Keys))
+	for i, k := range set.Keys {
+		out[i] = k.Kid
+	}
+	return out
+}
+
+func jwkPublicKey(t *testing.T, k JWK) interface{} {
+	t.Helper()
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	switch k.Kty {
+	case "OKP":
+		return ed25519.PublicKey(decode(k.X))
+	case "RSA":
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(decode(k.N)), E: int(new(big.Int).SetBytes(decode(k.E)).Int64())}
+	}
+	t.Fatalf("unexpected kty %s", k.Kty)
+	return nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// TokenTypeClient OAuth2 client_credentials 签发给应用（而非用户）的 Access Token，sub 为 client_id
+	TokenTypeClient = "client"
+	// TokenTypeOAuthAccess / TokenTypeOAuthRefresh OIDC 客户端通过授权码代表用户持有的 Token
+	// 与直接登录签发的 access / refresh 区分：只能访问 /oauth/userinfo 等按 scope 授权的端点，不能调用用户 API
+	TokenTypeOAuthAccess  = "oauth_access"
+	TokenTypeOAuthRefresh = "oauth_refresh"
+)
+
+// IDTokenClaims OIDC ID Token
+// iss 为 OIDC Issuer (URL)，aud 为 client_id，与面向本服务 API 的 Access Token 不同
+type IDTokenClaims struct {
+	Nonce             string           `json:"nonce,omitempty"`
+	AuthTime          *jwt.NumericDate `json:"auth_time,omitempty"`
+	AuthorizedParty   string           `json:"azp,omitempty"`
+	Name              string           `json:"name,omitempty"`
+	PreferredUsername string           `json:"preferred_username,omitempty"`
+	Picture           string           `json:"picture,omitempty"`
+	Email             string           `json:"email,omitempty"`
+	EmailVerified     *bool            `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// SignIDToken 使用当前密钥签名 ID Token，客户端通过 JWKS 校验
+func (m *JWTManager) SignIDToken(claims *IDTokenClaims) (string, error) {
+	return m.sign(claims)
+}
+
+// NewClientClaims 构建 client_credentials Access Token 的 Claims
+func (m *JWTManager) NewClientClaims(clientID, scope string, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		Type:  TokenTypeClient,
+		Scope: scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   clientID,
+			Audience:  jwt.ClaimStrings{m.audience},
+			ID:        NewTokenID(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// GenerateOAuthTokenPair 生成 OIDC 客户端持有的 Token 对，带 client_id 与授权范围
+func (m *JWTManager) GenerateOAuthTokenPair(userID int, generation int64, sessionID, clientID, scope string, accessTTL, refreshTTL time.Duration) (*TokenPair, error) {
+	atClaims := m.NewClaims(userID, TokenTypeOAuthAccess, accessTTL)
+	rtClaims := m.NewClaims(userID, TokenTypeOAuthRefresh, refreshTTL)
+	for _, c := range []*Claims{atClaims, rtClaims} {
+		c.Generation = generation
+		c.SessionID = sessionID
+		c.ClientID = clientID
+		c.Scope = scope
+	}
+	return m.signPair(atClaims, rtClaims, accessTTL)
+}
+
+// ParseClientToken 校验 client_credentials Access Token，sub 为 client_id
+func (m *JWTManager) ParseClientToken(tokenString string) (*Claims, error) {
+	claims, err := m.parse(tokenString, TokenTypeClient)
+	if err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// SigningAlgorithm 当前签名密钥的算法 (OIDC 发现文档使用)
+func (m *JWTManager) SigningAlgorithm() string {
+	if key := m.keys.Active(); key != nil {
+		return key.Algorithm
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 支持的密码哈希算法
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+// ErrUnknownHashFormat 无法识别的密码哈希格式
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Argon2Params Argon2id 参数，编码在 PHC 字符串中，校验时以存储的参数为准
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLen     uint32
+	KeyLen      uint32
+}
+
+// PasswordHasher 按配置的算法生成密码哈希，并能校验任意已支持算法生成的旧哈希
+// 存储格式自描述算法与参数：
+//
+//	bcrypt:   $2a$12$...
+//	argon2id: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>  (PHC 格式，Base64 无填充)
+type PasswordHasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     Argon2Params
+}
+
+// NewPasswordHasher 创建哈希器，参数为零值时使用推荐默认值
+func NewPasswordHasher(algorithm string, bcryptCost int, params Argon2Params) (*PasswordHasher, error) {
+	if algorithm == "" {
+		algorithm = HashArgon2id
+	}
+	if algorithm != HashArgon2id && algorithm != HashBcrypt {
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", algorithm)
+	}
+	if bcryptCost == 0 {
+		bcryptCost = bcrypt.DefaultCost
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid bcrypt cost %d", bcryptCost)
+	}
+	if params.Memory == 0 {
+		params.Memory = 64 * 1024
+	}
+	if params.Iterations == 0 {
+		params.Iterations = 3
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = 2
+	}
+	if params.SaltLen == 0 {
+		params.SaltLen = 16
+	}
+	if params.KeyLen == 0 {
+		params.KeyLen = 32
+	}
+	return &PasswordHasher{algorithm: algorithm, bcryptCost: bcryptCost, argon2: params}, nil
+}
+
+// Hash 使用当前配置的算法与参数生成密码哈希
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.algorithm == HashBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashed), nil
+	}
+
+	p := h.argon2
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify 按存储哈希自身的算法与参数校验密码
+// needsRehash 为 true 表示密码正确但哈希的算法或参数与当前配置不一致，应在登录成功后重新哈希
+func (h *PasswordHasher) Verify(password, encoded string) (ok bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return true, true, nil
+		}
+		return true, h.algorithm != HashBcrypt || cost != h.bcryptCost, nil
+
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		computed := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false, nil
+		}
+		want := h.argon2
+		stale := h.algorithm != HashArgon2id ||
+			p.Memory != want.Memory || p.Iterations != want.Iterations || p.Parallelism != want.Parallelism ||
+			uint32(len(salt)) != want.SaltLen || uint32(len(key)) != want.KeyLen
+		return true, stale, nil
+	}
+	return false, false, ErrUnknownHashFormat
+}
+
+// decodeArgon2id 解析 $argon2id$v=19$m=...,t=...,p=...$salt$hash
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword 密码不符合密码策略，具体原因通过 %w 包装在错误信息中
+var ErrWeakPassword = errors.New("密码不符合要求")
+
+// PasswordPolicy 注册、修改 / 重置密码时的密码强度校验
+type PasswordPolicy struct {
+	minLength     int
+	maxLength     int
+	breached      map[string]struct{} // 泄露密码的 SHA-1（大写十六进制）
+	checkPersonal bool
+}
+
+// NewPasswordPolicy 创建密码策略；breachedFile 每行一个明文密码或其 SHA-1（如 HIBP 导出），# 开头为注释
+func NewPasswordPolicy(minLength, maxLength int, breachedFile string, checkPersonal bool) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{
+		minLength:     minLength,
+		maxLength:     maxLength,
+		breached:      make(map[string]struct{}),
+		checkPersonal: checkPersonal,
+	}
+	if p.minLength <= 0 {
+		p.minLength = 8
+	}
+	if breachedFile == "" {
+		return p, nil
+	}
+
+	f, err := os.Open(breachedFile)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// HIBP 格式为 "SHA1:次数"
+		if h, _, _ := strings.Cut(line, ":"); len(h) == 40 && isHex(h) {
+			p.breached[strings.ToUpper(h)] = struct{}{}
+			continue
+		}
+		p.breached[sha1Upper(line)] = struct{}{}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read breached password list: %w", err)
+	}
+	return p, nil
+}
+
+// Check 校验密码；personal 为账号相关信息（邮箱、账号名、昵称），密码不能包含或近似于它们
+func (p *PasswordPolicy) Check(password string, personal ...string) error {
+	n := utf8.RuneCountInString(password)
+	if n < p.minLength {
+		return fmt.Errorf("%w：长度至少 %d 位", ErrWeakPassword, p.minLength)
+	}
+	if p.maxLength > 0 && n > p.maxLength {
+		return fmt.Errorf("%w：长度不能超过 %d 位", ErrWeakPassword, p.maxLength)
+	}
+
+	if _, ok := p.breached[sha1Upper(password)]; ok {
+		return fmt.Errorf("%w：该密码已出现在公开泄露的密码库中", ErrWeakPassword)
+	}
+	if _, ok := p.breached[sha1Upper(strings.ToLower(password))]; ok {
+		return fmt.Errorf("%w：该密码已出现在公开泄露的密码库中", ErrWeakPassword)
+	}
+
+	if p.checkPersonal {
+		lower := strings.ToLower(password)
+		for _, word := range personalWords(personal) {
+			if strings.Contains(lower, word) || levenshtein(lower, word) <= utf8.RuneCountInString(lower)/3 {
+				return fmt.Errorf("%w：不能包含或近似于邮箱、账号名或昵称", ErrWeakPassword)
+			}
+		}
+	}
+	return nil
+}
+
+// personalWords 拆出需要比对的词：完整值与邮箱 @ 前的部分，过短的词忽略以免误杀
+func personalWords(values []string) []string {
+	var words []string
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if local, _, ok := strings.Cut(v, "@"); ok {
+			words = append(words, local)
+		}
+		words = append(words, v)
+	}
+	out := words[:0]
+	for _, w := range words {
+		if utf8.RuneCountInString(w) >= 3 {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// levenshtein 编辑距离（按字符计）
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func sha1Upper(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 测试使用最低的 Argon2id 参数
+var testArgon2 = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func newTestHasher(t *testing.T, algorithm string, bcryptCost int, params Argon2Params) *PasswordHasher {
+	t.Helper()
+	h, err := NewPasswordHasher(algorithm, bcryptCost, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	for _, alg := range []string{HashArgon2id, HashBcrypt} {
+		t.Run(alg, func(t *testing.T) {
+			h := newTestHasher(t, alg, bcrypt.MinCost, testArgon2)
+			encoded, err := h.Hash("Correct-Horse-9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok, rehash, err := h.Verify("Correct-Horse-9", encoded)
+			if err != nil || !ok || rehash {
+				t.Fatalf("Verify = %v, %v, %v; want true, false, nil", ok, rehash, err)
+			}
+			ok, rehash, err = h.Verify("wrong", encoded)
+			if err != nil || ok || rehash {
+				t.Fatalf("Verify(wrong) = %v, %v, %v; want false, false, nil", ok, rehash, err)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherPHCFormat(t *testing.T) {
+	h := newTestHasher(t, HashArgon2id, 0, testArgon2)
+	encoded, err := h.Hash("Correct-Horse-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("encoded = %s", encoded)
+	}
+	// 默认参数下的编码长度不超过 users.password 列宽
+	h = newTestHasher(t, HashArgon2id, 0, Argon2Params{})
+	if encoded, _ = h.Hash("Correct-Horse-9"); len(encoded) > 128 {
+		t.Fatalf("encoded length %d exceeds VARCHAR(128)", len(encoded))
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	oldBcrypt := newTestHasher(t, HashBcrypt, bcrypt.MinCost, testArgon2)
+	oldArgon := newTestHasher(t, HashArgon2id, 0, testArgon2)
+	bcryptHash, _ := oldBcrypt.Hash("Correct-Horse-9")
+	argonHash, _ := oldArgon.Hash("Correct-Horse-9")
+
+	tests := []struct {
+		name    string
+		current *PasswordHasher
+		stored  string
+		want    bool
+	}{
+		{"bcrypt to argon2id", oldArgon, bcryptHash, true},
+		{"bcrypt cost raised", newTestHasher(t, HashBcrypt, bcrypt.MinCost+1, testArgon2), bcryptHash, true},
+		{"argon2id to bcrypt", oldBcrypt, argonHash, true},
+		{"argon2id memory raised", newTestHasher(t, HashArgon2id, 0, Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1}), argonHash, true},
+		{"argon2id iterations raised", newTestHasher(t, HashArgon2id, 0, Argon2Params{Memory: 1024, Iterations: 2, Parallelism: 1}), argonHash, true},
+		{"argon2id unchanged", oldArgon, argonHash, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := tt.current.Verify("Correct-Horse-9", tt.stored)
+			if err != nil || !ok {
+				t.Fatalf("Verify = %v, %v", ok, err)
+			}
+			if rehash != tt.want {
+				t.Fatalf("needsRehash = %v, want %v", rehash, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherUnknownFormat(t *testing.T) {
+	h := newTestHasher(t, HashArgon2id, 0, testArgon2)
+	for _, encoded := range []string{"", "plaintext", "$argon2id$v=19$m=1024$salt$hash", "$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$aGFzaA"} {
+		if ok, _, err := h.Verify("x", encoded); ok || err == nil {
+			t.Errorf("Verify(%q) = %v, %v; want error", encoded, ok, err)
+		}
+	}
+	if _, _, err := h.Verify("x", "plaintext"); !errors.Is(err, ErrUnknownHashFormat) {
+		t.Errorf("got %v, want ErrUnknownHashFormat", err)
+	}
+	if _, err := NewPasswordHasher("md5", 0, Argon2Params{}); err == nil {
+		t.Error("unsupported algorithm accepted")
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	// 明文与 HIBP 格式 (SHA1:次数) 混合；后者为 "Password123" 的 SHA-1
+	content := "# comment\nletmein2024\nB2E98AD6F6EB8508DD6A14CFA704BAD7F05F6FB1:4242\n"
+	if err := os.WriteFile(list, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPasswordPolicy(8, 20, list, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"Correct-Horse-9", true},
+		{"short", false},
+		{"this-password-is-way-too-long", false},
+		{"letmein2024", false},
+		{"LETMEIN2024", false}, // 大小写变体
+		{"Password123", false},
+		{"alice.smith", false}, // 等于邮箱前缀
+		{"alice.smith1", false},
+		{"Bob-Horse-Battery", true},
+	}
+	for _, tt := range tests {
+		err := p.Check(tt.password, "alice.smith@example.com", "alice")
+		if (err == nil) != tt.ok {
+			t.Errorf("Check(%q) = %v, want ok=%v", tt.password, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("Check(%q) error %v does not wrap ErrWeakPassword", tt.password, err)
+		}
+	}
+
+	if _, err := NewPasswordPolicy(8, 128, filepath.Join(t.TempDir(), "missing"), false); err == nil {
+		t.Error("missing breached list accepted")
+	}
+}
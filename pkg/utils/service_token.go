@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenTypeService 服务间调用 Token，sub/iss 为调用方服务名
+const TokenTypeService = "service"
+
+// ServiceKeySet 受信任的内部服务公钥集合，用于校验服务间 Token
+// 每个调用方服务持有自己的私钥，本服务只需配置其公钥，无需共享任何密钥
+type ServiceKeySet struct {
+	audience     string
+	keys         map[string]crypto.PublicKey // 服务名 -> 公钥
+	certServices map[string]bool             // 凭 mTLS 客户端证书认证的服务
+}
+
+// LoadServiceKeySet 加载受信任服务的公钥，files 为 服务名 -> PEM 公钥文件
+// certServices 为凭 mTLS 客户端证书即可认证的服务名
+func LoadServiceKeySet(audience string, files map[string]string, certServices []string) (*ServiceKeySet, error) {
+	set := &ServiceKeySet{audience: audience, keys: make(map[string]crypto.PublicKey, len(files)), certServices: make(map[string]bool, len(certServices))}
+	for _, name := range certServices {
+		set.certServices[name] = true
+	}
+	for name, path := range files {
+		pub, err := LoadPublicKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("load service key %s: %w", name, err)
+		}
+		set.keys[name] = pub
+	}
+	return set, nil
+}
+
+// VerifyServiceToken 校验服务间 Token，返回调用方服务名
+func (s *ServiceKeySet) VerifyServiceToken(tokenString string) (string, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		// 签名校验前 Claims 已解析，按 iss 选择对应服务的公钥
+		pub, ok := s.keys[claims.Issuer]
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		return pub, nil
+	},
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+		jwt.WithAudience(s.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil || !token.Valid {
+		return "", ErrInvalidToken
+	}
+
+	if claims.Type != TokenTypeService || claims.Subject != claims.Issuer {
+		return "", ErrInvalidToken
+	}
+	return claims.Issuer, nil
+}
+
+// TrustsCertService 证书身份为 name 的调用方是否可直接作为服务认证
+func (s *ServiceKeySet) TrustsCertService(name string) bool {
+	return s.certServices[name]
+}
+
+// ServiceTokenSource 调用方使用：以本服务私钥签发短期服务 Token
+// 实现 credentials.PerRPCCredentials，可直接作为 grpc.WithPerRPCCredentials 使用
+type ServiceTokenSource struct {
+	name     string
+	audience string
+	key      *SigningKey
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewServiceTokenSource name 为调用方服务名，audience 为被调用服务，keyPath 为调用方私钥
+func NewServiceTokenSource(name, audience, keyPath string) (*ServiceTokenSource, error) {
+	key, err := LoadKeyFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return &ServiceTokenSource{name: name, audience: audience, key: key, ttl: 5 * time.Minute}, nil
+}
+
+// Token 返回缓存的服务 Token，临近过期时重新签发
+func (s *ServiceTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expires) > time.Minute {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		Type: TokenTypeService,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.name,
+			Subject:   s.name,
+			Audience:  jwt.ClaimStrings{s.audience},
+			ID:        NewTokenID(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		},
+	}
+	token := jwt.NewWithClaims(s.key.method(), claims)
+	token.Header["kid"] = s.key.ID
+	signed, err := token.SignedString(s.key.Private)
+	if err != nil {
+		return "", err
+	}
+
+	s.token, s.expires = signed, claims.ExpiresAt.Time
+	return s.token, nil
+}
+
+// GetRequestMetadata 实现 credentials.PerRPCCredentials
+func (s *ServiceTokenSource) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := s.Token()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity 实现 credentials.PerRPCCredentials
+func (s *ServiceTokenSource) RequireTransportSecurity() bool {
+	return false
+}
+
+// LoadPublicKeyFile 读取 PEM 公钥 (PKIX)，也接受私钥文件并取其公钥
+func LoadPublicKeyFile(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	if pub, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return pub, nil
+	}
+
+	key, err := LoadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return key.Public, nil
+}
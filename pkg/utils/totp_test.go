@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附录 B 的 SHA-1 测试密钥 "12345678901234567890"
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// RFC 6238 附录 B 的 8 位验证码取后 6 位
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, code := range vectors {
+		step, ok := ValidateTOTP(rfc6238Secret, code, time.Unix(unix, 0), 0)
+		if !ok || step != unix/totpPeriod {
+			t.Errorf("t=%d code=%s: ok=%v step=%d", unix, code, ok, step)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	issued := time.Unix(1111111109, 0)
+	// 下一个时间步内仍在允许的偏差范围内
+	if _, ok := ValidateTOTP(rfc6238Secret, "081804", issued.Add(totpPeriod*time.Second), 1); !ok {
+		t.Fatal("code rejected within skew")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, "081804", issued.Add(2*totpPeriod*time.Second), 1); ok {
+		t.Fatal("code accepted outside skew")
+	}
+	for _, bad := range []string{"", "12345", "1234567", "000000"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, bad, issued, 1); ok {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestGenerateTOTPSecretAndURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := b32.DecodeString(secret); err != nil || len(key) != 20 {
+		t.Fatalf("secret %q: len=%d err=%v", secret, len(key), err)
+	}
+
+	u, err := url.Parse(TOTPURI("UserService", "alice@example.com", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || !strings.HasSuffix(u.Path, "UserService:alice@example.com") {
+		t.Fatalf("unexpected URI %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != secret || q.Get("issuer") != "UserService" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("unexpected query %v", q)
+	}
+}